@@ -3,6 +3,7 @@ package simapp
 import (
 	"io"
 	"os"
+	"path/filepath"
 
 	abci "github.com/tendermint/tendermint/abci/types"
 	cmn "github.com/tendermint/tendermint/libs/common"
@@ -17,7 +18,9 @@ import (
 	"github.com/cosmos/cosmos-sdk/x/auth"
 	"github.com/cosmos/cosmos-sdk/x/auth/genaccounts"
 	"github.com/cosmos/cosmos-sdk/x/bank"
+	"github.com/cosmos/cosmos-sdk/x/contract"
 	"github.com/cosmos/cosmos-sdk/x/crisis"
+	"github.com/cosmos/cosmos-sdk/x/delegation"
 	distr "github.com/cosmos/cosmos-sdk/x/distribution"
 	distrclient "github.com/cosmos/cosmos-sdk/x/distribution/client"
 	"github.com/cosmos/cosmos-sdk/x/genutil"
@@ -53,6 +56,8 @@ var (
 		params.AppModuleBasic{},
 		crisis.AppModuleBasic{},
 		slashing.AppModuleBasic{},
+		delegation.AppModuleBasic{},
+		contract.AppModuleBasic{},
 	)
 )
 
@@ -85,6 +90,8 @@ type SimApp struct {
 	keyFeeCollection *sdk.KVStoreKey
 	keyParams        *sdk.KVStoreKey
 	tkeyParams       *sdk.TransientStoreKey
+	keyDelegation    *sdk.KVStoreKey
+	keyContract      *sdk.KVStoreKey
 
 	// keepers
 	accountKeeper       auth.AccountKeeper
@@ -97,13 +104,16 @@ type SimApp struct {
 	govKeeper           gov.Keeper
 	crisisKeeper        crisis.Keeper
 	paramsKeeper        params.Keeper
+	delegationKeeper    delegation.Keeper
+	contractKeeper      contract.Keeper
 
 	// the module manager
 	mm *module.Manager
 }
 
-// NewSimApp returns a reference to an initialized SimApp.
-func NewSimApp(logger log.Logger, db dbm.DB, traceStore io.Writer, loadLatest bool,
+// NewSimApp returns a reference to an initialized SimApp. Compiled contract
+// modules are cached under the data directory of homePath.
+func NewSimApp(logger log.Logger, db dbm.DB, traceStore io.Writer, loadLatest bool, homePath string,
 	invCheckPeriod uint, baseAppOptions ...func(*bam.BaseApp)) *SimApp {
 
 	cdc := MakeCodec()
@@ -128,6 +138,8 @@ func NewSimApp(logger log.Logger, db dbm.DB, traceStore io.Writer, loadLatest bo
 		keyFeeCollection: sdk.NewKVStoreKey(auth.FeeStoreKey),
 		keyParams:        sdk.NewKVStoreKey(params.StoreKey),
 		tkeyParams:       sdk.NewTransientStoreKey(params.TStoreKey),
+		keyDelegation:    sdk.NewKVStoreKey(delegation.StoreKey),
+		keyContract:      sdk.NewKVStoreKey(contract.StoreKey),
 	}
 
 	// init params keeper and subspaces
@@ -140,6 +152,7 @@ func NewSimApp(logger log.Logger, db dbm.DB, traceStore io.Writer, loadLatest bo
 	slashingSubspace := app.paramsKeeper.Subspace(slashing.DefaultParamspace)
 	govSubspace := app.paramsKeeper.Subspace(gov.DefaultParamspace)
 	crisisSubspace := app.paramsKeeper.Subspace(crisis.DefaultParamspace)
+	contractSubspace := app.paramsKeeper.Subspace(contract.DefaultParamspace)
 
 	// add keepers
 	app.accountKeeper = auth.NewAccountKeeper(app.cdc, app.keyAccount, authSubspace, auth.ProtoBaseAccount)
@@ -154,6 +167,14 @@ func NewSimApp(logger log.Logger, db dbm.DB, traceStore io.Writer, loadLatest bo
 		slashingSubspace, slashing.DefaultCodespace)
 	app.crisisKeeper = crisis.NewKeeper(crisisSubspace, invCheckPeriod, app.distrKeeper,
 		app.bankKeeper, app.feeCollectionKeeper)
+	app.delegationKeeper = delegation.NewKeeper(app.keyDelegation, app.cdc, app.Router())
+	moduleCache, err := contract.NewModuleCache(contract.DefaultModuleCacheSize,
+		filepath.Join(homePath, "data", "wasm-cache"))
+	if err != nil {
+		cmn.Exit(err.Error())
+	}
+	app.contractKeeper = contract.NewKeeper(app.keyContract, app.cdc, app.accountKeeper, app.bankKeeper,
+		app.delegationKeeper, app.QueryRouter(), contractSubspace, moduleCache)
 
 	// register the proposal types
 	govRouter := gov.NewRouter()
@@ -179,6 +200,8 @@ func NewSimApp(logger log.Logger, db dbm.DB, traceStore io.Writer, loadLatest bo
 		mint.NewAppModule(app.mintKeeper),
 		slashing.NewAppModule(app.slashingKeeper, app.stakingKeeper),
 		staking.NewAppModule(app.stakingKeeper, app.feeCollectionKeeper, app.distrKeeper, app.accountKeeper),
+		delegation.NewAppModule(app.delegationKeeper),
+		contract.NewAppModule(app.contractKeeper),
 	)
 
 	// During begin block slashing happens after distr.BeginBlocker so that
@@ -186,13 +209,14 @@ func NewSimApp(logger log.Logger, db dbm.DB, traceStore io.Writer, loadLatest bo
 	// CanWithdrawInvariant invariant.
 	app.mm.SetOrderBeginBlockers(mint.ModuleName, distr.ModuleName, slashing.ModuleName)
 
-	app.mm.SetOrderEndBlockers(gov.ModuleName, staking.ModuleName)
+	app.mm.SetOrderEndBlockers(gov.ModuleName, staking.ModuleName, delegation.ModuleName)
 
 	// genutils must occur after staking so that pools are properly
 	// initialized with tokens from genesis accounts.
 	app.mm.SetOrderInitGenesis(genaccounts.ModuleName, distr.ModuleName,
 		staking.ModuleName, auth.ModuleName, bank.ModuleName, slashing.ModuleName,
-		gov.ModuleName, mint.ModuleName, crisis.ModuleName, genutil.ModuleName)
+		gov.ModuleName, mint.ModuleName, crisis.ModuleName, delegation.ModuleName,
+		contract.ModuleName, genutil.ModuleName)
 
 	app.mm.RegisterInvariants(&app.crisisKeeper)
 	app.mm.RegisterRoutes(app.Router(), app.QueryRouter())
@@ -200,7 +224,8 @@ func NewSimApp(logger log.Logger, db dbm.DB, traceStore io.Writer, loadLatest bo
 	// initialize stores
 	app.MountStores(app.keyMain, app.keyAccount, app.keyStaking, app.keyMint,
 		app.keyDistr, app.keySlashing, app.keyGov, app.keyFeeCollection,
-		app.keyParams, app.tkeyParams, app.tkeyStaking, app.tkeyDistr)
+		app.keyParams, app.tkeyParams, app.tkeyStaking, app.tkeyDistr,
+		app.keyDelegation, app.keyContract)
 
 	// initialize BaseApp
 	app.SetInitChainer(app.InitChainer)
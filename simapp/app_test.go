@@ -6,16 +6,19 @@ import (
 
 	"github.com/stretchr/testify/require"
 	"github.com/tendermint/tendermint/libs/db"
+	dbm "github.com/tendermint/tendermint/libs/db"
 	"github.com/tendermint/tendermint/libs/log"
 
 	"github.com/cosmos/cosmos-sdk/codec"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/contract"
 
 	abci "github.com/tendermint/tendermint/abci/types"
 )
 
 func TestSimAppExport(t *testing.T) {
 	db := db.NewMemDB()
-	app := NewSimApp(log.NewTMLogger(log.NewSyncWriter(os.Stdout)), db, nil, true, 0)
+	app := NewSimApp(log.NewTMLogger(log.NewSyncWriter(os.Stdout)), db, nil, true, DefaultNodeHome, 0)
 
 	genesisState := NewDefaultGenesisState()
 	stateBytes, err := codec.MarshalJSONIndent(app.cdc, genesisState)
@@ -31,7 +34,61 @@ func TestSimAppExport(t *testing.T) {
 	app.Commit()
 
 	// Making a new app object with the db, so that initchain hasn't been called
-	app2 := NewSimApp(log.NewTMLogger(log.NewSyncWriter(os.Stdout)), db, nil, true, 0)
+	app2 := NewSimApp(log.NewTMLogger(log.NewSyncWriter(os.Stdout)), db, nil, true, DefaultNodeHome, 0)
 	_, _, err = app2.ExportAppStateAndValidators(false, []string{})
 	require.NoError(t, err, "ExportAppStateAndValidators should not have an error")
 }
+
+func TestSimAppExportContracts(t *testing.T) {
+	db := db.NewMemDB()
+	app := NewSimApp(log.NewNopLogger(), db, nil, true, DefaultNodeHome, 0)
+
+	stateBytes, err := codec.MarshalJSONIndent(app.cdc, NewDefaultGenesisState())
+	require.NoError(t, err)
+	app.InitChain(abci.RequestInitChain{AppStateBytes: stateBytes})
+
+	// deploy a contract and change its storage
+	ctx := app.NewContext(false, abci.Header{})
+	creator := sdk.AccAddress([]byte("creator_____________"))
+	app.bankKeeper.SetCoins(ctx, creator, sdk.NewCoins(sdk.NewInt64Coin("stake", 100)))
+	code, err := contract.ReadWasmFromFile("../x/contract/examples/kvstore/build/kvstore.wasm")
+	require.NoError(t, err)
+	codeID, sdkErr := app.contractKeeper.StoreCode(ctx, creator, code, "", "")
+	require.NoError(t, sdkErr)
+	addr, res := app.contractKeeper.CreateContract(ctx, creator, creator, codeID, []byte("{}"), sdk.NewCoins(sdk.NewInt64Coin("stake", 10)))
+	require.True(t, res.IsOK(), "%v", res)
+	res = app.contractKeeper.SendContract(ctx, creator, addr, []byte("{}"), nil)
+	require.True(t, res.IsOK(), "%v", res)
+	app.Commit()
+
+	exported, _, err := app.ExportAppStateAndValidators(false, []string{})
+	require.NoError(t, err)
+	var genesisState GenesisState
+	require.NoError(t, app.cdc.UnmarshalJSON(exported, &genesisState))
+	require.NoError(t, contract.AppModuleBasic{}.ValidateGenesis(genesisState[contract.ModuleName]))
+
+	// start a new chain from the exported state
+	app2 := NewSimApp(log.NewNopLogger(), dbm.NewMemDB(), nil, true, DefaultNodeHome, 0)
+	app2.InitChain(abci.RequestInitChain{AppStateBytes: exported})
+	app2.Commit()
+
+	ctx = app.NewContext(true, abci.Header{})
+	ctx2 := app2.NewContext(true, abci.Header{})
+	require.Equal(t, contract.ExportGenesis(ctx, app.contractKeeper), contract.ExportGenesis(ctx2, app2.contractKeeper))
+	require.Equal(t, app.contractKeeper.GetContractAdmin(ctx, addr), app2.contractKeeper.GetContractAdmin(ctx2, addr))
+	require.Equal(t, app.bankKeeper.GetCoins(ctx, addr), app2.bankKeeper.GetCoins(ctx2, addr))
+
+	// the contract keeps running on the new chain, and new contracts don't
+	// reuse its address
+	header := abci.Header{Height: app2.LastBlockHeight() + 1}
+	app2.BeginBlock(abci.RequestBeginBlock{Header: header})
+	ctx2 = app2.NewContext(false, header)
+	res = app2.contractKeeper.SendContract(ctx2, creator, addr, []byte("{}"), nil)
+	require.True(t, res.IsOK(), "%v", res)
+	addr2, res := app2.contractKeeper.CreateContract(ctx2, creator, nil, codeID, []byte("{}"), nil)
+	require.True(t, res.IsOK(), "%v", res)
+	require.NotEqual(t, addr, addr2)
+	codeID2, sdkErr := app2.contractKeeper.StoreCode(ctx2, creator, code, "", "")
+	require.NoError(t, sdkErr)
+	require.NotEqual(t, codeID, codeID2)
+}
@@ -33,9 +33,10 @@ func init() {
 type SignatureVerificationGasConsumer = func(meter sdk.GasMeter, sig []byte, pubkey crypto.PubKey, params Params) sdk.Result
 
 type FeeDelegationHandler interface {
-	// AllowDelegatedFees checks if the grantee can use the granter's account to spend the specified fees, updating
-	// any fee allowance in accordance with the provided fees
-	AllowDelegatedFees(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, fee sdk.Coins) bool
+	// AllowDelegatedFees checks if the grantee can use the granter's account to spend the specified fees on a
+	// transaction with the provided msgs, updating any fee allowance in accordance with the provided fees.
+	// The returned tags are added to the tags of the transaction.
+	AllowDelegatedFees(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, fee sdk.Coins, msgs []sdk.Msg) (bool, sdk.Tags)
 }
 
 // NewAnteHandler returns an AnteHandler that checks and increments sequence
@@ -112,14 +113,17 @@ func NewAnteHandler(ak AccountKeeper, fck FeeCollectionKeeper, feeDelegationHand
 		signerAccs := make([]Account, len(signerAddrs))
 		isGenesis := ctx.BlockHeight() == 0
 
+		var tags sdk.Tags
 		feeAddr := stdTx.FeeAccount
 		if len(feeAddr) != 0 {
 			// check if fees can be delegated
 			if feeDelegationHandler == nil {
-                return newCtx, sdk.ErrUnknownRequest("delegated fees aren't supported").Result(), true
+				return newCtx, sdk.ErrUnknownRequest("delegated fees aren't supported").Result(), true
 			}
-			if !feeDelegationHandler.AllowDelegatedFees(ctx, signerAddrs[0], feeAddr, stdTx.Fee.Amount) {
-				return newCtx, res, true
+			var allow bool
+			allow, tags = feeDelegationHandler.AllowDelegatedFees(ctx, signerAddrs[0], feeAddr, stdTx.Fee.Amount, stdTx.GetMsgs())
+			if !allow {
+				return newCtx, sdk.ErrUnauthorized("fee allowance doesn't allow the fees").Result(), true
 			}
 		} else {
 			// use first signer, who's going to pay the fees
@@ -145,6 +149,8 @@ func NewAnteHandler(ak AccountKeeper, fck FeeCollectionKeeper, feeDelegationHand
 			if err := feeAccount.SetSequence(feeAccount.GetSequence() + 1); err != nil {
 				panic(err)
 			}
+			// the fee account isn't a signer, so it isn't stored below
+			ak.SetAccount(newCtx, feeAccount)
 		}
 
 		// stdSigs contains the sequence number, account number, and signatures.
@@ -172,8 +178,7 @@ func NewAnteHandler(ak AccountKeeper, fck FeeCollectionKeeper, feeDelegationHand
 			ak.SetAccount(newCtx, signerAccs[i])
 		}
 
-		// TODO: tx tags (?)
-		return newCtx, sdk.Result{GasWanted: stdTx.Fee.Gas}, false // continue...
+		return newCtx, sdk.Result{GasWanted: stdTx.Fee.Gas, Tags: tags}, false // continue...
 	}
 }
 
@@ -126,6 +126,13 @@ func (bldr TxBuilder) WithGas(gas uint64) TxBuilder {
 	return bldr
 }
 
+// WithSimulateAndExecute returns a copy of the context with updated
+// simulateAndExecute, which estimates the gas by simulating the tx.
+func (bldr TxBuilder) WithSimulateAndExecute(simulate bool) TxBuilder {
+	bldr.simulateAndExecute = simulate
+	return bldr
+}
+
 // WithFees returns a copy of the context with an updated fee.
 func (bldr TxBuilder) WithFees(fees string) TxBuilder {
 	parsedFees, err := sdk.ParseCoins(fees)
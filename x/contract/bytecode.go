@@ -0,0 +1,661 @@
+package contract
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// This file implements just enough of the wasm binary format to analyze and
+// rewrite uploaded contract code before it is stored.
+
+var wasmHeader = []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+
+// wasm section ids
+const (
+	sectionCustom    byte = 0
+	sectionType      byte = 1
+	sectionImport    byte = 2
+	sectionFunction  byte = 3
+	sectionTable     byte = 4
+	sectionMemory    byte = 5
+	sectionGlobal    byte = 6
+	sectionExport    byte = 7
+	sectionStart     byte = 8
+	sectionElement   byte = 9
+	sectionCode      byte = 10
+	sectionData      byte = 11
+	sectionDataCount byte = 12
+)
+
+// sectionOrder is the order in which non custom sections must appear
+var sectionOrder = []byte{
+	sectionType, sectionImport, sectionFunction, sectionTable, sectionMemory, sectionGlobal,
+	sectionExport, sectionStart, sectionElement, sectionDataCount, sectionCode, sectionData,
+}
+
+// import and export kinds
+const (
+	externFunc   byte = 0
+	externTable  byte = 1
+	externMemory byte = 2
+	externGlobal byte = 3
+)
+
+// value types
+const (
+	valueI32 byte = 0x7f
+	valueI64 byte = 0x7e
+	valueF32 byte = 0x7d
+	valueF64 byte = 0x7c
+)
+
+type wasmSection struct {
+	id   byte
+	data []byte
+}
+
+type wasmFuncType struct {
+	params  []byte
+	results []byte
+}
+
+type wasmLimits struct {
+	min    uint32
+	max    uint32
+	hasMax bool
+}
+
+type wasmImport struct {
+	module string
+	name   string
+	kind   byte
+	// typeIdx is set for function imports
+	typeIdx uint32
+	// limits are set for table and memory imports
+	limits wasmLimits
+	// desc holds the encoded import description following the kind
+	desc []byte
+}
+
+type wasmExport struct {
+	name  string
+	kind  byte
+	index uint32
+}
+
+type wasmGlobal struct {
+	valueType byte
+	mutable   bool
+	init      []instruction
+}
+
+type wasmElement struct {
+	offset  []instruction
+	indices []uint32
+}
+
+type wasmLocal struct {
+	count     uint32
+	valueType byte
+}
+
+type wasmCode struct {
+	locals []wasmLocal
+	body   []instruction
+}
+
+// instruction is a single decoded wasm instruction
+type instruction struct {
+	// op is the opcode, sub the opcode following a 0xfc prefix
+	op  byte
+	sub uint32
+	// index is the function called by call and ref.func
+	index uint32
+	// imm holds the encoded immediates of all other instructions
+	imm []byte
+}
+
+const (
+	opUnreachable  byte = 0x00
+	opBlock        byte = 0x02
+	opLoop         byte = 0x03
+	opIf           byte = 0x04
+	opElse         byte = 0x05
+	opEnd          byte = 0x0b
+	opBrIf         byte = 0x0d
+	opCall         byte = 0x10
+	opSelectTyped  byte = 0x1c
+	opMemoryGrow   byte = 0x40
+	opI32Const     byte = 0x41
+	opI32Eqz       byte = 0x45
+	opRefFunc      byte = 0xd2
+	opPrefixFC     byte = 0xfc
+	blockTypeEmpty byte = 0x40
+)
+
+// wasmReader decodes wasm primitives. The first error is kept and makes all
+// further reads return zero values.
+type wasmReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func newWasmReader(data []byte) *wasmReader {
+	return &wasmReader{data: data}
+}
+
+func (r *wasmReader) fail(format string, args ...interface{}) {
+	if r.err == nil {
+		r.err = errors.Errorf("offset %d: %s", r.pos, fmt.Sprintf(format, args...))
+	}
+}
+
+func (r *wasmReader) done() bool {
+	return r.err != nil || r.pos >= len(r.data)
+}
+
+func (r *wasmReader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos >= len(r.data) {
+		r.fail("unexpected end")
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *wasmReader) bytes(n uint32) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if uint64(r.pos)+uint64(n) > uint64(len(r.data)) {
+		r.fail("unexpected end")
+		return nil
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b
+}
+
+func (r *wasmReader) u32() uint32 {
+	var res uint64
+	for shift := uint(0); shift < 35; shift += 7 {
+		b := r.byte()
+		res |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			if res > 0xffffffff {
+				r.fail("integer too large")
+			}
+			return uint32(res)
+		}
+	}
+	r.fail("integer too long")
+	return 0
+}
+
+// skipSigned skips a signed LEB128 integer of at most the given bits
+func (r *wasmReader) skipSigned(bits uint) {
+	for n := uint(0); n < (bits+6)/7; n++ {
+		if r.byte()&0x80 == 0 {
+			return
+		}
+	}
+	r.fail("integer too long")
+}
+
+func (r *wasmReader) name() string {
+	return string(r.bytes(r.u32()))
+}
+
+func (r *wasmReader) limits() wasmLimits {
+	var l wasmLimits
+	switch flag := r.byte(); flag {
+	case 0x00:
+		l.min = r.u32()
+	case 0x01:
+		l.min = r.u32()
+		l.max = r.u32()
+		l.hasMax = true
+	default:
+		r.fail("unsupported limits flag 0x%x", flag)
+	}
+	return l
+}
+
+func (r *wasmReader) valueType() byte {
+	t := r.byte()
+	switch t {
+	case valueI32, valueI64, valueF32, valueF64:
+	default:
+		r.fail("unsupported value type 0x%x", t)
+	}
+	return t
+}
+
+func appendU32(buf []byte, v uint32) []byte {
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(buf, b)
+		}
+		buf = append(buf, b|0x80)
+	}
+}
+
+func appendI32(buf []byte, v int32) []byte {
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			return append(buf, b)
+		}
+		buf = append(buf, b|0x80)
+	}
+}
+
+func appendName(buf []byte, name string) []byte {
+	buf = appendU32(buf, uint32(len(name)))
+	return append(buf, name...)
+}
+
+// readSections splits a wasm module into its sections
+func readSections(code []byte) ([]wasmSection, error) {
+	if !bytes.HasPrefix(code, wasmHeader) {
+		return nil, errors.New("not a wasm module")
+	}
+	r := newWasmReader(code[len(wasmHeader):])
+	var sections []wasmSection
+	for !r.done() {
+		id := r.byte()
+		data := r.bytes(r.u32())
+		sections = append(sections, wasmSection{id: id, data: data})
+	}
+	if r.err != nil {
+		return nil, errors.Wrap(r.err, "reading sections")
+	}
+	return sections, nil
+}
+
+// writeSections encodes sections into a wasm module
+func writeSections(sections []wasmSection) []byte {
+	out := append([]byte{}, wasmHeader...)
+	for _, s := range sections {
+		out = append(out, s.id)
+		out = appendU32(out, uint32(len(s.data)))
+		out = append(out, s.data...)
+	}
+	return out
+}
+
+func findSection(sections []wasmSection, id byte) []byte {
+	for _, s := range sections {
+		if s.id == id {
+			return s.data
+		}
+	}
+	return nil
+}
+
+// setSection replaces the section with the given id, or inserts it at its
+// position in the section order
+func setSection(sections []wasmSection, id byte, data []byte) []wasmSection {
+	for i, s := range sections {
+		if s.id == id {
+			sections[i].data = data
+			return sections
+		}
+	}
+	rank := func(id byte) int {
+		for i, o := range sectionOrder {
+			if o == id {
+				return i
+			}
+		}
+		return -1
+	}
+	pos := len(sections)
+	for i, s := range sections {
+		if s.id != sectionCustom && rank(s.id) > rank(id) {
+			pos = i
+			break
+		}
+	}
+	sections = append(sections, wasmSection{})
+	copy(sections[pos+1:], sections[pos:])
+	sections[pos] = wasmSection{id: id, data: data}
+	return sections
+}
+
+// readVector calls item for each element of the vector encoded in data. A
+// nil data, as returned by findSection for a missing section, is an empty
+// vector.
+func readVector(data []byte, item func(r *wasmReader)) error {
+	if data == nil {
+		return nil
+	}
+	r := newWasmReader(data)
+	n := r.u32()
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		item(r)
+	}
+	if r.err == nil && r.pos != len(r.data) {
+		r.fail("trailing bytes")
+	}
+	return r.err
+}
+
+func parseTypes(data []byte) ([]wasmFuncType, error) {
+	var types []wasmFuncType
+	err := readVector(data, func(r *wasmReader) {
+		if form := r.byte(); form != 0x60 {
+			r.fail("unsupported type form 0x%x", form)
+			return
+		}
+		var t wasmFuncType
+		for i, n := uint32(0), r.u32(); i < n && r.err == nil; i++ {
+			t.params = append(t.params, r.valueType())
+		}
+		for i, n := uint32(0), r.u32(); i < n && r.err == nil; i++ {
+			t.results = append(t.results, r.valueType())
+		}
+		types = append(types, t)
+	})
+	return types, errors.Wrap(err, "type section")
+}
+
+func encodeTypes(types []wasmFuncType) []byte {
+	out := appendU32(nil, uint32(len(types)))
+	for _, t := range types {
+		out = append(out, 0x60)
+		out = appendU32(out, uint32(len(t.params)))
+		out = append(out, t.params...)
+		out = appendU32(out, uint32(len(t.results)))
+		out = append(out, t.results...)
+	}
+	return out
+}
+
+func parseImports(data []byte) ([]wasmImport, error) {
+	var imports []wasmImport
+	err := readVector(data, func(r *wasmReader) {
+		imp := wasmImport{module: r.name(), name: r.name(), kind: r.byte()}
+		start := r.pos
+		switch imp.kind {
+		case externFunc:
+			imp.typeIdx = r.u32()
+		case externTable:
+			r.byte()
+			imp.limits = r.limits()
+		case externMemory:
+			imp.limits = r.limits()
+		case externGlobal:
+			r.valueType()
+			r.byte()
+		default:
+			r.fail("unsupported import kind 0x%x", imp.kind)
+		}
+		if r.err == nil {
+			imp.desc = r.data[start:r.pos]
+		}
+		imports = append(imports, imp)
+	})
+	return imports, errors.Wrap(err, "import section")
+}
+
+func encodeImports(imports []wasmImport) []byte {
+	out := appendU32(nil, uint32(len(imports)))
+	for _, imp := range imports {
+		out = appendName(out, imp.module)
+		out = appendName(out, imp.name)
+		out = append(out, imp.kind)
+		out = append(out, imp.desc...)
+	}
+	return out
+}
+
+func parseFunctions(data []byte) ([]uint32, error) {
+	var funcs []uint32
+	err := readVector(data, func(r *wasmReader) {
+		funcs = append(funcs, r.u32())
+	})
+	return funcs, errors.Wrap(err, "function section")
+}
+
+func parseLimitsSection(data []byte, tables bool) ([]wasmLimits, error) {
+	var limits []wasmLimits
+	err := readVector(data, func(r *wasmReader) {
+		if tables {
+			if t := r.byte(); t != 0x70 {
+				r.fail("unsupported table type 0x%x", t)
+			}
+		}
+		limits = append(limits, r.limits())
+	})
+	return limits, err
+}
+
+func encodeMemories(memories []wasmLimits) []byte {
+	out := appendU32(nil, uint32(len(memories)))
+	for _, l := range memories {
+		if l.hasMax {
+			out = append(out, 0x01)
+			out = appendU32(out, l.min)
+			out = appendU32(out, l.max)
+		} else {
+			out = append(out, 0x00)
+			out = appendU32(out, l.min)
+		}
+	}
+	return out
+}
+
+func parseGlobals(data []byte) ([]wasmGlobal, error) {
+	var globals []wasmGlobal
+	err := readVector(data, func(r *wasmReader) {
+		g := wasmGlobal{valueType: r.valueType(), mutable: r.byte() == 1}
+		g.init = r.expression()
+		globals = append(globals, g)
+	})
+	return globals, errors.Wrap(err, "global section")
+}
+
+func parseExports(data []byte) ([]wasmExport, error) {
+	var exports []wasmExport
+	err := readVector(data, func(r *wasmReader) {
+		exports = append(exports, wasmExport{name: r.name(), kind: r.byte(), index: r.u32()})
+	})
+	return exports, errors.Wrap(err, "export section")
+}
+
+func encodeExports(exports []wasmExport) []byte {
+	out := appendU32(nil, uint32(len(exports)))
+	for _, e := range exports {
+		out = appendName(out, e.name)
+		out = append(out, e.kind)
+		out = appendU32(out, e.index)
+	}
+	return out
+}
+
+func parseElements(data []byte) ([]wasmElement, error) {
+	var elems []wasmElement
+	err := readVector(data, func(r *wasmReader) {
+		if flag := r.u32(); flag != 0 {
+			r.fail("unsupported element segment kind %d", flag)
+			return
+		}
+		e := wasmElement{offset: r.expression()}
+		for i, n := uint32(0), r.u32(); i < n && r.err == nil; i++ {
+			e.indices = append(e.indices, r.u32())
+		}
+		elems = append(elems, e)
+	})
+	return elems, errors.Wrap(err, "element section")
+}
+
+func encodeElements(elems []wasmElement) []byte {
+	out := appendU32(nil, uint32(len(elems)))
+	for _, e := range elems {
+		out = appendU32(out, 0)
+		out = encodeInstructions(out, e.offset)
+		out = appendU32(out, uint32(len(e.indices)))
+		for _, idx := range e.indices {
+			out = appendU32(out, idx)
+		}
+	}
+	return out
+}
+
+func parseCode(data []byte) ([]wasmCode, error) {
+	var codes []wasmCode
+	err := readVector(data, func(r *wasmReader) {
+		body := newWasmReader(r.bytes(r.u32()))
+		var c wasmCode
+		for i, n := uint32(0), body.u32(); i < n && body.err == nil; i++ {
+			c.locals = append(c.locals, wasmLocal{count: body.u32(), valueType: body.valueType()})
+		}
+		c.body = body.expression()
+		if body.err == nil && body.pos != len(body.data) {
+			body.fail("trailing bytes after function body")
+		}
+		if body.err != nil {
+			r.fail("function %d: %s", len(codes), body.err)
+		}
+		codes = append(codes, c)
+	})
+	return codes, errors.Wrap(err, "code section")
+}
+
+func encodeCode(codes []wasmCode) []byte {
+	out := appendU32(nil, uint32(len(codes)))
+	for _, c := range codes {
+		body := appendU32(nil, uint32(len(c.locals)))
+		for _, l := range c.locals {
+			body = appendU32(body, l.count)
+			body = append(body, l.valueType)
+		}
+		body = encodeInstructions(body, c.body)
+		out = appendU32(out, uint32(len(body)))
+		out = append(out, body...)
+	}
+	return out
+}
+
+// expression decodes instructions up to and including the end closing the
+// expression
+func (r *wasmReader) expression() []instruction {
+	var instrs []instruction
+	depth := 0
+	for r.err == nil {
+		ins := r.instruction()
+		instrs = append(instrs, ins)
+		switch ins.op {
+		case opBlock, opLoop, opIf:
+			depth++
+		case opEnd:
+			if depth == 0 {
+				return instrs
+			}
+			depth--
+		}
+	}
+	return instrs
+}
+
+// instruction decodes a single instruction
+func (r *wasmReader) instruction() instruction {
+	ins := instruction{op: r.byte()}
+	start := r.pos
+	switch op := ins.op; {
+	case op == opBlock || op == opLoop || op == opIf:
+		// block type, either empty, a value type or a type index
+		r.skipSigned(33)
+	case op == 0x0c || op == opBrIf:
+		r.u32()
+	case op == 0x0e:
+		for i, n := uint32(0), r.u32(); i < n && r.err == nil; i++ {
+			r.u32()
+		}
+		r.u32()
+	case op == opCall || op == opRefFunc:
+		ins.index = r.u32()
+		return ins
+	case op == 0x11:
+		r.u32()
+		r.byte()
+	case op == opSelectTyped:
+		for i, n := uint32(0), r.u32(); i < n && r.err == nil; i++ {
+			r.valueType()
+		}
+	case op >= 0x20 && op <= 0x26:
+		r.u32()
+	case op >= 0x28 && op <= 0x3e:
+		r.u32()
+		r.u32()
+	case op == 0x3f || op == opMemoryGrow:
+		r.byte()
+	case op == opI32Const:
+		r.skipSigned(32)
+	case op == 0x42:
+		r.skipSigned(64)
+	case op == 0x43:
+		r.bytes(4)
+	case op == 0x44:
+		r.bytes(8)
+	case op == 0xd0:
+		r.byte()
+	case op == opPrefixFC:
+		ins.sub = r.u32()
+		start = r.pos
+		switch ins.sub {
+		case 0, 1, 2, 3, 4, 5, 6, 7:
+		case 8:
+			r.u32()
+			r.byte()
+		case 9, 13, 15, 16, 17:
+			r.u32()
+		case 10:
+			r.byte()
+			r.byte()
+		case 11:
+			r.byte()
+		case 12, 14:
+			r.u32()
+			r.u32()
+		default:
+			r.fail("unsupported instruction 0xfc %d", ins.sub)
+		}
+	case op <= 0x01 || op == opElse || op == opEnd || op == 0x0f || op == 0x1a || op == 0x1b:
+	case op >= opI32Eqz && op <= 0xc4:
+	case op == 0xd1:
+	default:
+		r.fail("unsupported instruction 0x%x", op)
+	}
+	if r.err == nil {
+		ins.imm = r.data[start:r.pos]
+	}
+	return ins
+}
+
+func encodeInstructions(out []byte, instrs []instruction) []byte {
+	for _, ins := range instrs {
+		out = append(out, ins.op)
+		switch ins.op {
+		case opCall, opRefFunc:
+			out = appendU32(out, ins.index)
+		case opPrefixFC:
+			out = appendU32(out, ins.sub)
+			out = append(out, ins.imm...)
+		default:
+			out = append(out, ins.imm...)
+		}
+	}
+	return out
+}
@@ -0,0 +1,210 @@
+package contract
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	wasm "github.com/wasmerio/go-ext-wasm/wasmer"
+)
+
+// DefaultModuleCacheSize is the number of compiled modules kept in memory
+const DefaultModuleCacheSize = 100
+
+// ModuleCache keeps the most recently used compiled wasm modules, keyed by
+// the hash of their code, so contracts don't have to be recompiled on every
+// call. If a directory is set, compiled modules are also serialized to disk
+// and survive restarts of the node. Modules read back from disk are checked
+// against the hash of the code before they are used.
+//
+// A nil *ModuleCache is valid and compiles the code on every call.
+type ModuleCache struct {
+	mtx     sync.Mutex
+	size    int
+	dir     string
+	lru     *list.List
+	entries map[[sha256.Size]byte]*list.Element
+	metrics CacheMetrics
+}
+
+// CacheMetrics counts how compiled modules were obtained
+type CacheMetrics struct {
+	// Hits is the number of modules found in memory
+	Hits uint64 `json:"hits"`
+	// DiskHits is the number of modules loaded from the cache directory
+	DiskHits uint64 `json:"disk_hits"`
+	// Misses is the number of modules that had to be compiled
+	Misses uint64 `json:"misses"`
+}
+
+type cachedModule struct {
+	hash   [sha256.Size]byte
+	module wasm.Module
+}
+
+// NewModuleCache creates a cache holding up to size compiled modules in
+// memory. If dir is not empty, compiled modules are stored there as well,
+// the node home would typically use <home>/data/wasm-cache.
+func NewModuleCache(size int, dir string) (*ModuleCache, error) {
+	if size < 1 {
+		return nil, errors.Errorf("invalid module cache size %d", size)
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, errors.Wrap(err, "creating module cache directory")
+		}
+	}
+	return &ModuleCache{
+		size:    size,
+		dir:     dir,
+		lru:     list.New(),
+		entries: make(map[[sha256.Size]byte]*list.Element),
+	}, nil
+}
+
+// Metrics returns the cache hit counters
+func (c *ModuleCache) Metrics() CacheMetrics {
+	if c == nil {
+		return CacheMetrics{}
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.metrics
+}
+
+// Len returns the number of modules held in memory
+func (c *ModuleCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.lru.Len()
+}
+
+// instantiate creates an instance of code with the given imports, compiling
+// the code only if it isn't cached yet
+func (c *ModuleCache) instantiate(code []byte, imports *wasm.Imports) (wasm.Instance, error) {
+	if c == nil {
+		return wasm.NewInstanceWithImports(code, imports)
+	}
+	hash := sha256.Sum256(code)
+
+	c.mtx.Lock()
+	if elem, ok := c.entries[hash]; ok {
+		c.metrics.Hits++
+		c.lru.MoveToFront(elem)
+		// instantiating under the lock keeps the module from being evicted
+		// and closed in the meantime
+		defer c.mtx.Unlock()
+		return elem.Value.(*cachedModule).module.InstantiateWithImports(imports)
+	}
+	c.mtx.Unlock()
+
+	module, fromDisk, err := c.load(hash, code)
+	if err != nil {
+		return wasm.Instance{}, err
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if fromDisk {
+		c.metrics.DiskHits++
+	} else {
+		c.metrics.Misses++
+	}
+	if elem, ok := c.entries[hash]; ok {
+		// compiled concurrently by another call
+		module.Close()
+		c.lru.MoveToFront(elem)
+		module = elem.Value.(*cachedModule).module
+	} else {
+		c.entries[hash] = c.lru.PushFront(&cachedModule{hash: hash, module: module})
+		for c.lru.Len() > c.size {
+			oldest := c.lru.Remove(c.lru.Back()).(*cachedModule)
+			delete(c.entries, oldest.hash)
+			oldest.module.Close()
+		}
+	}
+	return module.InstantiateWithImports(imports)
+}
+
+// load reads the compiled module from the cache directory, or compiles code
+// and stores the result there
+func (c *ModuleCache) load(hash [sha256.Size]byte, code []byte) (wasm.Module, bool, error) {
+	var path string
+	if c.dir != "" {
+		path = filepath.Join(c.dir, hex.EncodeToString(hash[:])+".module")
+		if bz, err := ioutil.ReadFile(path); err == nil {
+			// an artifact that is corrupt or doesn't belong to the code is
+			// simply compiled again
+			if serialized, ok := verifyArtifact(hash, bz); ok {
+				if module, err := wasm.DeserializeModule(serialized); err == nil {
+					return module, true, nil
+				}
+			}
+		}
+	}
+
+	module, err := wasm.Compile(code)
+	if err != nil {
+		return wasm.Module{}, false, err
+	}
+	if path != "" {
+		// failing to persist the module only costs a compilation later on
+		if bz, err := module.Serialize(); err == nil {
+			writeFileAtomic(path, newArtifact(hash, bz))
+		}
+	}
+	return module, false, nil
+}
+
+// newArtifact prefixes a serialized module with the hash of the code it was
+// compiled from and its own checksum
+func newArtifact(hash [sha256.Size]byte, serialized []byte) []byte {
+	sum := sha256.Sum256(serialized)
+	bz := make([]byte, 0, 2*sha256.Size+len(serialized))
+	bz = append(bz, hash[:]...)
+	bz = append(bz, sum[:]...)
+	return append(bz, serialized...)
+}
+
+// verifyArtifact returns the serialized module of an artifact written by
+// newArtifact, if it was compiled from the code with the given hash and
+// wasn't modified since
+func verifyArtifact(hash [sha256.Size]byte, bz []byte) ([]byte, bool) {
+	if len(bz) < 2*sha256.Size || !bytes.Equal(bz[:sha256.Size], hash[:]) {
+		return nil, false
+	}
+	serialized := bz[2*sha256.Size:]
+	sum := sha256.Sum256(serialized)
+	if !bytes.Equal(bz[sha256.Size:2*sha256.Size], sum[:]) {
+		return nil, false
+	}
+	return serialized, true
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it, so concurrent readers never see a partial module
+func writeFileAtomic(path string, data []byte) {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+}
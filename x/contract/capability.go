@@ -0,0 +1,46 @@
+package contract
+
+import (
+	"bytes"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/delegation"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+// SendContractCapability allows sending msgs and payments to a single
+// contract on behalf of the granter
+type SendContractCapability struct {
+	Contract sdk.AccAddress
+	// SpendLimit specifies the maximum amount of tokens that can be paid to
+	// the contract by this capability and will be updated as tokens are
+	// spent. Msgs without a payment are always allowed.
+	SpendLimit sdk.Coins
+}
+
+var _ delegation.Capability = SendContractCapability{}
+
+func (cap SendContractCapability) MsgType() sdk.Msg {
+	return MsgSendContract{}
+}
+
+func (cap SendContractCapability) Accept(msg sdk.Msg, block abci.Header) (allow bool, updated delegation.Capability, delete bool) {
+	switch msg := msg.(type) {
+	case MsgSendContract:
+		if !bytes.Equal(msg.Contract, cap.Contract) {
+			return false, nil, false
+		}
+		if msg.Payment.Empty() {
+			return true, nil, false
+		}
+		left, invalid := cap.SpendLimit.SafeSub(msg.Payment)
+		if invalid {
+			return false, nil, false
+		}
+		if left.IsZero() {
+			return true, nil, true
+		}
+		return true, SendContractCapability{Contract: cap.Contract, SpendLimit: left}, false
+	}
+	return false, nil, false
+}
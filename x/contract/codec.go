@@ -9,4 +9,8 @@ func RegisterCodec(cdc *codec.Codec) {
 	cdc.RegisterConcrete(MsgStoreCode{}, "contract/MsgStoreCode", nil)
 	cdc.RegisterConcrete(MsgCreateContract{}, "contract/MsgCreateContract", nil)
 	cdc.RegisterConcrete(MsgSendContract{}, "contract/MsgSendContract", nil)
+	cdc.RegisterConcrete(MsgMigrateContract{}, "contract/MsgMigrateContract", nil)
+	cdc.RegisterConcrete(MsgUpdateAdmin{}, "contract/MsgUpdateAdmin", nil)
+	cdc.RegisterConcrete(MsgClearAdmin{}, "contract/MsgClearAdmin", nil)
+	cdc.RegisterConcrete(SendContractCapability{}, "contract/SendContractCapability", nil)
 }
@@ -0,0 +1,117 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/delegation"
+)
+
+// MaxCallDepth is the maximum nesting of contract calls dispatching
+// messages that call contracts again
+const MaxCallDepth = 10
+
+type callDepthKey struct{}
+
+// callDepth returns how many contract calls are dispatching messages in ctx
+func callDepth(ctx sdk.Context) int {
+	depth, _ := ctx.Value(callDepthKey{}).(int)
+	return depth
+}
+
+// contractReply is passed to the reply entry point once a submessage was
+// dispatched
+type contractReply struct {
+	ContractAddress sdk.AccAddress `json:"contract_address"`
+	ID              uint64         `json:"id,string"`
+	// Data is the result data of the submessage
+	Data []byte `json:"data"`
+	// Error is empty if the submessage succeeded
+	Error string `json:"error"`
+	// Env describes the block the contract runs in
+	Env ContractEnv `json:"env"`
+}
+
+// dispatch routes the messages returned by contract. Msgs must all succeed.
+// Every submessage is executed in a cache context which is only written if
+// it succeeds, and its outcome is passed to the reply entry point of the
+// contract.
+func (k Keeper) dispatch(ctx sdk.Context, contract sdk.AccAddress, res *SendResponse) sdk.Result {
+	depth := callDepth(ctx)
+	if depth >= MaxCallDepth {
+		return sdk.ErrUnknownRequest(fmt.Sprintf("contract call depth exceeds %d", MaxCallDepth)).Result()
+	}
+	ctx = ctx.WithValue(callDepthKey{}, depth+1)
+
+	result := k.delegationKeeper.DispatchActions(ctx, contract, res.Msgs)
+	if !result.IsOK() {
+		return result
+	}
+	result.Tags = append(contractTags(contract, res.Log), result.Tags...)
+	result.Data = res.Data
+
+	for _, sub := range res.SubMsgs {
+		subCtx, write := ctx.CacheContext()
+		subRes := k.delegationKeeper.DispatchActions(subCtx, contract, []sdk.Msg{sub.Msg})
+		reply := contractReply{
+			ContractAddress: contract,
+			ID:              sub.ID,
+		}
+		if subRes.IsOK() {
+			write()
+			result.Tags = result.Tags.AppendTags(subRes.Tags)
+			// the submessage is dispatched on its own, so its data is the
+			// only result
+			results, err := delegation.DecodeMsgResults(subRes.Data)
+			if err != nil || len(results) != 1 {
+				return sdk.ErrInternal("invalid submessage result").Result()
+			}
+			reply.Data = results[0].Data
+		} else {
+			// only the code is passed on, the log is not deterministic
+			reply.Error = fmt.Sprintf("codespace: %s, code: %d", subRes.Codespace, subRes.Code)
+		}
+
+		replyRes := k.reply(ctx, contract, reply)
+		if !replyRes.IsOK() {
+			return replyRes
+		}
+		result.Tags = result.Tags.AppendTags(replyRes.Tags)
+		// the reply may override the data of the call
+		if replyRes.Data != nil {
+			result.Data = replyRes.Data
+		}
+	}
+	return result
+}
+
+// contractTags converts the log of contract into tags, prefixing each key
+// with the contract address so tags of different contracts can't be
+// confused with each other or those of other modules
+func contractTags(contract sdk.AccAddress, log []LogAttribute) sdk.Tags {
+	tags := sdk.EmptyTags()
+	for _, attr := range log {
+		tags = tags.AppendTag(fmt.Sprintf("contract.%s.%s", contract, attr.Key), attr.Value)
+	}
+	return tags
+}
+
+// reply calls the reply entry point of contract and dispatches the messages
+// it returns
+func (k Keeper) reply(ctx sdk.Context, contract sdk.AccAddress, reply contractReply) sdk.Result {
+	codeBz, err := k.contractCode(ctx, contract)
+	if err != nil {
+		return err.Result()
+	}
+	reply.Env = k.contractEnv(ctx, contract)
+	txtMsg, stdErr := json.Marshal(reply)
+	if stdErr != nil {
+		return sdk.ErrUnknownRequest(stdErr.Error()).Result()
+	}
+	res, err := k.execute(ctx, codeBz, contract, contract, "reply", txtMsg)
+	if err != nil {
+		return err.Result()
+	}
+	return k.dispatch(ctx, contract, res)
+}
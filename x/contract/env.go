@@ -0,0 +1,304 @@
+package contract
+
+// #include <stdlib.h>
+// #include <stdint.h>
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"unsafe"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
+	wasm "github.com/wasmerio/go-ext-wasm/wasmer"
+)
+
+// Env is the environment of a single contract call. It is attached to the
+// wasm instance as context data, so host functions resolve the store, gas
+// meter and caller of the call they were invoked from rather than sharing
+// package level state with every other running contract.
+type Env struct {
+	Store    sdk.KVStore
+	Key      []byte
+	GasMeter sdk.GasMeter
+	Contract sdk.AccAddress
+	Sender   sdk.AccAddress
+	Header   abci.Header
+	// InstructionCost is the gas charged per wasm instruction by metered code
+	InstructionCost uint64
+	// Balance returns the coins owned by the contract
+	Balance func() sdk.Coins
+	// Logger receives debug output of the call, it may be nil
+	Logger log.Logger
+	// Querier answers the queries of the contract, it may be nil
+	Querier func(path string, data []byte) ([]byte, sdk.Error)
+	// QueryCost is the gas charged for every query of the contract
+	QueryCost uint64
+
+	instance  *wasm.Instance
+	iterators []sdk.Iterator
+	// values holds the value of the key last returned by each iterator
+	values   [][]byte
+	outOfGas bool
+	// failure is set by host functions that can't return an error to the
+	// contract, the call fails once it returns
+	failure error
+}
+
+// NewEnv creates the environment for calling contract on behalf of sender
+func NewEnv(ctx sdk.Context, store sdk.KVStore, key []byte, contract sdk.AccAddress, sender sdk.AccAddress) *Env {
+	return &Env{
+		Store:    store,
+		Key:      key,
+		GasMeter: ctx.GasMeter(),
+		Contract: contract,
+		Sender:   sender,
+		Header:   ctx.BlockHeader(),
+		Logger:   ctx.Logger().With("module", "x/contract", "contract", contract.String()),
+	}
+}
+
+func (env *Env) logger() log.Logger {
+	if env.Logger == nil {
+		return log.NewNopLogger()
+	}
+	return env.Logger
+}
+
+// Get returns the value stored under key in the contract store, or nil
+func (env *Env) Get(key []byte) []byte {
+	return env.Store.Get(key)
+}
+
+// Set stores value under key in the contract store
+func (env *Env) Set(key, value []byte) {
+	env.Store.Set(key, value)
+}
+
+// Delete removes key from the contract store
+func (env *Env) Delete(key []byte) {
+	env.Store.Delete(key)
+}
+
+// Range opens an iterator over the contract store in the domain [start, end)
+// and returns its handle. A nil start or end leaves that side of the range
+// open. Iterators stay valid until the call returns.
+func (env *Env) Range(start, end []byte) int32 {
+	env.iterators = append(env.iterators, env.Store.Iterator(start, end))
+	env.values = append(env.values, nil)
+	return int32(len(env.iterators))
+}
+
+// Next returns the next key of the iterator with the given handle, and
+// false once the iterator is exhausted. The value stored under the key is
+// returned by Value until Next is called again.
+func (env *Env) Next(handle int32) ([]byte, bool) {
+	if handle < 1 || int(handle) > len(env.iterators) {
+		return nil, false
+	}
+	iter := env.iterators[handle-1]
+	if !iter.Valid() {
+		env.values[handle-1] = nil
+		return nil, false
+	}
+	key, value := iter.Key(), iter.Value()
+	env.values[handle-1] = value
+	iter.Next()
+	return key, true
+}
+
+// Value returns the value of the key last returned by Next for the iterator
+// with the given handle, or nil
+func (env *Env) Value(handle int32) []byte {
+	if handle < 1 || int(handle) > len(env.values) {
+		return nil
+	}
+	return env.values[handle-1]
+}
+
+func (env *Env) closeIterators() {
+	for _, iter := range env.iterators {
+		iter.Close()
+	}
+	env.iterators = nil
+	env.values = nil
+}
+
+// Funds returns the json encoded balance of the contract for all denoms
+func (env *Env) Funds() string {
+	coins := sdk.Coins{}
+	if env.Balance != nil {
+		if balance := env.Balance(); balance != nil {
+			coins = balance
+		}
+	}
+	bz, err := json.Marshal(coins)
+	if err != nil {
+		panic(err)
+	}
+	return string(bz)
+}
+
+// Query answers the json encoded ContractQuery of the contract and returns
+// the json encoded QueryResponse. Failed queries are reported to the
+// contract, like submessages only with their error code as the log isn't
+// deterministic.
+func (env *Env) Query(request []byte) string {
+	env.GasMeter.ConsumeGas(env.QueryCost, "contract query")
+	var res QueryResponse
+	var query ContractQuery
+	if err := json.Unmarshal(request, &query); err != nil {
+		res.Error = "invalid query request"
+	} else if env.Querier == nil {
+		res.Error = "queries are not supported"
+	} else if bz, err := env.Querier(query.Path, query.Data); err != nil {
+		res.Error = fmt.Sprintf("codespace: %s, code: %d", err.Codespace(), err.Code())
+	} else if len(bz) > 0 {
+		if !json.Valid(bz) {
+			res.Error = "query result is not json"
+		} else {
+			res.Result = bz
+		}
+	}
+	bz, err := json.Marshal(res)
+	if err != nil {
+		panic(err)
+	}
+	return string(bz)
+}
+
+// ConsumeInstructions charges the gas for executing n wasm instructions and
+// returns false once the call ran out of gas
+func (env *Env) ConsumeInstructions(n int32) bool {
+	if env.outOfGas || env.failure != nil {
+		return false
+	}
+	defer env.recoverHostPanic()
+	env.GasMeter.ConsumeGas(env.InstructionCost*uint64(n), "wasm instructions")
+	return true
+}
+
+// recoverHostPanic must be deferred by every host function touching the gas
+// meter or the store. Panics must not unwind through the wasm runtime, so
+// running out of gas or writing to a read-only store is recorded on the
+// environment and reported once the call returns.
+func (env *Env) recoverHostPanic() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	switch r := r.(type) {
+	case sdk.ErrorOutOfGas:
+		env.outOfGas = true
+	case readOnlyError:
+		env.fail(r)
+	default:
+		panic(r)
+	}
+}
+
+// readOnlyError is the panic raised by writes to a readOnlyStore
+type readOnlyError struct{}
+
+func (readOnlyError) Error() string {
+	return "contract store is read-only"
+}
+
+// readOnlyStore wraps the contract store during queries, writes panic
+type readOnlyStore struct {
+	sdk.KVStore
+}
+
+// NewReadOnlyStore returns a view of store panicking on writes
+func NewReadOnlyStore(store sdk.KVStore) sdk.KVStore {
+	return readOnlyStore{store}
+}
+
+func (readOnlyStore) Set(key, value []byte) {
+	panic(readOnlyError{})
+}
+
+func (readOnlyStore) Delete(key []byte) {
+	panic(readOnlyError{})
+}
+
+// ReadDB returns the contract state stored under the environment key
+func (env *Env) ReadDB() string {
+	bz := env.Store.Get(env.Key)
+	return string(bz)
+}
+
+// WriteDB replaces the contract state stored under the environment key
+func (env *Env) WriteDB(val string) {
+	env.Store.Set(env.Key, []byte(val))
+}
+
+// WasmString can be called by a go function provided into Imports
+// It will allocate space in wasm, copy the string there, and return a pointer
+// The pointer can be returned to the wasm caller to receive the string. If
+// the contract doesn't allocate valid memory the call fails and 0 is returned.
+func (env *Env) WasmString(res string) int32 {
+	ptr, err := prepareString(*env.instance, res)
+	if err != nil {
+		env.fail(err)
+		return 0
+	}
+	return ptr
+}
+
+// fail records err for host functions that can't return an error to the
+// contract, only the first failure is kept
+func (env *Env) fail(err error) {
+	if env.failure == nil {
+		env.failure = err
+	}
+}
+
+// Environments are handed to wasmer by handle, as cgo doesn't allow C code
+// to hold on to go pointers.
+var (
+	envsMtx   sync.RWMutex
+	envs      = make(map[uint64]*Env)
+	lastEnvID uint64
+)
+
+// attachEnv registers env and sets its handle as the context data of
+// instance. The returned function releases the handle again.
+func attachEnv(instance *wasm.Instance, env *Env) func() {
+	envsMtx.Lock()
+	lastEnvID++
+	id := lastEnvID
+	envs[id] = env
+	envsMtx.Unlock()
+
+	handle := (*C.uint64_t)(C.malloc(C.sizeof_uint64_t))
+	*handle = C.uint64_t(id)
+	env.instance = instance
+	instance.SetContextData(unsafe.Pointer(handle))
+
+	return func() {
+		envsMtx.Lock()
+		delete(envs, id)
+		envsMtx.Unlock()
+		C.free(unsafe.Pointer(handle))
+		env.closeIterators()
+		env.instance = nil
+	}
+}
+
+// envFromContext returns the environment attached to the instance a host
+// function was called from
+func envFromContext(context unsafe.Pointer) *Env {
+	instanceContext := wasm.IntoInstanceContext(context)
+	handle := (*C.uint64_t)(instanceContext.Data())
+	envsMtx.RLock()
+	defer envsMtx.RUnlock()
+	env, ok := envs[uint64(*handle)]
+	if !ok {
+		panic("contract: no environment attached to wasm instance")
+	}
+	return env
+}
@@ -0,0 +1,8 @@
+#!/bin/bash
+
+rm -r build || true
+mkdir build
+
+for name in float import memory select; do
+  wat2wasm $name.wat -o build/$name.wasm
+done
@@ -0,0 +1,28 @@
+;; float is a contract computing with floating point numbers, which is
+;; rejected on upload as float results may differ between machines.
+(module
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+
+  (data (i32.const 128) "{\22msgs\22:[]}\00")
+
+  (func $allocate (export "allocate") (param $size i32) (result i32)
+    global.get $heap
+    global.get $heap
+    local.get $size
+    i32.add
+    i32.const 1
+    i32.add
+    global.set $heap)
+
+  (func $init (export "init_wrapper") (param $msg i32) (result i32)
+    i32.const 128)
+
+  (func $send (export "send_wrapper") (param $msg i32) (result i32)
+    local.get $msg
+    f32.convert_i32_s
+    f32.const 1.5
+    f32.mul
+    i32.trunc_f32_s
+    drop
+    i32.const 128))
@@ -0,0 +1,26 @@
+;; import is a contract importing a function the host doesn't provide,
+;; which is rejected on upload.
+(module
+  (import "env" "c_random" (func $c_random (result i32)))
+
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+
+  (data (i32.const 128) "{\22msgs\22:[]}\00")
+
+  (func $allocate (export "allocate") (param $size i32) (result i32)
+    global.get $heap
+    global.get $heap
+    local.get $size
+    i32.add
+    i32.const 1
+    i32.add
+    global.set $heap)
+
+  (func $init (export "init_wrapper") (param $msg i32) (result i32)
+    i32.const 128)
+
+  (func $send (export "send_wrapper") (param $msg i32) (result i32)
+    call $c_random
+    drop
+    i32.const 128))
@@ -0,0 +1,22 @@
+;; memory is a contract starting with 64 pages of memory, more than the
+;; default limit of 32 pages, so it is rejected on upload.
+(module
+  (memory (export "memory") 64)
+  (global $heap (mut i32) (i32.const 1024))
+
+  (data (i32.const 128) "{\22msgs\22:[]}\00")
+
+  (func $allocate (export "allocate") (param $size i32) (result i32)
+    global.get $heap
+    global.get $heap
+    local.get $size
+    i32.add
+    i32.const 1
+    i32.add
+    global.set $heap)
+
+  (func $init (export "init_wrapper") (param $msg i32) (result i32)
+    i32.const 128)
+
+  (func $send (export "send_wrapper") (param $msg i32) (result i32)
+    i32.const 128))
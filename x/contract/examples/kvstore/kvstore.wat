@@ -0,0 +1,118 @@
+;; kvstore exercises the key-value host imports.
+;;
+;; init stores foo=bar and foz=baz. send copies foo to copy, deletes foz and
+;; stores the number of keys left in the range [f, g) under count.
+;;
+;; init also keeps its message under init and the contract balance under
+;; balance. It stores a query response under the json string "answer". query
+;; looks up its json message as a key and returns the stored response.
+;; migrate keeps its message under migrated.
+(module
+  (import "env" "c_get" (func $c_get (param i32) (result i32)))
+  (import "env" "c_set" (func $c_set (param i32 i32)))
+  (import "env" "c_delete" (func $c_delete (param i32)))
+  (import "env" "c_range" (func $c_range (param i32 i32) (result i32)))
+  (import "env" "c_next" (func $c_next (param i32) (result i32)))
+  (import "env" "c_balance" (func $c_balance (result i32)))
+
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+
+  (data (i32.const 8) "foo\00")
+  (data (i32.const 16) "bar\00")
+  (data (i32.const 24) "foz\00")
+  (data (i32.const 32) "baz\00")
+  (data (i32.const 40) "copy\00")
+  (data (i32.const 48) "f\00")
+  (data (i32.const 56) "g\00")
+  (data (i32.const 64) "count\00")
+  (data (i32.const 128) "{\22msgs\22:[]}\00")
+  (data (i32.const 160) "\22answer\22\00")
+  (data (i32.const 176) "{\22result\22:{\22foo\22:\22bar\22}}\00")
+  (data (i32.const 208) "{\22error\22:\22not found\22}\00")
+  (data (i32.const 240) "balance\00")
+  (data (i32.const 256) "init\00")
+  (data (i32.const 272) "migrated\00")
+
+  ;; bump allocator, leaving room for the terminating NUL
+  (func $allocate (export "allocate") (param $size i32) (result i32)
+    global.get $heap
+    global.get $heap
+    local.get $size
+    i32.add
+    i32.const 1
+    i32.add
+    global.set $heap)
+
+  (func $init (export "init_wrapper") (param $msg i32) (result i32)
+    i32.const 8
+    i32.const 16
+    call $c_set
+    i32.const 24
+    i32.const 32
+    call $c_set
+    i32.const 160
+    i32.const 176
+    call $c_set
+    i32.const 240
+    call $c_balance
+    call $c_set
+    i32.const 256
+    local.get $msg
+    call $c_set
+    i32.const 128)
+
+  (func $migrate (export "migrate") (param $msg i32) (result i32)
+    i32.const 272
+    local.get $msg
+    call $c_set
+    i32.const 128)
+
+  (func $query (export "query") (param $msg i32) (result i32) (local $res i32)
+    local.get $msg
+    call $c_get
+    local.tee $res
+    i32.eqz
+    if
+      i32.const 208
+      local.set $res
+    end
+    local.get $res)
+
+  (func $send (export "send_wrapper") (param $msg i32) (result i32) (local $iter i32) (local $n i32)
+    i32.const 40
+    i32.const 8
+    call $c_get
+    call $c_set
+    i32.const 24
+    call $c_delete
+    i32.const 48
+    i32.const 56
+    call $c_range
+    local.set $iter
+    block $done
+      loop $next
+        local.get $iter
+        call $c_next
+        i32.eqz
+        br_if $done
+        local.get $n
+        i32.const 1
+        i32.add
+        local.set $n
+        br $next
+      end
+    end
+    ;; count as a single digit string at 72
+    i32.const 72
+    local.get $n
+    i32.const 48
+    i32.add
+    i32.store8
+    i32.const 73
+    i32.const 0
+    i32.store8
+    i32.const 64
+    i32.const 72
+    call $c_set
+    i32.const 128))
@@ -0,0 +1,39 @@
+;; loop never returns from send, it is used to test running out of gas.
+;;
+;; init stores foo=bar and returns. send overwrites foo and then loops
+;; forever.
+(module
+  (import "env" "c_set" (func $c_set (param i32 i32)))
+
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+
+  (data (i32.const 8) "foo\00")
+  (data (i32.const 16) "bar\00")
+  (data (i32.const 24) "baz\00")
+  (data (i32.const 128) "{\22msgs\22:[]}\00")
+
+  ;; bump allocator, leaving room for the terminating NUL
+  (func $allocate (export "allocate") (param $size i32) (result i32)
+    global.get $heap
+    global.get $heap
+    local.get $size
+    i32.add
+    i32.const 1
+    i32.add
+    global.set $heap)
+
+  (func $init (export "init_wrapper") (param $msg i32) (result i32)
+    i32.const 8
+    i32.const 16
+    call $c_set
+    i32.const 128)
+
+  (func $send (export "send_wrapper") (param $msg i32) (result i32)
+    i32.const 8
+    i32.const 24
+    call $c_set
+    loop $forever
+      br $forever
+    end
+    i32.const 128))
@@ -0,0 +1,6 @@
+#!/bin/bash
+
+rm -r build || true
+mkdir build
+
+wat2wasm memory.wat -o build/memory.wasm
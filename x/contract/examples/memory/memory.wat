@@ -0,0 +1,46 @@
+;; memory grows its memory, to test that memory is limited.
+;;
+;; send grows the memory from 1 to 17 pages and then to 33 pages. It stores
+;; grown=yes if that succeeded or grown=no if the memory limit was reached.
+(module
+  (import "env" "c_set" (func $c_set (param i32 i32)))
+
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+
+  (data (i32.const 8) "grown\00")
+  (data (i32.const 16) "yes\00")
+  (data (i32.const 24) "no\00")
+  (data (i32.const 128) "{\22msgs\22:[]}\00")
+
+  ;; bump allocator, leaving room for the terminating NUL
+  (func $allocate (export "allocate") (param $size i32) (result i32)
+    global.get $heap
+    global.get $heap
+    local.get $size
+    i32.add
+    i32.const 1
+    i32.add
+    global.set $heap)
+
+  (func $init (export "init_wrapper") (param $msg i32) (result i32)
+    i32.const 128)
+
+  (func $send (export "send_wrapper") (param $msg i32) (result i32)
+    i32.const 16
+    memory.grow
+    drop
+    i32.const 16
+    memory.grow
+    i32.const -1
+    i32.eq
+    if
+      i32.const 8
+      i32.const 24
+      call $c_set
+    else
+      i32.const 8
+      i32.const 16
+      call $c_set
+    end
+    i32.const 128))
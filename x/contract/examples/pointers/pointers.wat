@@ -0,0 +1,64 @@
+;; pointers passes invalid pointers to the host, which must fail the call
+;; instead of crashing.
+;;
+;; set_negative stores under a negative key pointer, get_unterminated reads a
+;; key running to the end of memory without a NUL and set_missing stores a
+;; zero key. return_outside returns a pointer beyond the memory.
+;; get_bad_allocation looks up foo with an allocator returning memory beyond
+;; the end of the memory.
+(module
+  (import "env" "c_get" (func $c_get (param i32) (result i32)))
+  (import "env" "c_set" (func $c_set (param i32 i32)))
+  (import "env" "c_write" (func $c_write (param i32)))
+
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+  (global $broken (mut i32) (i32.const 0))
+
+  (data (i32.const 8) "foo\00")
+  (data (i32.const 16) "bar\00")
+  (data (i32.const 65530) "abcdef")
+
+  ;; bump allocator, leaving room for the terminating NUL, returning the last
+  ;; byte of memory once broken
+  (func $allocate (export "allocate") (param $size i32) (result i32)
+    i32.const 65535
+    global.get $heap
+    global.get $heap
+    local.get $size
+    i32.add
+    i32.const 1
+    i32.add
+    global.set $heap
+    global.get $broken
+    select)
+
+  (func $set_negative (export "set_negative") (result i32)
+    i32.const -8
+    i32.const 16
+    call $c_set
+    i32.const 16)
+
+  (func $get_unterminated (export "get_unterminated") (result i32)
+    i32.const 65530
+    call $c_get)
+
+  (func $set_missing (export "set_missing") (result i32)
+    i32.const 0
+    i32.const 16
+    call $c_set
+    i32.const 16)
+
+  (func $write_outside (export "write_outside") (result i32)
+    i32.const 70000
+    call $c_write
+    i32.const 16)
+
+  (func $return_outside (export "return_outside") (result i32)
+    i32.const 70000)
+
+  (func $get_bad_allocation (export "get_bad_allocation") (result i32)
+    i32.const 1
+    global.set $broken
+    i32.const 8
+    call $c_get))
@@ -3,6 +3,8 @@
 rm -r build || true
 mkdir build
 
-cargo build --release --target=wasm32-unknown-unknown
+# contracts run on the wasm MVP, without the extensions enabled by default
+# since rust 1.82
+RUSTFLAGS="-C target-cpu=mvp $RUSTFLAGS" cargo build --release --target=wasm32-unknown-unknown
 
 find ./target/wasm32-unknown-unknown/release/ -name "*.wasm" -exec cp "{}" build/ ";"
@@ -1,55 +1,137 @@
-use crate::{get_state, set_state, CosmosMsg, InitParams, SendAmount, SendParams};
+use crate::json::{self, Value};
+use crate::{get, range, remove, set, CosmosMsg, Error, Params, SendAmount};
 
-use failure::{bail, Error};
-use serde::{Deserialize, Serialize};
-use serde_json::{from_slice, from_str, to_vec};
+use alloc::string::String;
+use alloc::vec;
+use alloc::vec::Vec;
+
+/// The funds of every funder are kept under funds/<address> until the
+/// verifier releases them to the beneficiary
+const FUNDS_PREFIX: &str = "funds/";
+/// FUNDS_END is the first key after all keys starting with FUNDS_PREFIX
+const FUNDS_END: &str = "funds0";
 
-#[derive(Serialize, Deserialize)]
 struct RegenInitMsg {
     verifier: String,
     beneficiary: String,
 }
 
-#[derive(Serialize, Deserialize)]
-struct RegenState {
-    verifier: String,
-    beneficiary: String,
-    payout: u64,
-    funder: String,
+impl RegenInitMsg {
+    fn parse(msg: &Value) -> Result<RegenInitMsg, Error> {
+        let field = |key| msg.get(key).and_then(Value::as_str).map(String::from);
+        Ok(RegenInitMsg {
+            verifier: field("verifier").ok_or("invalid msg")?,
+            beneficiary: field("beneficiary").ok_or("invalid msg")?,
+        })
+    }
 }
 
-#[derive(Serialize, Deserialize)]
-struct RegenSendMsg {}
+/// RegenSendMsg is {} to release the funds, or {"fund":{}} to add the sent
+/// funds
+enum RegenSendMsg {
+    Release,
+    Fund,
+}
 
-pub fn init(params: InitParams) -> Result<Vec<CosmosMsg>, Error> {
-    let msg: RegenInitMsg = from_str(params.msg.get())?;
+impl RegenSendMsg {
+    fn parse(msg: &Value) -> Result<RegenSendMsg, Error> {
+        match msg {
+            Value::Object(fields) if fields.is_empty() => Ok(RegenSendMsg::Release),
+            _ if msg.get("fund").is_some() => Ok(RegenSendMsg::Fund),
+            _ => Err("invalid msg"),
+        }
+    }
+}
 
-    set_state(to_vec(&RegenState {
-        verifier: msg.verifier,
-        beneficiary: msg.beneficiary,
-        payout: params.sent_funds,
-        funder: params.sender
-    })?);
+fn funds_key(funder: &str) -> String {
+    let mut key = String::from(FUNDS_PREFIX);
+    key.push_str(funder);
+    key
+}
+
+fn parse_amount(value: &[u8]) -> Result<u64, Error> {
+    json::parse_u64(value).ok_or("invalid state")
+}
+
+/// funds returns the funds of funder that weren't released yet
+fn funds(funder: &str) -> Result<u64, Error> {
+    match get(&funds_key(funder))? {
+        Some(value) => parse_amount(&value),
+        None => Ok(0),
+    }
+}
+
+fn add_funds(funder: &str, amount: u64) -> Result<(), Error> {
+    if amount == 0 {
+        return Ok(());
+    }
+    let total = funds(funder)?.checked_add(amount).ok_or("overflow")?;
+    let mut value = String::new();
+    json::write_u64(&mut value, total);
+    set(&funds_key(funder), &value)
+}
+
+fn get_address(key: &str) -> Result<String, Error> {
+    let value = get(key)?.ok_or("invalid state")?;
+    String::from_utf8(value).map_err(|_| "invalid state")
+}
+
+pub fn init(params: Params) -> Result<Vec<CosmosMsg>, Error> {
+    let msg = RegenInitMsg::parse(&params.msg)?;
+
+    set("verifier", &msg.verifier)?;
+    set("beneficiary", &msg.beneficiary)?;
+    add_funds(&params.sender, params.sent_funds)?;
 
     Ok(Vec::new())
 }
 
-pub fn send(params: SendParams) -> Result<Vec<CosmosMsg>, Error> {
-    let mut state: RegenState = from_slice(&get_state())?;
-    let funds = state.payout + params.sent_funds;
-    state.payout = 0;
-    set_state(to_vec(&state)?);
-
-    if params.sender == state.verifier {
-        Ok(vec![CosmosMsg::SendTx {
-            from_address: params.contract_address,
-            to_address: state.beneficiary,
-            amount: vec![SendAmount {
-                denom: "earth".into(),
-                amount: funds.to_string(),
-            }],
-        }])
-    } else {
-        bail!("Unauthorized")
+pub fn send(params: Params) -> Result<Vec<CosmosMsg>, Error> {
+    match RegenSendMsg::parse(&params.msg)? {
+        RegenSendMsg::Fund => {
+            add_funds(&params.sender, params.sent_funds)?;
+            Ok(Vec::new())
+        }
+        RegenSendMsg::Release => release(params),
+    }
+}
+
+/// release pays the funds of all funders and the sent funds to the
+/// beneficiary
+fn release(params: Params) -> Result<Vec<CosmosMsg>, Error> {
+    if params.sender != get_address("verifier")? {
+        return Err("Unauthorized");
+    }
+
+    let mut total = params.sent_funds;
+    for (key, value) in range(FUNDS_PREFIX, FUNDS_END)? {
+        total = total.checked_add(parse_amount(&value)?).ok_or("overflow")?;
+        remove(&key)?;
+    }
+    if total == 0 {
+        return Ok(Vec::new());
     }
+
+    let mut amount = String::new();
+    json::write_u64(&mut amount, total);
+    Ok(vec![CosmosMsg::SendTx {
+        from_address: params.contract_address,
+        to_address: get_address("beneficiary")?,
+        amount: vec![SendAmount {
+            denom: "earth".into(),
+            amount,
+        }],
+    }])
+}
+
+/// query answers {"funds":"<address>"} with {"amount":<funds of address>}
+pub fn query(msg: &Value) -> Result<String, Error> {
+    let funder = msg
+        .get("funds")
+        .and_then(Value::as_str)
+        .ok_or("invalid query")?;
+    let mut out = String::from(r#"{"amount":"#);
+    json::write_u64(&mut out, funds(funder)?);
+    out.push('}');
+    Ok(out)
 }
@@ -0,0 +1,307 @@
+//! A small json parser and writer. Numbers are only read as unsigned
+//! integers, so unlike json libraries it adds no floating point code to the
+//! contract, which would be rejected on upload.
+
+use alloc::string::String;
+use alloc::vec::Vec;
+
+/// Documents nested deeper than this are rejected
+const MAX_DEPTH: usize = 32;
+
+// contracts only read the values they need
+#[allow(dead_code)]
+pub enum Value {
+    Null,
+    Bool(bool),
+    /// Number is kept as written, see as_u64
+    Number(String),
+    String(String),
+    Array(Vec<Value>),
+    Object(Vec<(String, Value)>),
+}
+
+impl Value {
+    /// get returns the field of an object
+    pub fn get(&self, key: &str) -> Option<&Value> {
+        match self {
+            Value::Object(fields) => fields.iter().find(|(k, _)| k == key).map(|(_, v)| v),
+            _ => None,
+        }
+    }
+
+    pub fn as_str(&self) -> Option<&str> {
+        match self {
+            Value::String(s) => Some(s),
+            _ => None,
+        }
+    }
+
+    /// as_u64 returns the value of non-negative integers fitting into u64,
+    /// numbers with a fraction or exponent are not supported
+    pub fn as_u64(&self) -> Option<u64> {
+        match self {
+            Value::Number(n) => parse_u64(n.as_bytes()),
+            _ => None,
+        }
+    }
+}
+
+/// parse_u64 reads a non-negative decimal integer fitting into u64
+pub fn parse_u64(digits: &[u8]) -> Option<u64> {
+    if digits.is_empty() {
+        return None;
+    }
+    let mut n: u64 = 0;
+    for &c in digits {
+        if !c.is_ascii_digit() {
+            return None;
+        }
+        n = n.checked_mul(10)?.checked_add(u64::from(c - b'0'))?;
+    }
+    Some(n)
+}
+
+/// parse reads a single json value, surrounded by optional whitespace
+pub fn parse(input: &[u8]) -> Result<Value, &'static str> {
+    let mut p = Parser { input, pos: 0 };
+    let value = p.value(0)?;
+    p.skip_whitespace();
+    if p.pos != input.len() {
+        return Err("invalid json");
+    }
+    Ok(value)
+}
+
+struct Parser<'a> {
+    input: &'a [u8],
+    pos: usize,
+}
+
+impl<'a> Parser<'a> {
+    fn peek(&self) -> Option<u8> {
+        self.input.get(self.pos).cloned()
+    }
+
+    fn next(&mut self) -> Result<u8, &'static str> {
+        let c = self.peek().ok_or("invalid json")?;
+        self.pos += 1;
+        Ok(c)
+    }
+
+    fn expect(&mut self, c: u8) -> Result<(), &'static str> {
+        if self.next()? != c {
+            return Err("invalid json");
+        }
+        Ok(())
+    }
+
+    fn skip_whitespace(&mut self) {
+        while let Some(b' ') | Some(b'\t') | Some(b'\n') | Some(b'\r') = self.peek() {
+            self.pos += 1;
+        }
+    }
+
+    fn literal(&mut self, word: &[u8], value: Value) -> Result<Value, &'static str> {
+        if !self.input[self.pos..].starts_with(word) {
+            return Err("invalid json");
+        }
+        self.pos += word.len();
+        Ok(value)
+    }
+
+    fn value(&mut self, depth: usize) -> Result<Value, &'static str> {
+        if depth > MAX_DEPTH {
+            return Err("json nested too deeply");
+        }
+        self.skip_whitespace();
+        match self.peek().ok_or("invalid json")? {
+            b'n' => self.literal(b"null", Value::Null),
+            b't' => self.literal(b"true", Value::Bool(true)),
+            b'f' => self.literal(b"false", Value::Bool(false)),
+            b'"' => Ok(Value::String(self.string()?)),
+            b'[' => {
+                self.pos += 1;
+                let mut items = Vec::new();
+                self.skip_whitespace();
+                if self.peek() == Some(b']') {
+                    self.pos += 1;
+                    return Ok(Value::Array(items));
+                }
+                loop {
+                    items.push(self.value(depth + 1)?);
+                    self.skip_whitespace();
+                    match self.next()? {
+                        b',' => continue,
+                        b']' => return Ok(Value::Array(items)),
+                        _ => return Err("invalid json"),
+                    }
+                }
+            }
+            b'{' => {
+                self.pos += 1;
+                let mut fields = Vec::new();
+                self.skip_whitespace();
+                if self.peek() == Some(b'}') {
+                    self.pos += 1;
+                    return Ok(Value::Object(fields));
+                }
+                loop {
+                    self.skip_whitespace();
+                    let key = self.string()?;
+                    self.skip_whitespace();
+                    self.expect(b':')?;
+                    fields.push((key, self.value(depth + 1)?));
+                    self.skip_whitespace();
+                    match self.next()? {
+                        b',' => continue,
+                        b'}' => return Ok(Value::Object(fields)),
+                        _ => return Err("invalid json"),
+                    }
+                }
+            }
+            b'-' | b'0'..=b'9' => self.number(),
+            _ => Err("invalid json"),
+        }
+    }
+
+    /// number checks the json number grammar and keeps the text
+    fn number(&mut self) -> Result<Value, &'static str> {
+        let start = self.pos;
+        if self.peek() == Some(b'-') {
+            self.pos += 1;
+        }
+        match self.next()? {
+            b'0' => {}
+            b'1'..=b'9' => self.digits(),
+            _ => return Err("invalid json"),
+        }
+        if self.peek() == Some(b'.') {
+            self.pos += 1;
+            self.required_digits()?;
+        }
+        if let Some(b'e') | Some(b'E') = self.peek() {
+            self.pos += 1;
+            if let Some(b'+') | Some(b'-') = self.peek() {
+                self.pos += 1;
+            }
+            self.required_digits()?;
+        }
+        // the number is ascii
+        let text = core::str::from_utf8(&self.input[start..self.pos]).map_err(|_| "invalid json")?;
+        Ok(Value::Number(String::from(text)))
+    }
+
+    fn digits(&mut self) {
+        while let Some(b'0'..=b'9') = self.peek() {
+            self.pos += 1;
+        }
+    }
+
+    fn required_digits(&mut self) -> Result<(), &'static str> {
+        match self.next()? {
+            b'0'..=b'9' => {
+                self.digits();
+                Ok(())
+            }
+            _ => Err("invalid json"),
+        }
+    }
+
+    fn string(&mut self) -> Result<String, &'static str> {
+        self.expect(b'"')?;
+        let mut out = Vec::new();
+        loop {
+            match self.next()? {
+                b'"' => break,
+                b'\\' => {
+                    let c = match self.next()? {
+                        b'"' => '"',
+                        b'\\' => '\\',
+                        b'/' => '/',
+                        b'b' => '\u{8}',
+                        b'f' => '\u{c}',
+                        b'n' => '\n',
+                        b'r' => '\r',
+                        b't' => '\t',
+                        b'u' => self.unicode_escape()?,
+                        _ => return Err("invalid json"),
+                    };
+                    let mut buf = [0; 4];
+                    out.extend_from_slice(c.encode_utf8(&mut buf).as_bytes());
+                }
+                c if c < 0x20 => return Err("invalid json"),
+                c => out.push(c),
+            }
+        }
+        String::from_utf8(out).map_err(|_| "invalid json")
+    }
+
+    /// unicode_escape reads the code point of a \u escape, combining
+    /// surrogate pairs
+    fn unicode_escape(&mut self) -> Result<char, &'static str> {
+        let mut c = self.hex4()?;
+        if (0xd800..0xdc00).contains(&c) {
+            self.expect(b'\\')?;
+            self.expect(b'u')?;
+            let low = self.hex4()?;
+            if !(0xdc00..0xe000).contains(&low) {
+                return Err("invalid json");
+            }
+            c = 0x10000 + ((c - 0xd800) << 10) + (low - 0xdc00);
+        }
+        core::char::from_u32(c).ok_or("invalid json")
+    }
+
+    fn hex4(&mut self) -> Result<u32, &'static str> {
+        let mut n = 0;
+        for _ in 0..4 {
+            let d = match self.next()? {
+                c @ b'0'..=b'9' => c - b'0',
+                c @ b'a'..=b'f' => c - b'a' + 10,
+                c @ b'A'..=b'F' => c - b'A' + 10,
+                _ => return Err("invalid json"),
+            };
+            n = n * 16 + u32::from(d);
+        }
+        Ok(n)
+    }
+}
+
+/// write_str appends s to out as a json string
+pub fn write_str(out: &mut String, s: &str) {
+    const HEX: &[u8; 16] = b"0123456789abcdef";
+    out.push('"');
+    for c in s.chars() {
+        match c {
+            '"' => out.push_str("\\\""),
+            '\\' => out.push_str("\\\\"),
+            '\n' => out.push_str("\\n"),
+            '\r' => out.push_str("\\r"),
+            '\t' => out.push_str("\\t"),
+            c if (c as u32) < 0x20 => {
+                out.push_str("\\u00");
+                out.push(HEX[(c as usize) >> 4] as char);
+                out.push(HEX[(c as usize) & 0xf] as char);
+            }
+            c => out.push(c),
+        }
+    }
+    out.push('"');
+}
+
+/// write_u64 appends n to out in decimal
+pub fn write_u64(out: &mut String, mut n: u64) {
+    let mut buf = [0u8; 20];
+    let mut i = buf.len();
+    loop {
+        i -= 1;
+        buf[i] = b'0' + (n % 10) as u8;
+        n /= 10;
+        if n == 0 {
+            break;
+        }
+    }
+    for &c in &buf[i..] {
+        out.push(c as char);
+    }
+}
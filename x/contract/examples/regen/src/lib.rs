@@ -1,35 +1,55 @@
-extern crate failure;
-extern crate heapless;
-extern crate serde;
-extern crate serde_json;
-
-use failure::Error;
-use serde::{Deserialize, Serialize};
-use serde_json::value::RawValue;
-use serde_json::{from_slice, to_vec};
-use std::ffi::{CStr, CString};
-use std::mem;
-use std::os::raw::{c_char, c_void};
+#![no_std]
+
+extern crate alloc;
+
+use alloc::ffi::CString;
+use alloc::string::String;
+use alloc::vec::Vec;
+use core::alloc::{GlobalAlloc, Layout};
+use core::arch::wasm32;
+use core::ffi::{c_char, c_void, CStr};
+use core::panic::PanicInfo;
+use core::ptr;
 
 mod contract;
+mod json;
 use contract::{init, send};
+use json::Value;
 
-#[derive(Serialize, Deserialize)]
-pub struct SendParams<'a> {
+pub type Error = &'static str;
+
+/// Params are passed to init_wrapper and send_wrapper
+pub struct Params {
     contract_address: String,
     sender: String,
-    #[serde(borrow)]
-    msg: &'a RawValue,
+    msg: Value,
     sent_funds: u64,
 }
 
-#[derive(Serialize, Deserialize)]
-struct RegenSendMsg {}
+impl Params {
+    fn parse(data: &[u8]) -> Result<Params, Error> {
+        let params = json::parse(data)?;
+        let field = |key| params.get(key).and_then(Value::as_str).map(String::from);
+        Ok(Params {
+            contract_address: field("contract_address").ok_or("invalid params")?,
+            sender: field("sender").ok_or("invalid params")?,
+            sent_funds: params
+                .get("sent_funds")
+                .and_then(Value::as_u64)
+                .ok_or("invalid params")?,
+            msg: match params {
+                Value::Object(fields) => fields
+                    .into_iter()
+                    .find(|(k, _)| k == "msg")
+                    .map(|(_, v)| v)
+                    .ok_or("invalid params")?,
+                _ => return Err("invalid params"),
+            },
+        })
+    }
+}
 
-#[derive(Serialize, Deserialize)]
-#[serde(tag = "type", content = "value")]
 pub enum CosmosMsg {
-    #[serde(rename = "cosmos-sdk/MsgSend")]
     SendTx {
         from_address: String,
         to_address: String,
@@ -37,139 +57,229 @@ pub enum CosmosMsg {
     },
 }
 
-#[derive(Serialize, Deserialize)]
+impl CosmosMsg {
+    fn write_json(&self, out: &mut String) {
+        match self {
+            CosmosMsg::SendTx {
+                from_address,
+                to_address,
+                amount,
+            } => {
+                out.push_str(r#"{"type":"cosmos-sdk/MsgSend","value":{"from_address":"#);
+                json::write_str(out, from_address);
+                out.push_str(r#","to_address":"#);
+                json::write_str(out, to_address);
+                out.push_str(r#","amount":["#);
+                for (i, coin) in amount.iter().enumerate() {
+                    if i > 0 {
+                        out.push(',');
+                    }
+                    out.push_str(r#"{"denom":"#);
+                    json::write_str(out, &coin.denom);
+                    out.push_str(r#","amount":"#);
+                    json::write_str(out, &coin.amount);
+                    out.push('}');
+                }
+                out.push_str("]}}");
+            }
+        }
+    }
+}
+
 pub struct SendAmount {
     denom: String,
     amount: String,
 }
 
-#[derive(Serialize, Deserialize)]
 enum ContractResult {
-    #[serde(rename = "msgs")]
     Msgs(Vec<CosmosMsg>),
-    #[serde(rename = "error")]
-    Error(String),
+    Error(Error),
+}
+
+impl ContractResult {
+    fn to_json(&self) -> String {
+        let mut out = String::new();
+        match self {
+            ContractResult::Msgs(msgs) => {
+                out.push_str(r#"{"msgs":["#);
+                for (i, msg) in msgs.iter().enumerate() {
+                    if i > 0 {
+                        out.push(',');
+                    }
+                    msg.write_json(&mut out);
+                }
+                out.push_str("]}");
+            }
+            ContractResult::Error(e) => {
+                out.push_str(r#"{"error":"#);
+                json::write_str(&mut out, e);
+                out.push('}');
+            }
+        }
+        out
+    }
 }
 
 extern "C" {
-    fn c_read() -> *mut c_char;
-    fn c_write(string: *mut c_char);
+    fn c_get(key: *const c_char) -> *mut c_char;
+    fn c_set(key: *const c_char, value: *const c_char);
+    fn c_delete(key: *const c_char);
+    fn c_range(start: *const c_char, end: *const c_char) -> i32;
+    fn c_next(iter: i32) -> *mut c_char;
+    fn c_value(iter: i32) -> *mut c_char;
 }
 
-pub fn get_state() -> std::vec::Vec<u8> {
-    let state: std::vec::Vec<u8>;
+fn c_string(s: &str) -> Result<CString, Error> {
+    CString::new(s).map_err(|_| "string contains NUL")
+}
 
-    unsafe {
-        state = CStr::from_ptr(c_read()).to_bytes().to_vec();
+/// from_host takes a string returned by the host, null stands for none
+unsafe fn from_host(ptr: *mut c_char) -> Option<Vec<u8>> {
+    if ptr.is_null() {
+        None
+    } else {
+        Some(CStr::from_ptr(ptr).to_bytes().to_vec())
     }
-
-    state
 }
 
-pub fn set_state(state: Vec<u8>) {
-    unsafe {
-        c_write(CString::new(state).unwrap().into_raw());
-    }
+/// get returns the value stored under key
+pub fn get(key: &str) -> Result<Option<Vec<u8>>, Error> {
+    let key = c_string(key)?;
+    Ok(unsafe { from_host(c_get(key.as_ptr())) })
 }
 
-#[no_mangle]
-pub extern "C" fn allocate(size: usize) -> *mut c_void {
-    let mut buffer = Vec::with_capacity(size);
-    let pointer = buffer.as_mut_ptr();
-    mem::forget(buffer);
+pub fn set(key: &str, value: &str) -> Result<(), Error> {
+    let key = c_string(key)?;
+    let value = c_string(value)?;
+    unsafe { c_set(key.as_ptr(), value.as_ptr()) };
+    Ok(())
+}
 
-    pointer as *mut c_void
+pub fn remove(key: &str) -> Result<(), Error> {
+    let key = c_string(key)?;
+    unsafe { c_delete(key.as_ptr()) };
+    Ok(())
 }
 
-#[no_mangle]
-pub extern "C" fn deallocate(pointer: *mut c_void, capacity: usize) {
+/// range returns the keys in [start, end) along with their values
+pub fn range(start: &str, end: &str) -> Result<Vec<(String, Vec<u8>)>, Error> {
+    let start = c_string(start)?;
+    let end = c_string(end)?;
+    let mut entries = Vec::new();
     unsafe {
-        let _ = Vec::from_raw_parts(pointer, 0, capacity);
+        let iter = c_range(start.as_ptr(), end.as_ptr());
+        while let Some(key) = from_host(c_next(iter)) {
+            let key = String::from_utf8(key).map_err(|_| "invalid key")?;
+            entries.push((key, from_host(c_value(iter)).unwrap_or_default()));
+        }
     }
+    Ok(entries)
 }
 
-#[derive(Serialize, Deserialize)]
-pub struct InitParams<'a> {
-    contract_address: String,
-    sender: String,
-    #[serde(borrow)]
-    msg: &'a RawValue,
-    sent_funds: u64,
+/// into_c_string hands s to the host, which frees it through deallocate
+fn into_c_string(s: String) -> *mut c_char {
+    // the json written by the contract contains no NUL
+    match CString::new(s) {
+        Ok(s) => s.into_raw(),
+        Err(_) => ptr::null_mut(),
+    }
 }
 
-fn make_error_c_string<E: Into<Error>>(error: E) -> *mut c_char {
-    let error: Error = error.into();
-    CString::new(to_vec(&ContractResult::Error(error.to_string())).unwrap())
-        .unwrap()
-        .into_raw()
-}
+/// Heap is a bump allocator. Every call runs in a new instance, so freed
+/// memory isn't reused.
+struct Heap;
 
-#[no_mangle]
-pub extern "C" fn init_wrapper(params_ptr: *mut c_char) -> *mut c_char {
-    let params: std::vec::Vec<u8>;
+const PAGE_SIZE: usize = 65536;
 
-    unsafe {
-        params = CStr::from_ptr(params_ptr).to_bytes().to_vec();
-    }
+static mut HEAP_END: usize = 0;
 
-    // Catches and formats deserialization errors
-    let params: InitParams = match from_slice(&params) {
-        Ok(params) => params,
-        Err(e) => return make_error_c_string(e),
-    };
+extern "C" {
+    static __heap_base: u8;
+}
 
-    // Catches and formats errors from the logic
-    let res = match init(params) {
-        Ok(msgs) => ContractResult::Msgs(msgs),
-        Err(e) => return make_error_c_string(e),
-    };
+unsafe impl GlobalAlloc for Heap {
+    unsafe fn alloc(&self, layout: Layout) -> *mut u8 {
+        if HEAP_END == 0 {
+            HEAP_END = &__heap_base as *const u8 as usize;
+        }
+        let start = (HEAP_END + layout.align() - 1) & !(layout.align() - 1);
+        let end = match start.checked_add(layout.size()) {
+            Some(end) => end,
+            None => return ptr::null_mut(),
+        };
+        let size = wasm32::memory_size(0) * PAGE_SIZE;
+        if end > size && wasm32::memory_grow(0, (end - size + PAGE_SIZE - 1) / PAGE_SIZE) == usize::MAX {
+            return ptr::null_mut();
+        }
+        HEAP_END = end;
+        start as *mut u8
+    }
 
-    // Catches and formats serialization errors
-    let res = match to_vec(&res) {
-        Ok(res) => res,
-        Err(e) => return make_error_c_string(e),
-    };
+    unsafe fn dealloc(&self, _ptr: *mut u8, _layout: Layout) {}
+}
 
-    // Catches and formats CString errors
-    let res = match CString::new(res) {
-        Ok(res) => res,
-        Err(e) => return make_error_c_string(e),
-    };
+#[global_allocator]
+static HEAP: Heap = Heap;
 
-    res.into_raw()
+#[panic_handler]
+fn panic(_info: &PanicInfo) -> ! {
+    wasm32::unreachable()
 }
 
+/// allocate returns memory for size bytes and the NUL written by the host
 #[no_mangle]
-pub extern "C" fn send_wrapper(params_ptr: *mut c_char) -> *mut c_char {
-    let params: std::vec::Vec<u8>;
+pub extern "C" fn allocate(size: usize) -> *mut c_void {
+    match Layout::from_size_align(size + 1, 1) {
+        Ok(layout) => unsafe { HEAP.alloc(layout) as *mut c_void },
+        Err(_) => ptr::null_mut(),
+    }
+}
 
-    unsafe {
-        params = CStr::from_ptr(params_ptr).to_bytes().to_vec();
+#[no_mangle]
+pub extern "C" fn deallocate(pointer: *mut c_void, size: usize) {
+    if let Ok(layout) = Layout::from_size_align(size + 1, 1) {
+        unsafe { HEAP.dealloc(pointer as *mut u8, layout) }
     }
+}
 
-    // Catches and formats deserialization errors
-    let params: SendParams = match from_slice(&params) {
-        Ok(params) => params,
-        Err(e) => return make_error_c_string(e),
-    };
+fn call(params_ptr: *mut c_char, f: fn(Params) -> Result<Vec<CosmosMsg>, Error>) -> *mut c_char {
+    let params = unsafe { CStr::from_ptr(params_ptr).to_bytes() };
 
-    // Catches and formats errors from the logic
-    let res = match send(params) {
+    // Catches and formats errors from parsing and the logic
+    let res = match Params::parse(params).and_then(f) {
         Ok(msgs) => ContractResult::Msgs(msgs),
-        Err(e) => return make_error_c_string(e),
+        Err(e) => ContractResult::Error(e),
     };
 
-    // Catches and formats serialization errors
-    let res = match to_vec(&res) {
-        Ok(res) => res,
-        Err(e) => return make_error_c_string(e),
-    };
+    into_c_string(res.to_json())
+}
 
-    // Catches and formats CString errors
-    let res = match CString::new(res) {
-        Ok(res) => res,
-        Err(e) => return make_error_c_string(e),
-    };
+#[no_mangle]
+pub extern "C" fn init_wrapper(params_ptr: *mut c_char) -> *mut c_char {
+    call(params_ptr, init)
+}
+
+#[no_mangle]
+pub extern "C" fn send_wrapper(params_ptr: *mut c_char) -> *mut c_char {
+    call(params_ptr, send)
+}
+
+#[no_mangle]
+pub extern "C" fn query(msg_ptr: *mut c_char) -> *mut c_char {
+    let msg = unsafe { CStr::from_ptr(msg_ptr).to_bytes() };
+
+    let mut out = String::new();
+    match json::parse(msg).and_then(|msg| contract::query(&msg)) {
+        Ok(result) => {
+            out.push_str(r#"{"result":"#);
+            out.push_str(&result);
+        }
+        Err(e) => {
+            out.push_str(r#"{"error":"#);
+            json::write_str(&mut out, e);
+        }
+    }
+    out.push('}');
 
-    res.into_raw()
+    into_c_string(out)
 }
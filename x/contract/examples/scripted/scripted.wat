@@ -0,0 +1,63 @@
+;; scripted returns responses prepared in its store, to test how the
+;; messages returned by contracts are dispatched.
+;;
+;; send keeps its message under sent and returns the response stored under
+;; send, or no messages. If a query request is stored under query, send
+;; makes the query and keeps the response under queried. reply keeps its
+;; message under reply.
+(module
+  (import "env" "c_get" (func $c_get (param i32) (result i32)))
+  (import "env" "c_set" (func $c_set (param i32 i32)))
+  (import "env" "c_query" (func $c_query (param i32) (result i32)))
+
+  (memory (export "memory") 1)
+  (global $heap (mut i32) (i32.const 1024))
+
+  (data (i32.const 8) "sent\00")
+  (data (i32.const 16) "send\00")
+  (data (i32.const 24) "reply\00")
+  (data (i32.const 32) "query\00")
+  (data (i32.const 40) "queried\00")
+  (data (i32.const 128) "{\22msgs\22:[]}\00")
+
+  ;; bump allocator, leaving room for the terminating NUL
+  (func $allocate (export "allocate") (param $size i32) (result i32)
+    global.get $heap
+    global.get $heap
+    local.get $size
+    i32.add
+    i32.const 1
+    i32.add
+    global.set $heap)
+
+  (func $init (export "init_wrapper") (param $msg i32) (result i32)
+    i32.const 128)
+
+  (func $send (export "send_wrapper") (param $msg i32) (result i32) (local $res i32)
+    i32.const 8
+    local.get $msg
+    call $c_set
+    i32.const 32
+    call $c_get
+    local.tee $res
+    if
+      i32.const 40
+      local.get $res
+      call $c_query
+      call $c_set
+    end
+    i32.const 16
+    call $c_get
+    local.tee $res
+    i32.eqz
+    if
+      i32.const 128
+      local.set $res
+    end
+    local.get $res)
+
+  (func $reply (export "reply") (param $msg i32) (result i32)
+    i32.const 24
+    local.get $msg
+    call $c_set
+    i32.const 128))
@@ -0,0 +1,172 @@
+package contract
+
+import (
+	"github.com/pkg/errors"
+)
+
+// gasImport is the host function metered code calls to pay for the
+// instructions it is about to execute. It takes the number of instructions
+// and returns 0 once the call ran out of gas.
+const gasImport = "c_gas"
+
+// InjectGasMetering instruments wasm code so that it pays for its execution.
+// Every function entry and every point where control flow can continue
+// (start of a block, loop or if branch, after an end or a conditional branch)
+// is prefixed with a call to c_gas charging the instructions up to the next
+// such point, followed by a trap if the gas meter is exhausted.
+//
+// The gas import is appended to the function imports, so all indices of the
+// functions defined by the module are shifted by one.
+func InjectGasMetering(code []byte) ([]byte, error) {
+	sections, err := readSections(code)
+	if err != nil {
+		return nil, err
+	}
+
+	types, err := parseTypes(findSection(sections, sectionType))
+	if err != nil {
+		return nil, err
+	}
+	gasType := -1
+	for i, t := range types {
+		if string(t.params) == string(valueI32) && string(t.results) == string(valueI32) {
+			gasType = i
+			break
+		}
+	}
+	if gasType < 0 {
+		types = append(types, wasmFuncType{params: []byte{valueI32}, results: []byte{valueI32}})
+		gasType = len(types) - 1
+	}
+
+	imports, err := parseImports(findSection(sections, sectionImport))
+	if err != nil {
+		return nil, err
+	}
+	var funcImports uint32
+	for _, imp := range imports {
+		if imp.kind == externFunc {
+			if imp.name == gasImport {
+				return nil, errors.Errorf("code must not import %s", gasImport)
+			}
+			funcImports++
+		}
+	}
+	imports = append(imports, wasmImport{
+		module: "env",
+		name:   gasImport,
+		kind:   externFunc,
+		desc:   appendU32(nil, uint32(gasType)),
+	})
+	// gasFunc is the index of the gas import, every function index at or
+	// above it moves up by one
+	gasFunc := funcImports
+	shift := func(idx uint32) uint32 {
+		if idx >= gasFunc {
+			return idx + 1
+		}
+		return idx
+	}
+
+	codes, err := parseCode(findSection(sections, sectionCode))
+	if err != nil {
+		return nil, err
+	}
+	for i := range codes {
+		codes[i].body = meterBody(codes[i].body, gasFunc, shift)
+	}
+
+	exports, err := parseExports(findSection(sections, sectionExport))
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range exports {
+		if e.kind == externFunc {
+			exports[i].index = shift(e.index)
+		}
+	}
+
+	var out []wasmSection
+	for _, s := range sections {
+		// the name section refers to the old function indices
+		if s.id == sectionCustom {
+			continue
+		}
+		out = append(out, s)
+	}
+	out = setSection(out, sectionType, encodeTypes(types))
+	out = setSection(out, sectionImport, encodeImports(imports))
+	if len(codes) > 0 {
+		out = setSection(out, sectionCode, encodeCode(codes))
+	}
+	if len(exports) > 0 {
+		out = setSection(out, sectionExport, encodeExports(exports))
+	}
+	if data := findSection(sections, sectionStart); data != nil {
+		r := newWasmReader(data)
+		start := r.u32()
+		if r.err != nil {
+			return nil, errors.Wrap(r.err, "start section")
+		}
+		out = setSection(out, sectionStart, appendU32(nil, shift(start)))
+	}
+	if data := findSection(sections, sectionElement); data != nil {
+		elems, err := parseElements(data)
+		if err != nil {
+			return nil, err
+		}
+		for i := range elems {
+			for j, idx := range elems[i].indices {
+				elems[i].indices[j] = shift(idx)
+			}
+		}
+		out = setSection(out, sectionElement, encodeElements(elems))
+	}
+	return writeSections(out), nil
+}
+
+// meterBody inserts gas charges into a function body and remaps the called
+// functions
+func meterBody(body []instruction, gasFunc uint32, shift func(uint32) uint32) []instruction {
+	out := make([]instruction, 0, len(body)+len(body)/4)
+	charge := func(from int) {
+		// count instructions until the next metering point
+		n := 0
+		for _, ins := range body[from:] {
+			n++
+			if isMeteringPoint(ins.op) {
+				break
+			}
+		}
+		out = append(out,
+			instruction{op: opI32Const, imm: appendI32(nil, int32(n))},
+			instruction{op: opCall, index: gasFunc},
+			instruction{op: opI32Eqz},
+			instruction{op: opIf, imm: []byte{blockTypeEmpty}},
+			instruction{op: opUnreachable},
+			instruction{op: opEnd},
+		)
+	}
+
+	charge(0)
+	for i, ins := range body {
+		if ins.op == opCall || ins.op == opRefFunc {
+			ins.index = shift(ins.index)
+		}
+		out = append(out, ins)
+		if isMeteringPoint(ins.op) && i+1 < len(body) {
+			charge(i + 1)
+		}
+	}
+	return out
+}
+
+// isMeteringPoint returns whether execution may continue at the instruction
+// following op without having passed the previous gas charge
+func isMeteringPoint(op byte) bool {
+	switch op {
+	case opBlock, opLoop, opIf, opElse, opEnd, opBrIf:
+		return true
+	}
+	return false
+}
@@ -1,35 +1,190 @@
 package contract
 
-import sdk "github.com/cosmos/cosmos-sdk/types"
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/store/prefix"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	cmn "github.com/tendermint/tendermint/libs/common"
+)
 
 // GenesisState defines genesis data for the module
 type GenesisState struct {
+	Params    Params     `json:"params"`
+	Codes     []Code     `json:"codes"`
 	Contracts []Contract `json:"contracts"`
+	Sequences Sequences  `json:"sequences"`
+}
+
+// Code is stored code along with its metadata
+type Code struct {
+	ID   CodeID   `json:"id"`
+	Info CodeInfo `json:"info"`
+	// CodeBytes is the code as uploaded, gas metering is injected again
+	// on import
+	CodeBytes []byte `json:"code_bytes"`
+}
+
+// Contract is an instantiated contract with its storage
+type Contract struct {
+	Address sdk.AccAddress         `json:"address"`
+	CodeID  CodeID                 `json:"code_id"`
+	Admin   sdk.AccAddress         `json:"admin"`
+	History []ContractHistoryEntry `json:"history"`
+	State   []Model                `json:"state"`
+}
+
+// Model is a key/value pair of a contract store
+type Model struct {
+	Key   cmn.HexBytes `json:"key"`
+	Value []byte       `json:"value"`
+}
+
+// Sequences are the next code and contract IDs to be handed out
+type Sequences struct {
+	NextCodeID     uint64 `json:"next_code_id"`
+	NextContractID uint64 `json:"next_contract_id"`
 }
 
 // NewGenesisState creates a new genesis state.
-func NewGenesisState() GenesisState {
+func NewGenesisState(params Params) GenesisState {
 	return GenesisState{
+		Params:    params,
+		Codes:     nil,
 		Contracts: nil,
 	}
 }
 
 // DefaultGenesisState returns a default genesis state
-func DefaultGenesisState() GenesisState { return NewGenesisState() }
+func DefaultGenesisState() GenesisState { return NewGenesisState(DefaultParams()) }
 
 // InitGenesis initializes story state from genesis file
 func InitGenesis(ctx sdk.Context, keeper Keeper, data GenesisState) {
+	keeper.SetParams(ctx, data.Params)
+	for _, code := range data.Codes {
+		if err := keeper.setCode(ctx, code.ID, code.Info, code.CodeBytes); err != nil {
+			panic(fmt.Sprintf("code %d: %s", code.ID, err.Error()))
+		}
+	}
+	for _, contract := range data.Contracts {
+		keeper.importContract(ctx, contract)
+	}
+	keeper.setAutoIncrementID(ctx, keyNextCodeID, data.Sequences.NextCodeID)
+	keeper.setAutoIncrementID(ctx, keyNextContractID, data.Sequences.NextContractID)
 }
 
 // ExportGenesis exports the genesis state
 func ExportGenesis(ctx sdk.Context, keeper Keeper) GenesisState {
+	store := ctx.KVStore(keeper.storeKey)
+	var codes []Code
+	for _, info := range keeper.ListCodeInfos(ctx) {
+		codes = append(codes, Code{
+			ID:        info.ID,
+			Info:      info.CodeInfo,
+			CodeBytes: store.Get(KeyOriginalCode(info.ID)),
+		})
+	}
 	return GenesisState{
-//		Contracts: keeper.Contracts(ctx),
+		Params:    keeper.GetParams(ctx),
+		Codes:     codes,
+		Contracts: keeper.exportContracts(ctx),
+		Sequences: Sequences{
+			NextCodeID:     keeper.peekAutoIncrementID(ctx, keyNextCodeID),
+			NextContractID: keeper.peekAutoIncrementID(ctx, keyNextContractID),
+		},
+	}
+}
+
+// exportContracts returns all contracts ordered by address
+func (k Keeper) exportContracts(ctx sdk.Context) []Contract {
+	var contracts []Contract
+	for _, addr := range k.ListContracts(ctx) {
+		codeID, err := k.contractCodeID(ctx, addr)
+		if err != nil {
+			panic(err)
+		}
+		contracts = append(contracts, Contract{
+			Address: addr,
+			CodeID:  codeID,
+			Admin:   k.GetContractAdmin(ctx, addr),
+			History: k.GetContractHistory(ctx, addr),
+			State:   k.ContractState(ctx, addr),
+		})
+	}
+	return contracts
+}
+
+func (k Keeper) importContract(ctx sdk.Context, contract Contract) {
+	store := ctx.KVStore(k.storeKey)
+	k.setContractCode(ctx, contract.Address, contract.CodeID)
+	if !contract.Admin.Empty() {
+		store.Set(KeyContractAdmin(contract.Address), contract.Admin)
+	}
+	for i, entry := range contract.History {
+		k.setHistoryEntry(ctx, contract.Address, uint64(i), entry)
+	}
+	contractStore := prefix.NewStore(store, KeyContractStore(contract.Address))
+	for _, model := range contract.State {
+		contractStore.Set(model.Key, model.Value)
 	}
 }
 
 // ValidateGenesis validates the genesis state data
 func ValidateGenesis(data GenesisState) error {
+	if data.Params.MaxContractGas == 0 {
+		return fmt.Errorf("contract parameter MaxContractGas must be positive")
+	}
+	if data.Params.MaxCodeSize == 0 {
+		return fmt.Errorf("contract parameter MaxCodeSize must be positive")
+	}
+	if data.Params.MaxMemoryPages == 0 || data.Params.MaxMemoryPages > maxWasmMemoryPages {
+		return fmt.Errorf("contract parameter MaxMemoryPages must be between 1 and %d", maxWasmMemoryPages)
+	}
 
+	codes := make(map[CodeID]bool, len(data.Codes))
+	for _, code := range data.Codes {
+		if codes[code.ID] {
+			return fmt.Errorf("duplicate code %d", code.ID)
+		}
+		codes[code.ID] = true
+		if uint64(code.ID) >= data.Sequences.NextCodeID {
+			return fmt.Errorf("code %d is not below the next code ID %d", code.ID, data.Sequences.NextCodeID)
+		}
+		hash := sha256.Sum256(code.CodeBytes)
+		if !bytes.Equal(hash[:], code.Info.CodeHash) {
+			return fmt.Errorf("code %d doesn't match its hash %s", code.ID, code.Info.CodeHash)
+		}
+		if err := ValidateCode(code.CodeBytes); err != nil {
+			return fmt.Errorf("code %d: %s", code.ID, err)
+		}
+	}
+
+	contracts := make(map[string]bool, len(data.Contracts))
+	for _, contract := range data.Contracts {
+		if contract.Address.Empty() {
+			return fmt.Errorf("contract without address")
+		}
+		if contracts[contract.Address.String()] {
+			return fmt.Errorf("duplicate contract %s", contract.Address)
+		}
+		contracts[contract.Address.String()] = true
+		id, ok := contractIDFromAddr(contract.Address)
+		if !ok {
+			return fmt.Errorf("%s is not a contract address", contract.Address)
+		}
+		if id >= data.Sequences.NextContractID {
+			return fmt.Errorf("contract %d is not below the next contract ID %d", id, data.Sequences.NextContractID)
+		}
+		if !codes[contract.CodeID] {
+			return fmt.Errorf("contract %s references unknown code %d", contract.Address, contract.CodeID)
+		}
+		for _, entry := range contract.History {
+			if !codes[entry.CodeID] {
+				return fmt.Errorf("history of contract %s references unknown code %d", contract.Address, entry.CodeID)
+			}
+		}
+	}
 	return nil
 }
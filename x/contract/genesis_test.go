@@ -0,0 +1,68 @@
+package contract
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestValidateGenesis(t *testing.T) {
+	code, err := ReadWasmFromFile("examples/kvstore/build/kvstore.wasm")
+	require.NoError(t, err)
+	hash := sha256.Sum256(code)
+	validCode := Code{ID: 0, Info: CodeInfo{CodeHash: hash[:]}, CodeBytes: code}
+	validContract := Contract{Address: addrFromUint64(0), CodeID: 0}
+
+	genesis := func(codes []Code, contracts []Contract) GenesisState {
+		return GenesisState{
+			Params:    DefaultParams(),
+			Codes:     codes,
+			Contracts: contracts,
+			Sequences: Sequences{NextCodeID: 1, NextContractID: 1},
+		}
+	}
+	badHash := validCode
+	badHash.Info.CodeHash = make([]byte, sha256.Size)
+	unknownCode := validContract
+	unknownCode.CodeID = 1
+	unknownHistory := validContract
+	nextCode := validCode
+	nextCode.ID = 1
+	nextContract := validContract
+	nextContract.Address = addrFromUint64(1)
+	notContract := validContract
+	notContract.Address = sdk.AccAddress(bytes.Repeat([]byte{'C'}, 20))
+	unknownHistory.History = []ContractHistoryEntry{{Operation: OperationInit, CodeID: 3}}
+
+	cases := map[string]struct {
+		state GenesisState
+		valid bool
+	}{
+		"default":            {DefaultGenesisState(), true},
+		"valid":              {genesis([]Code{validCode}, []Contract{validContract}), true},
+		"code hash":          {genesis([]Code{badHash}, nil), false},
+		"duplicate code":     {genesis([]Code{validCode, validCode}, nil), false},
+		"code sequence":      {GenesisState{Params: DefaultParams(), Codes: []Code{validCode}}, false},
+		"unknown code":       {genesis([]Code{validCode}, []Contract{unknownCode}), false},
+		"unknown history":    {genesis([]Code{validCode}, []Contract{unknownHistory}), false},
+		"duplicate contract": {genesis([]Code{validCode}, []Contract{validContract, validContract}), false},
+		"no address":         {genesis([]Code{validCode}, []Contract{{CodeID: 0}}), false},
+		"next code id":       {genesis([]Code{validCode, nextCode}, nil), false},
+		"next contract id":   {genesis([]Code{validCode}, []Contract{validContract, nextContract}), false},
+		"not a contract":     {genesis([]Code{validCode}, []Contract{notContract}), false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateGenesis(tc.state)
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
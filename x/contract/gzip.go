@@ -0,0 +1,61 @@
+package contract
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+
+	"github.com/pkg/errors"
+)
+
+// gzipHeader is the magic number and deflate method of gzip streams
+var gzipHeader = []byte{0x1f, 0x8b, 0x08}
+
+// IsGzip returns whether code is gzip compressed
+func IsGzip(code []byte) bool {
+	return bytes.HasPrefix(code, gzipHeader)
+}
+
+// GzipCode compresses code for upload, code that is compressed already is
+// returned as it is
+func GzipCode(code []byte) ([]byte, error) {
+	if IsGzip(code) {
+		return code, nil
+	}
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(code); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// uncompress decompresses gzip compressed code, reading no more than limit
+// bytes so a small upload can't expand into a huge module. Code that isn't
+// compressed is returned as it is.
+func uncompress(code []byte, limit uint64) ([]byte, error) {
+	if !IsGzip(code) {
+		return code, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(code))
+	if err != nil {
+		return nil, errors.Wrap(err, "gzip")
+	}
+	defer r.Close()
+	r.Multistream(false)
+	uncompressed, err := ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "gzip")
+	}
+	if uint64(len(uncompressed)) > limit {
+		return nil, errors.Errorf("uncompressed code exceeds the limit of %d bytes", limit)
+	}
+	return uncompressed, nil
+}
@@ -20,6 +20,12 @@ func NewHandler(keeper Keeper) sdk.Handler {
 			return handleMsgCreateContract(ctx, keeper, msg)
 		case MsgSendContract:
 			return handleMsgSendContract(ctx, keeper, msg)
+		case MsgMigrateContract:
+			return handleMsgMigrateContract(ctx, keeper, msg)
+		case MsgUpdateAdmin:
+			return handleMsgUpdateAdmin(ctx, keeper, msg)
+		case MsgClearAdmin:
+			return handleMsgClearAdmin(ctx, keeper, msg)
 		default:
 			errMsg := fmt.Sprintf("Unrecognized contract message type: %T", msg)
 			return sdk.ErrUnknownRequest(errMsg).Result()
@@ -57,7 +63,7 @@ func DecodeBech32CodeID(bch string) (CodeID, error) {
 }
 
 func handleMsgStoreCode(ctx sdk.Context, keeper Keeper, msg MsgStoreCode) sdk.Result {
-	id, err := keeper.StoreCode(ctx, msg.WASMByteCode)
+	id, err := keeper.StoreCode(ctx, msg.Sender, msg.WASMByteCode, msg.Source, msg.Builder)
 	if err != nil {
 		return err.Result()
 	}
@@ -65,14 +71,14 @@ func handleMsgStoreCode(ctx sdk.Context, keeper Keeper, msg MsgStoreCode) sdk.Re
 	if e != nil {
 		return sdk.ErrUnknownRequest(e.Error()).Result()
 	}
-	fmt.Printf("contract: %s\n", bch)
+	keeper.Logger(ctx).Debug("stored code", "code-id", bch)
 	res := sdk.Result{Data: []byte(bch)}
 	res.Tags = res.Tags.AppendTag("contract.code-id", bch)
 	return res
 }
 
 func handleMsgCreateContract(ctx sdk.Context, keeper Keeper, msg MsgCreateContract) sdk.Result {
-	id, res := keeper.CreateContract(ctx, msg.Sender, msg.Code, msg.InitMsg, msg.InitFunds)
+	id, res := keeper.CreateContract(ctx, msg.Sender, msg.Admin, msg.Code, msg.InitMsg, msg.InitFunds)
 	if !res.IsOK() {
 		return res
 	}
@@ -83,3 +89,32 @@ func handleMsgCreateContract(ctx sdk.Context, keeper Keeper, msg MsgCreateContra
 func handleMsgSendContract(ctx sdk.Context, keeper Keeper, msg MsgSendContract) sdk.Result {
 	return keeper.SendContract(ctx, msg.Sender, msg.Contract, msg.Msg, msg.Payment)
 }
+
+func handleMsgMigrateContract(ctx sdk.Context, keeper Keeper, msg MsgMigrateContract) sdk.Result {
+	res := keeper.MigrateContract(ctx, msg.Sender, msg.Contract, msg.Code, msg.MigrateMsg)
+	if !res.IsOK() {
+		return res
+	}
+	res.Tags = res.Tags.AppendTag("contract.address", msg.Contract.String())
+	return res
+}
+
+func handleMsgUpdateAdmin(ctx sdk.Context, keeper Keeper, msg MsgUpdateAdmin) sdk.Result {
+	err := keeper.UpdateContractAdmin(ctx, msg.Sender, msg.Contract, msg.NewAdmin)
+	if err != nil {
+		return err.Result()
+	}
+	res := sdk.Result{}
+	res.Tags = res.Tags.AppendTag("contract.address", msg.Contract.String())
+	return res
+}
+
+func handleMsgClearAdmin(ctx sdk.Context, keeper Keeper, msg MsgClearAdmin) sdk.Result {
+	err := keeper.UpdateContractAdmin(ctx, msg.Sender, msg.Contract, nil)
+	if err != nil {
+		return err.Result()
+	}
+	res := sdk.Result{}
+	res.Tags = res.Tags.AppendTag("contract.address", msg.Contract.String())
+	return res
+}
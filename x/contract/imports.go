@@ -2,36 +2,204 @@ package contract
 
 /*
 Imports are exposed to all wasm functions
+
+Strings passed between the contract and the host, including keys and values
+of the contract store, are NUL terminated. A zero pointer stands for a
+missing value, which only the bounds of c_range and results may be. Pointers
+outside of the contract memory, strings without a terminator and allocations
+outside of the memory fail the call.
+
+c_range opens an iterator over the keys in [start, end), c_next returns its
+next key and c_value the value stored under the key last returned by c_next.
+
+Metered code calls c_gas with the number of instructions it is about to run
+and traps once it returns 0. Store access is charged by the gas meter of the
+call as well.
+
+c_balance returns the coins owned by the contract as a json list of
+{"denom", "amount"} objects.
+
+c_query takes a json {"path", "data"} request and returns a json
+{"result", "error"} response. The query is answered by the querier registered
+for the first element of the path, like an abci query of custom/<path>.
 */
 
 // #include <stdlib.h>
 //
 // extern int32_t c_read(void *context);
 // extern void c_write(void *context, int32_t ptr);
+// extern int32_t c_get(void *context, int32_t key);
+// extern void c_set(void *context, int32_t key, int32_t value);
+// extern void c_delete(void *context, int32_t key);
+// extern int32_t c_range(void *context, int32_t start, int32_t end);
+// extern int32_t c_next(void *context, int32_t iter);
+// extern int32_t c_value(void *context, int32_t iter);
+// extern int32_t c_gas(void *context, int32_t units);
+// extern int32_t c_balance(void *context);
+// extern int32_t c_query(void *context, int32_t request);
 import "C"
 
 import (
-	"fmt"
 	"unsafe"
 
 	wasm "github.com/wasmerio/go-ext-wasm/wasmer"
 )
 
-
 //export c_read
 func c_read(context unsafe.Pointer) int32 {
-	data := ReadDB()
-	fmt.Printf("read: %s\n", data)
-	return WasmString(data)
+	env := envFromContext(context)
+	defer env.recoverHostPanic()
+	data := env.ReadDB()
+	env.logger().Debug("contract read", "data", data)
+	return env.WasmString(data)
 }
 
 //export c_write
 func c_write(context unsafe.Pointer, ptr int32) {
+	env := envFromContext(context)
+	defer env.recoverHostPanic()
+	text, ok := readBytes(context, env, ptr, true)
+	if !ok {
+		return
+	}
+	env.logger().Debug("contract write", "data", string(text))
+	env.WriteDB(string(text))
+}
+
+//export c_get
+func c_get(context unsafe.Pointer, key int32) int32 {
+	env := envFromContext(context)
+	defer env.recoverHostPanic()
+	k, ok := readBytes(context, env, key, true)
+	if !ok {
+		return 0
+	}
+	val := env.Get(k)
+	if val == nil {
+		return 0
+	}
+	return env.WasmString(string(val))
+}
+
+//export c_set
+func c_set(context unsafe.Pointer, key int32, value int32) {
+	env := envFromContext(context)
+	defer env.recoverHostPanic()
+	k, ok := readBytes(context, env, key, true)
+	if !ok {
+		return
+	}
+	v, ok := readBytes(context, env, value, true)
+	if !ok {
+		return
+	}
+	env.Set(k, v)
+}
+
+//export c_delete
+func c_delete(context unsafe.Pointer, key int32) {
+	env := envFromContext(context)
+	defer env.recoverHostPanic()
+	k, ok := readBytes(context, env, key, true)
+	if !ok {
+		return
+	}
+	env.Delete(k)
+}
+
+//export c_range
+func c_range(context unsafe.Pointer, start int32, end int32) int32 {
+	env := envFromContext(context)
+	defer env.recoverHostPanic()
+	s, ok := readBytes(context, env, start, false)
+	if !ok {
+		return 0
+	}
+	e, ok := readBytes(context, env, end, false)
+	if !ok {
+		return 0
+	}
+	return env.Range(s, e)
+}
+
+//export c_next
+func c_next(context unsafe.Pointer, iter int32) int32 {
+	env := envFromContext(context)
+	defer env.recoverHostPanic()
+	key, ok := env.Next(iter)
+	if !ok {
+		return 0
+	}
+	return env.WasmString(string(key))
+}
+
+//export c_value
+func c_value(context unsafe.Pointer, iter int32) int32 {
+	env := envFromContext(context)
+	defer env.recoverHostPanic()
+	value := env.Value(iter)
+	if value == nil {
+		return 0
+	}
+	return env.WasmString(string(value))
+}
+
+//export c_gas
+func c_gas(context unsafe.Pointer, units int32) int32 {
+	if envFromContext(context).ConsumeInstructions(units) {
+		return 1
+	}
+	return 0
+}
+
+//export c_balance
+func c_balance(context unsafe.Pointer) int32 {
+	env := envFromContext(context)
+	defer env.recoverHostPanic()
+	return env.WasmString(env.Funds())
+}
+
+//export c_query
+func c_query(context unsafe.Pointer, request int32) int32 {
+	env := envFromContext(context)
+	defer env.recoverHostPanic()
+	req, ok := readBytes(context, env, request, true)
+	if !ok {
+		return 0
+	}
+	return env.WasmString(env.Query(req))
+}
+
+// readBytes copies the string at ptr out of the instance memory. A zero
+// pointer is read as nil, unless a value is required. Invalid pointers fail
+// the call instead of panicking in the host function, readBytes returns
+// false then.
+func readBytes(context unsafe.Pointer, env *Env, ptr int32, required bool) ([]byte, bool) {
+	if ptr == 0 && !required {
+		return nil, true
+	}
 	var instanceContext = wasm.IntoInstanceContext(context)
-	var memory = instanceContext.Memory().Data()
-	text := readString(memory[ptr:])
-	fmt.Printf("writing: %s\n", text)
-	WriteDB(text)
+	str, err := readString(instanceContext.Memory().Data(), ptr)
+	if err != nil {
+		env.fail(err)
+		return nil, false
+	}
+	return []byte(str), true
+}
+
+// hostFunctions are the signatures of the functions contracts may import.
+// c_gas is missing as it is only imported by the injected gas metering.
+var hostFunctions = map[string]wasmFuncType{
+	"c_read":    {results: []byte{valueI32}},
+	"c_write":   {params: []byte{valueI32}},
+	"c_get":     {params: []byte{valueI32}, results: []byte{valueI32}},
+	"c_set":     {params: []byte{valueI32, valueI32}},
+	"c_delete":  {params: []byte{valueI32}},
+	"c_range":   {params: []byte{valueI32, valueI32}, results: []byte{valueI32}},
+	"c_next":    {params: []byte{valueI32}, results: []byte{valueI32}},
+	"c_value":   {params: []byte{valueI32}, results: []byte{valueI32}},
+	"c_balance": {results: []byte{valueI32}},
+	"c_query":   {params: []byte{valueI32}, results: []byte{valueI32}},
 }
 
 func wasmImports() (*wasm.Imports, error) {
@@ -43,5 +211,41 @@ func wasmImports() (*wasm.Imports, error) {
 	if err != nil {
 		return nil, err
 	}
+	imp, err = imp.Append("c_get", c_get, C.c_get)
+	if err != nil {
+		return nil, err
+	}
+	imp, err = imp.Append("c_set", c_set, C.c_set)
+	if err != nil {
+		return nil, err
+	}
+	imp, err = imp.Append("c_delete", c_delete, C.c_delete)
+	if err != nil {
+		return nil, err
+	}
+	imp, err = imp.Append("c_range", c_range, C.c_range)
+	if err != nil {
+		return nil, err
+	}
+	imp, err = imp.Append("c_next", c_next, C.c_next)
+	if err != nil {
+		return nil, err
+	}
+	imp, err = imp.Append("c_value", c_value, C.c_value)
+	if err != nil {
+		return nil, err
+	}
+	imp, err = imp.Append(gasImport, c_gas, C.c_gas)
+	if err != nil {
+		return nil, err
+	}
+	imp, err = imp.Append("c_balance", c_balance, C.c_balance)
+	if err != nil {
+		return nil, err
+	}
+	imp, err = imp.Append("c_query", c_query, C.c_query)
+	if err != nil {
+		return nil, err
+	}
 	return imp, nil
 }
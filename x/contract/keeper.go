@@ -1,15 +1,24 @@
 package contract
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/cosmos/cosmos-sdk/codec"
+	"github.com/cosmos/cosmos-sdk/store/prefix"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/x/auth"
 	"github.com/cosmos/cosmos-sdk/x/bank"
 	"github.com/cosmos/cosmos-sdk/x/delegation"
+	"github.com/cosmos/cosmos-sdk/x/params/subspace"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/libs/log"
 )
 
 // Keeper is the model object for the package contract module
@@ -19,10 +28,38 @@ type Keeper struct {
 	accountKeeper    auth.AccountKeeper
 	bankKeeper       bank.Keeper
 	delegationKeeper delegation.Keeper
+	queryRouter      sdk.QueryRouter
+	paramSpace       subspace.Subspace
+	cache            *ModuleCache
 }
 
-func NewKeeper(storeKey sdk.StoreKey, cdc *codec.Codec, accountKeeper auth.AccountKeeper, bankKeeper bank.Keeper, delegationKeeper delegation.Keeper) Keeper {
-	return Keeper{storeKey: storeKey, cdc: cdc, accountKeeper: accountKeeper, bankKeeper: bankKeeper, delegationKeeper: delegationKeeper}
+// NewKeeper creates a contract keeper. Contracts query other modules through
+// queryRouter. Compiled contract code is kept in cache, which may be nil to
+// compile the code on every call.
+func NewKeeper(storeKey sdk.StoreKey, cdc *codec.Codec, accountKeeper auth.AccountKeeper, bankKeeper bank.Keeper, delegationKeeper delegation.Keeper, queryRouter sdk.QueryRouter, paramSpace subspace.Subspace, cache *ModuleCache) Keeper {
+	return Keeper{storeKey: storeKey, cdc: cdc, accountKeeper: accountKeeper, bankKeeper: bankKeeper, delegationKeeper: delegationKeeper,
+		queryRouter: queryRouter, paramSpace: paramSpace.WithKeyTable(ParamKeyTable()), cache: cache}
+}
+
+// Logger returns a module-specific logger.
+func (k Keeper) Logger(ctx sdk.Context) log.Logger {
+	return ctx.Logger().With("module", "x/contract")
+}
+
+// ModuleCacheMetrics returns the hit counters of the compiled module cache
+func (k Keeper) ModuleCacheMetrics() CacheMetrics {
+	return k.cache.Metrics()
+}
+
+// SetParams sets the contract module's parameters.
+func (k Keeper) SetParams(ctx sdk.Context, params Params) {
+	k.paramSpace.SetParamSet(ctx, &params)
+}
+
+// GetParams gets the contract module's parameters.
+func (k Keeper) GetParams(ctx sdk.Context) (params Params) {
+	k.paramSpace.GetParamSet(ctx, &params)
+	return
 }
 
 var (
@@ -36,16 +73,59 @@ func KeyCode(id CodeID) []byte {
 	return []byte(fmt.Sprintf("d/%x", id))
 }
 
+// KeyOriginalCode is the key of the code as uploaded, without gas metering
+func KeyOriginalCode(id CodeID) []byte {
+	return []byte(fmt.Sprintf("o/%x", id))
+}
+
+// KeyCodeInfo is the key of the CodeInfo stored with the code
+func KeyCodeInfo(id CodeID) []byte {
+	return []byte(fmt.Sprintf("ci/%x", id))
+}
+
+// keyContractCodePrefix is the prefix of the KeyContractCode of all contracts
+var keyContractCodePrefix = []byte("n/")
+
 func KeyContractCode(id sdk.AccAddress) []byte {
-	return []byte(fmt.Sprintf("n/%x", id))
+	return []byte(fmt.Sprintf("%s%x", keyContractCodePrefix, id))
+}
+
+// KeyContractStore is the prefix of all storage owned by a contract
+func KeyContractStore(id sdk.AccAddress) []byte {
+	return []byte(fmt.Sprintf("s/%x/", id))
 }
 
+// contractStateKey is the key within the contract store used by c_read/c_write
+var contractStateKey = []byte("state")
+
 func KeyContractState(id sdk.AccAddress) []byte {
-	return []byte(fmt.Sprintf("s/%x", id))
+	return append(KeyContractStore(id), contractStateKey...)
+}
+
+// KeyContractAdmin is the key of the address allowed to migrate a contract
+func KeyContractAdmin(id sdk.AccAddress) []byte {
+	return []byte(fmt.Sprintf("a/%x", id))
+}
+
+// KeyContractHistory is the prefix of the code and admin changes of a
+// contract
+func KeyContractHistory(id sdk.AccAddress) []byte {
+	return []byte(fmt.Sprintf("h/%x/", id))
+}
+
+// KeyContractHistoryEntry is the key of a single change of a contract, seq
+// is fixed width so entries are ordered by it
+func KeyContractHistoryEntry(id sdk.AccAddress, seq uint64) []byte {
+	return append(KeyContractHistory(id), []byte(fmt.Sprintf("%016x", seq))...)
 }
 
 func KeyCodeHasContract(id CodeID, contract sdk.AccAddress) []byte {
-	return []byte(fmt.Sprintf("cc/%x/%x", id, contract))
+	return append(keyCodeContracts(id), []byte(fmt.Sprintf("%x", contract))...)
+}
+
+// keyCodeContracts is the prefix of the KeyCodeHasContract index of a code
+func keyCodeContracts(id CodeID) []byte {
+	return []byte(fmt.Sprintf("cc/%x/", id))
 }
 
 func (k Keeper) autoIncrementID(ctx sdk.Context, nextIdKey []byte) uint64 {
@@ -60,17 +140,159 @@ func (k Keeper) autoIncrementID(ctx sdk.Context, nextIdKey []byte) uint64 {
 	return id
 }
 
+// peekAutoIncrementID returns the next ID autoIncrementID hands out
+func (k Keeper) peekAutoIncrementID(ctx sdk.Context, nextIdKey []byte) uint64 {
+	var id uint64
+	if bz := ctx.KVStore(k.storeKey).Get(nextIdKey); bz != nil {
+		k.cdc.MustUnmarshalBinaryBare(bz, &id)
+	}
+	return id
+}
+
+func (k Keeper) setAutoIncrementID(ctx sdk.Context, nextIdKey []byte, id uint64) {
+	ctx.KVStore(k.storeKey).Set(nextIdKey, k.cdc.MustMarshalBinaryBare(id))
+}
+
 func (k Keeper) getNewCodeID(ctx sdk.Context) CodeID {
 	return CodeID(k.autoIncrementID(ctx, keyNextCodeID))
 }
 
-func (k Keeper) StoreCode(ctx sdk.Context, byteCode []byte) (CodeID, sdk.Error) {
-	store := ctx.KVStore(k.storeKey)
+// StoreCode stores validated, gas metered code under a new code ID. Gzipped
+// code is decompressed first, source and builder optionally describe how the
+// code was built.
+func (k Keeper) StoreCode(ctx sdk.Context, creator sdk.AccAddress, byteCode []byte, source string, builder string) (CodeID, sdk.Error) {
+	params := k.GetParams(ctx)
+	byteCode, err := uncompress(byteCode, params.MaxCodeSize)
+	if err != nil {
+		return 0, sdk.ErrUnknownRequest(fmt.Sprintf("invalid wasm code: %s", err))
+	}
+	if err := ValidateCodeLimits(byteCode, params); err != nil {
+		return 0, sdk.ErrUnknownRequest(fmt.Sprintf("invalid wasm code: %s", err))
+	}
+	if err := ValidateCode(byteCode); err != nil {
+		return 0, sdk.ErrUnknownRequest(fmt.Sprintf("invalid wasm code: %s", err))
+	}
+	hash := sha256.Sum256(byteCode)
+	info := CodeInfo{
+		CodeHash: hash[:],
+		Creator:  creator,
+		Source:   source,
+		Builder:  builder,
+	}
+
 	id := k.getNewCodeID(ctx)
-	store.Set(KeyCode(id), byteCode)
+	if err := k.setCode(ctx, id, info, byteCode); err != nil {
+		return 0, err
+	}
 	return id, nil
 }
 
+// setCode instruments byteCode with gas metering, limits its memory to
+// MaxMemoryPages and stores it under id. The uploaded code is kept as well,
+// so it can be exported.
+func (k Keeper) setCode(ctx sdk.Context, id CodeID, info CodeInfo, byteCode []byte) sdk.Error {
+	metered, err := InjectGasMetering(byteCode)
+	if err == nil {
+		metered, err = limitMemory(metered, k.GetParams(ctx).MaxMemoryPages)
+	}
+	if err != nil {
+		return sdk.ErrUnknownRequest(fmt.Sprintf("invalid wasm code: %s", err))
+	}
+	store := ctx.KVStore(k.storeKey)
+	store.Set(KeyCode(id), metered)
+	store.Set(KeyOriginalCode(id), byteCode)
+	store.Set(KeyCodeInfo(id), k.cdc.MustMarshalBinaryBare(info))
+	return nil
+}
+
+// GetCodeInfo returns the metadata of the code, or nil if it doesn't exist
+func (k Keeper) GetCodeInfo(ctx sdk.Context, id CodeID) *CodeInfo {
+	bz := ctx.KVStore(k.storeKey).Get(KeyCodeInfo(id))
+	if bz == nil {
+		return nil
+	}
+	var info CodeInfo
+	k.cdc.MustUnmarshalBinaryBare(bz, &info)
+	return &info
+}
+
+// ListCodeInfos returns the metadata of all stored code ordered by code ID
+func (k Keeper) ListCodeInfos(ctx sdk.Context) []CodeInfoResponse {
+	var infos []CodeInfoResponse
+	k.IterateCodeInfos(ctx, func(info CodeInfoResponse) bool {
+		infos = append(infos, info)
+		return false
+	})
+	return infos
+}
+
+// IterateCodeInfos calls cb with the metadata of the stored code ordered by
+// code ID, until cb returns true
+func (k Keeper) IterateCodeInfos(ctx sdk.Context, cb func(CodeInfoResponse) (stop bool)) {
+	next := k.peekAutoIncrementID(ctx, keyNextCodeID)
+	for id := CodeID(0); uint64(id) < next; id++ {
+		if info := k.GetCodeInfo(ctx, id); info != nil && cb(CodeInfoResponse{ID: id, CodeInfo: *info}) {
+			return
+		}
+	}
+}
+
+// ListContracts returns the addresses of all contracts
+func (k Keeper) ListContracts(ctx sdk.Context) []sdk.AccAddress {
+	var contracts []sdk.AccAddress
+	k.IterateContracts(ctx, func(addr sdk.AccAddress) bool {
+		contracts = append(contracts, addr)
+		return false
+	})
+	return contracts
+}
+
+// IterateContracts calls cb with the addresses of all contracts, until cb
+// returns true
+func (k Keeper) IterateContracts(ctx sdk.Context, cb func(sdk.AccAddress) (stop bool)) {
+	iter := sdk.KVStorePrefixIterator(ctx.KVStore(k.storeKey), keyContractCodePrefix)
+	defer iter.Close()
+
+	for ; iter.Valid(); iter.Next() {
+		addr, err := hex.DecodeString(string(iter.Key()[len(keyContractCodePrefix):]))
+		if err != nil {
+			panic(err)
+		}
+		if cb(addr) {
+			return
+		}
+	}
+}
+
+// ListContractsByCode returns the addresses of all contracts created from
+// the code
+func (k Keeper) ListContractsByCode(ctx sdk.Context, id CodeID) []sdk.AccAddress {
+	var contracts []sdk.AccAddress
+	k.IterateContractsByCode(ctx, id, func(addr sdk.AccAddress) bool {
+		contracts = append(contracts, addr)
+		return false
+	})
+	return contracts
+}
+
+// IterateContractsByCode calls cb with the addresses of the contracts created
+// from the code, until cb returns true
+func (k Keeper) IterateContractsByCode(ctx sdk.Context, id CodeID, cb func(sdk.AccAddress) (stop bool)) {
+	prefixStore := prefix.NewStore(ctx.KVStore(k.storeKey), keyCodeContracts(id))
+	iter := prefixStore.Iterator(nil, nil)
+	defer iter.Close()
+
+	for ; iter.Valid(); iter.Next() {
+		addr, err := hex.DecodeString(string(iter.Key()))
+		if err != nil {
+			panic(err)
+		}
+		if cb(addr) {
+			return
+		}
+	}
+}
+
 func (k Keeper) getNewContractId(ctx sdk.Context) sdk.AccAddress {
 	id := k.autoIncrementID(ctx, keyNextContractID)
 	return addrFromUint64(id)
@@ -83,14 +305,54 @@ func addrFromUint64(id uint64) sdk.AccAddress {
 	return addr
 }
 
+// contractIDFromAddr returns the ID a contract address was created from by
+// addrFromUint64, if it is one
+func contractIDFromAddr(addr sdk.AccAddress) (uint64, bool) {
+	if len(addr) != 20 || addr[0] != 'C' {
+		return 0, false
+	}
+	id, n := binary.Uvarint(addr[1:])
+	if n <= 0 || !bytes.Equal(addr, addrFromUint64(id)) {
+		return 0, false
+	}
+	return id, true
+}
+
 type contractMsg struct {
 	ContractAddress sdk.AccAddress  `json:"contract_address"`
 	Sender          sdk.AccAddress  `json:"sender"`
 	Msg             json.RawMessage `json:"msg"`
-	SentFunds       int64           `json:"sent_funds"`
+	// Funds are all coins sent along with the message
+	Funds sdk.Coins `json:"funds"`
+	// SentFunds is the amount sent if it was a single denomination, and
+	// zero otherwise. It is kept for contracts built against the old message
+	// format, which had no funds field.
+	SentFunds int64 `json:"sent_funds"`
+	// Env describes the block the contract runs in
+	Env ContractEnv `json:"env"`
 }
 
-func (k Keeper) CreateContract(ctx sdk.Context, creator sdk.AccAddress, codeId CodeID, initData []byte, coins sdk.Coins) (sdk.AccAddress, sdk.Result) {
+func newContractMsg(env ContractEnv, sender sdk.AccAddress, msg []byte, coins sdk.Coins) contractMsg {
+	if coins == nil {
+		coins = sdk.Coins{}
+	}
+	var amt int64
+	if len(coins) == 1 && coins[0].Amount.IsInt64() {
+		amt = coins[0].Amount.Int64()
+	}
+	return contractMsg{
+		ContractAddress: env.Contract,
+		Sender:          sender,
+		Msg:             msg,
+		Funds:           coins,
+		SentFunds:       amt,
+		Env:             env,
+	}
+}
+
+// CreateContract instantiates a contract from the code. If admin is not
+// empty, it may migrate the contract to other code later on.
+func (k Keeper) CreateContract(ctx sdk.Context, creator sdk.AccAddress, admin sdk.AccAddress, codeId CodeID, initData []byte, coins sdk.Coins) (sdk.AccAddress, sdk.Result) {
 	// Create a contract address
 	addr := k.getNewContractId(ctx)
 
@@ -114,31 +376,28 @@ func (k Keeper) CreateContract(ctx sdk.Context, creator sdk.AccAddress, codeId C
 		return nil, sdk.ErrUnknownRequest("can't find contract code").Result()
 	}
 
-	// Store contract code ID
-	store.Set(KeyContractCode(addr), k.cdc.MustMarshalBinaryBare(codeId))
-	// Store secondary index to look up contracts using a specific CodeID
-	store.Set(KeyCodeHasContract(codeId, addr), []byte{0})
-
-	// TODO: we really need to handle coins, not just one int
-	amt := coins[0].Amount.Int64()
-	msg := contractMsg{
-		ContractAddress: addr,
-		Sender:          creator,
-		Msg:             initData,
-		SentFunds:       amt,
+	k.setContractCode(ctx, addr, codeId)
+	if !admin.Empty() {
+		store.Set(KeyContractAdmin(addr), admin)
 	}
-	txtMsg, stdErr := json.Marshal(msg)
+	k.appendHistory(ctx, addr, ContractHistoryEntry{
+		Operation: OperationInit,
+		CodeID:    codeId,
+		Admin:     admin,
+		Msg:       initData,
+	})
+
+	txtMsg, stdErr := json.Marshal(newContractMsg(k.contractEnv(ctx, addr), creator, initData, coins))
 	if stdErr != nil {
 		return nil, sdk.ErrUnknownRequest(stdErr.Error()).Result()
 	}
 
-	// TODO: setup proper db key to expose for Read/Write
-	res, err := Run(k.cdc, store, KeyContractState(addr), codeBz, "init_wrapper", []interface{}{txtMsg})
+	res, err := k.execute(ctx, codeBz, addr, creator, "init_wrapper", txtMsg)
 	if err != nil {
 		return nil, err.Result()
 	}
 
-	out := k.delegationKeeper.DispatchActions(ctx, addr, res.Msgs)
+	out := k.dispatch(ctx, addr, res)
 	return addr, out
 }
 
@@ -149,41 +408,326 @@ func (k Keeper) SendContract(ctx sdk.Context, sender sdk.AccAddress, contract sd
 		return err.Result()
 	}
 
-	// Retrieve code ID
-	store := ctx.KVStore(k.storeKey)
-	codeIdBz := store.Get(KeyContractCode(contract))
-	var codeId CodeID
-	k.cdc.MustUnmarshalBinaryBare(codeIdBz, &codeId)
+	codeBz, err := k.contractCode(ctx, contract)
+	if err != nil {
+		return err.Result()
+	}
 
-	// Retrieve code
-	codeBz := store.Get(KeyCode(codeId))
+	txtMsg, stdErr := json.Marshal(newContractMsg(k.contractEnv(ctx, contract), sender, msg, coins))
+	if stdErr != nil {
+		return sdk.ErrUnknownRequest(stdErr.Error()).Result()
+	}
+
+	res, err := k.execute(ctx, codeBz, contract, sender, "send_wrapper", txtMsg)
+	if err != nil {
+		return err.Result()
+	}
+
+	out := k.dispatch(ctx, contract, res)
+	return out
+}
+
+// MigrateContract switches the code of contract to codeID and calls the
+// migrate entry point of the new code with msg. Only the admin of the
+// contract may migrate it.
+func (k Keeper) MigrateContract(ctx sdk.Context, caller sdk.AccAddress, contract sdk.AccAddress, codeID CodeID, msg []byte) sdk.Result {
+	if err := k.requireAdmin(ctx, caller, contract); err != nil {
+		return err.Result()
+	}
+	codeBz := ctx.KVStore(k.storeKey).Get(KeyCode(codeID))
 	if len(codeBz) == 0 {
 		return sdk.ErrUnknownRequest("can't find contract code").Result()
 	}
 
-	// TODO: we really need to handle coins, not just one int
-	amt := coins[0].Amount.Int64()
-	cmsg := contractMsg{
-		ContractAddress: contract,
-		Sender:          sender,
-		Msg:             msg,
-		SentFunds:       amt,
-	}
-	txtMsg, stdErr := json.Marshal(cmsg)
+	txtMsg, stdErr := json.Marshal(newContractMsg(k.contractEnv(ctx, contract), caller, msg, nil))
 	if stdErr != nil {
 		return sdk.ErrUnknownRequest(stdErr.Error()).Result()
 	}
-
-	res, err := Run(k.cdc, store, KeyContractState(contract), codeBz, "send_wrapper", []interface{}{txtMsg})
+	res, err := k.execute(ctx, codeBz, contract, caller, "migrate", txtMsg)
 	if err != nil {
 		return err.Result()
 	}
 
-	out := k.delegationKeeper.DispatchActions(ctx, contract, res.Msgs)
-	return out
+	k.setContractCode(ctx, contract, codeID)
+	k.appendHistory(ctx, contract, ContractHistoryEntry{
+		Operation: OperationMigrate,
+		CodeID:    codeID,
+		Admin:     k.GetContractAdmin(ctx, contract),
+		Msg:       msg,
+	})
+
+	return k.dispatch(ctx, contract, res)
+}
+
+// UpdateContractAdmin replaces the admin of contract. An empty newAdmin
+// clears the admin, making the contract immutable.
+func (k Keeper) UpdateContractAdmin(ctx sdk.Context, caller sdk.AccAddress, contract sdk.AccAddress, newAdmin sdk.AccAddress) sdk.Error {
+	if err := k.requireAdmin(ctx, caller, contract); err != nil {
+		return err
+	}
+	store := ctx.KVStore(k.storeKey)
+	op := OperationUpdateAdmin
+	if newAdmin.Empty() {
+		op = OperationClearAdmin
+		store.Delete(KeyContractAdmin(contract))
+	} else {
+		store.Set(KeyContractAdmin(contract), newAdmin)
+	}
+	codeID, err := k.contractCodeID(ctx, contract)
+	if err != nil {
+		return err
+	}
+	k.appendHistory(ctx, contract, ContractHistoryEntry{
+		Operation: op,
+		CodeID:    codeID,
+		Admin:     newAdmin,
+	})
+	return nil
+}
+
+// GetContractAdmin returns the admin of contract, or nil if it has none
+func (k Keeper) GetContractAdmin(ctx sdk.Context, contract sdk.AccAddress) sdk.AccAddress {
+	bz := ctx.KVStore(k.storeKey).Get(KeyContractAdmin(contract))
+	if bz == nil {
+		return nil
+	}
+	return sdk.AccAddress(bz)
+}
+
+// GetContractHistory returns the code and admin changes of contract, oldest
+// first
+func (k Keeper) GetContractHistory(ctx sdk.Context, contract sdk.AccAddress) []ContractHistoryEntry {
+	iter := sdk.KVStorePrefixIterator(ctx.KVStore(k.storeKey), KeyContractHistory(contract))
+	defer iter.Close()
+
+	var history []ContractHistoryEntry
+	for ; iter.Valid(); iter.Next() {
+		var entry ContractHistoryEntry
+		k.cdc.MustUnmarshalBinaryBare(iter.Value(), &entry)
+		history = append(history, entry)
+	}
+	return history
+}
+
+// appendHistory stores entry after the last change of contract, so existing
+// entries don't have to be rewritten
+func (k Keeper) appendHistory(ctx sdk.Context, contract sdk.AccAddress, entry ContractHistoryEntry) {
+	entry.Height = ctx.BlockHeight()
+	k.setHistoryEntry(ctx, contract, k.nextHistorySeq(ctx, contract), entry)
+}
+
+// nextHistorySeq returns the sequence after the last change of contract
+func (k Keeper) nextHistorySeq(ctx sdk.Context, contract sdk.AccAddress) uint64 {
+	iter := sdk.KVStoreReversePrefixIterator(ctx.KVStore(k.storeKey), KeyContractHistory(contract))
+	defer iter.Close()
+
+	if !iter.Valid() {
+		return 0
+	}
+	seq, err := strconv.ParseUint(string(iter.Key()[len(KeyContractHistory(contract)):]), 16, 64)
+	if err != nil {
+		panic(err)
+	}
+	return seq + 1
+}
+
+func (k Keeper) setHistoryEntry(ctx sdk.Context, contract sdk.AccAddress, seq uint64, entry ContractHistoryEntry) {
+	ctx.KVStore(k.storeKey).Set(KeyContractHistoryEntry(contract, seq), k.cdc.MustMarshalBinaryBare(entry))
+}
+
+// requireAdmin fails unless caller is the admin of an existing contract
+func (k Keeper) requireAdmin(ctx sdk.Context, caller sdk.AccAddress, contract sdk.AccAddress) sdk.Error {
+	if _, err := k.contractCodeID(ctx, contract); err != nil {
+		return err
+	}
+	admin := k.GetContractAdmin(ctx, contract)
+	if admin == nil {
+		return sdk.ErrUnauthorized(fmt.Sprintf("contract %s has no admin", contract))
+	}
+	if !admin.Equals(caller) {
+		return sdk.ErrUnauthorized(fmt.Sprintf("%s is not the admin of contract %s", caller, contract))
+	}
+	return nil
+}
+
+// setContractCode sets the code of contract, maintaining the index of
+// contracts by code
+func (k Keeper) setContractCode(ctx sdk.Context, contract sdk.AccAddress, codeID CodeID) {
+	store := ctx.KVStore(k.storeKey)
+	if old, err := k.contractCodeID(ctx, contract); err == nil {
+		store.Delete(KeyCodeHasContract(old, contract))
+	}
+	// Store contract code ID
+	store.Set(KeyContractCode(contract), k.cdc.MustMarshalBinaryBare(codeID))
+	// Store secondary index to look up contracts using a specific CodeID
+	store.Set(KeyCodeHasContract(codeID, contract), []byte{0})
+}
+
+// QuerySmart calls the query entry point of contract with the json encoded
+// msg. The contract has read-only access to its store, and the call is
+// limited to MaxContractGas and the gas left in ctx. The consumed gas is
+// charged to ctx.
+func (k Keeper) QuerySmart(ctx sdk.Context, contract sdk.AccAddress, msg []byte) ([]byte, sdk.Error) {
+	codeBz, err := k.contractCode(ctx, contract)
+	if err != nil {
+		return nil, err
+	}
+
+	params := k.GetParams(ctx)
+	meter := contractGasMeter(ctx, params)
+	queryCtx := ctx.WithGasMeter(meter)
+	env := k.newEnv(queryCtx, NewReadOnlyStore(k.contractStore(queryCtx, contract)), contract, nil, params)
+	res, err := Query(k.cache, env, codeBz, msg)
+	ctx.GasMeter().ConsumeGas(meter.GasConsumedToLimit(), "contract query")
+	return res, err
+}
+
+// contractCodeID returns the ID of the code contract is running
+func (k Keeper) contractCodeID(ctx sdk.Context, contract sdk.AccAddress) (CodeID, sdk.Error) {
+	codeIdBz := ctx.KVStore(k.storeKey).Get(KeyContractCode(contract))
+	if codeIdBz == nil {
+		return 0, sdk.ErrUnknownRequest(fmt.Sprintf("contract %s doesn't exist", contract))
+	}
+	var codeId CodeID
+	k.cdc.MustUnmarshalBinaryBare(codeIdBz, &codeId)
+	return codeId, nil
+}
+
+// contractCode returns the code contract is running
+func (k Keeper) contractCode(ctx sdk.Context, contract sdk.AccAddress) ([]byte, sdk.Error) {
+	codeId, err := k.contractCodeID(ctx, contract)
+	if err != nil {
+		return nil, err
+	}
+
+	codeBz := ctx.KVStore(k.storeKey).Get(KeyCode(codeId))
+	if len(codeBz) == 0 {
+		return nil, sdk.ErrUnknownRequest("can't find contract code")
+	}
+	return codeBz, nil
+}
+
+// execute calls the contract in a cache context with a gas meter of its own,
+// limited by MaxContractGas and the gas left in ctx. The consumed gas is
+// charged to ctx, state changes are only written if the call succeeds.
+func (k Keeper) execute(ctx sdk.Context, code []byte, contract sdk.AccAddress, sender sdk.AccAddress, call string, msg []byte) (*SendResponse, sdk.Error) {
+	params := k.GetParams(ctx)
+	meter := contractGasMeter(ctx, params)
+	cacheCtx, write := ctx.CacheContext()
+	cacheCtx = cacheCtx.WithGasMeter(meter)
+
+	env := k.newEnv(cacheCtx, k.contractStore(cacheCtx, contract), contract, sender, params)
+	res, err := RunCached(k.cdc, k.cache, env, code, call, []interface{}{msg})
+	ctx.GasMeter().ConsumeGas(meter.GasConsumedToLimit(), "contract execution")
+	if err != nil {
+		return nil, err
+	}
+	write()
+	return res, nil
+}
+
+// contractGasMeter returns the gas meter for a contract call, limited by
+// MaxContractGas and the gas left in ctx
+func contractGasMeter(ctx sdk.Context, params Params) sdk.GasMeter {
+	limit := params.MaxContractGas
+	if ctx.GasMeter().Limit() > 0 {
+		left := ctx.GasMeter().Limit() - ctx.GasMeter().GasConsumedToLimit()
+		if left < limit {
+			limit = left
+		}
+	}
+	return sdk.NewGasMeter(limit)
+}
+
+// newEnv creates the environment of a contract call running in ctx
+func (k Keeper) newEnv(ctx sdk.Context, store sdk.KVStore, contract sdk.AccAddress, sender sdk.AccAddress, params Params) *Env {
+	env := NewEnv(ctx, store, contractStateKey, contract, sender)
+	env.InstructionCost = params.InstructionCost
+	env.QueryCost = params.QueryCost
+	env.Balance = func() sdk.Coins {
+		return k.bankKeeper.GetCoins(ctx, contract)
+	}
+	env.Querier = func(path string, data []byte) ([]byte, sdk.Error) {
+		return k.queryRoute(ctx, path, data)
+	}
+	return env
+}
+
+// queryRoute answers a query of a contract with the querier registered for
+// the first element of path in the app QueryRouter. Queries run in a cache
+// context which is discarded, and count towards the call depth of contracts.
+func (k Keeper) queryRoute(ctx sdk.Context, path string, data []byte) (res []byte, err sdk.Error) {
+	depth := callDepth(ctx)
+	if depth >= MaxCallDepth {
+		return nil, sdk.ErrUnknownRequest(fmt.Sprintf("contract call depth exceeds %d", MaxCallDepth))
+	}
+	parts := strings.Split(path, "/")
+	var querier sdk.Querier
+	if k.queryRouter != nil {
+		querier = k.queryRouter.Route(parts[0])
+	}
+	if querier == nil {
+		return nil, sdk.ErrUnknownRequest(fmt.Sprintf("unknown query route %s", parts[0]))
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			// running out of gas aborts the contract call
+			if _, ok := r.(sdk.ErrorOutOfGas); ok {
+				panic(r)
+			}
+			err = sdk.ErrUnknownRequest(fmt.Sprintf("invalid query %s", path))
+		}
+	}()
+	queryCtx, _ := ctx.CacheContext()
+	queryCtx = queryCtx.WithValue(callDepthKey{}, depth+1)
+	return querier(queryCtx, parts[1:], abci.RequestQuery{Path: "custom/" + path, Data: data, Height: ctx.BlockHeight()})
+}
+
+// contractEnv describes the block and the contract to the contract
+func (k Keeper) contractEnv(ctx sdk.Context, contract sdk.AccAddress) ContractEnv {
+	return ContractEnv{
+		Block: BlockInfo{
+			Height:  ctx.BlockHeight(),
+			Time:    ctx.BlockHeader().Time.Unix(),
+			ChainID: ctx.ChainID(),
+		},
+		Contract: contract,
+		Balance:  k.bankKeeper.GetCoins(ctx, contract),
+	}
+}
+
+// contractStore returns the store a contract reads and writes through the
+// host imports
+func (k Keeper) contractStore(ctx sdk.Context, contract sdk.AccAddress) sdk.KVStore {
+	return prefix.NewStore(ctx.KVStore(k.storeKey), KeyContractStore(contract))
 }
 
 func (k Keeper) GetContractState(ctx sdk.Context, contract sdk.AccAddress) []byte {
 	store := ctx.KVStore(k.storeKey)
 	return store.Get(KeyContractState(contract))
 }
+
+// ContractState returns all key/value pairs stored by the contract, ordered by
+// key
+func (k Keeper) ContractState(ctx sdk.Context, contract sdk.AccAddress) []Model {
+	var state []Model
+	k.IterateContractState(ctx, contract, func(model Model) bool {
+		state = append(state, model)
+		return false
+	})
+	return state
+}
+
+// IterateContractState calls cb with the key/value pairs stored by the
+// contract ordered by key, until cb returns true
+func (k Keeper) IterateContractState(ctx sdk.Context, contract sdk.AccAddress, cb func(Model) (stop bool)) {
+	iter := k.contractStore(ctx, contract).Iterator(nil, nil)
+	defer iter.Close()
+
+	for ; iter.Valid(); iter.Next() {
+		if cb(Model{Key: iter.Key(), Value: iter.Value()}) {
+			return
+		}
+	}
+}
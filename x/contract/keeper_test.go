@@ -1,12 +1,17 @@
 package contract
 
 import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/cosmos/cosmos-sdk/baseapp"
 	"github.com/stretchr/testify/require"
 
 	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/crypto"
 	dbm "github.com/tendermint/tendermint/libs/db"
 	"github.com/tendermint/tendermint/libs/log"
 
@@ -38,6 +43,7 @@ func setupTestInput() testInput {
 	auth.RegisterCodec(cdc)
 	bank.RegisterCodec(cdc)
 	delegation.RegisterCodec(cdc)
+	RegisterCodec(cdc)
 	sdk.RegisterCodec(cdc)
 	codec.RegisterCrypto(cdc)
 
@@ -72,9 +78,19 @@ func setupTestInput() testInput {
 
 	router.AddRoute("bank", bank.NewHandler(bk))
 
-	ck := NewKeeper(contCapKey, cdc, ak, bk, dk)
+	cache, err := NewModuleCache(DefaultModuleCacheSize, "")
+	if err != nil {
+		panic(err)
+	}
+	queryRouter := baseapp.NewQueryRouter()
+	ck := NewKeeper(contCapKey, cdc, ak, bk, dk, queryRouter, pk.Subspace(DefaultParamspace), cache)
+
+	router.AddRoute(RouterKey, NewHandler(ck))
+	queryRouter.AddRoute(QuerierRoute, NewQuerier(ck))
+	queryRouter.AddRoute(auth.QuerierRoute, auth.NewQuerier(ak))
 
 	ak.SetParams(ctx, auth.DefaultParams())
+	ck.SetParams(ctx, DefaultParams())
 
 	return testInput{cdc: cdc, ctx: ctx, ak: ak, pk: pk, bk: bk, ck: ck, dk: dk, router: router}
 }
@@ -108,16 +124,15 @@ func TestKeeperRegen(t *testing.T) {
 	require.NoError(t, err)
 	addr2, err := sdk.AccAddressFromBech32(recipient)
 	require.NoError(t, err)
-	input.bk.SetCoins(ctx, addr, sdk.NewCoins(sdk.NewInt64Coin("tree", 10000)))
+	input.bk.SetCoins(ctx, addr, sdk.NewCoins(sdk.NewInt64Coin("earth", 10000)))
 
 	regen, err := ReadWasmFromFile("examples/regen/build/regen.wasm")
 	if err != nil {
 		t.Fatalf("%+v", err)
 	}
 
-	codeID, err := input.ck.StoreCode(input.ctx, regen)
+	codeID, err := input.ck.StoreCode(input.ctx, addr, regen, "", "")
 	require.NoError(t, err)
-	require.NotNil(t, codeID)
 
 	initMsg := regenInitMsg{
 		Verifier:    addr,
@@ -126,23 +141,744 @@ func TestKeeperRegen(t *testing.T) {
 	rawMsg, err := input.cdc.MarshalJSON(initMsg)
 	require.NoError(t, err)
 
-	contract, res := input.ck.CreateContract(input.ctx, addr, codeID, rawMsg, sdk.NewCoins(sdk.NewInt64Coin("tree", 500)))
+	contract, res := input.ck.CreateContract(input.ctx, addr, nil, codeID, rawMsg, sdk.NewCoins(sdk.NewInt64Coin("earth", 500)))
 	require.True(t, res.IsOK())
 	require.NotNil(t, contract)
 
-	require.True(t, input.bk.GetCoins(ctx, addr).IsEqual(sdk.NewCoins(sdk.NewInt64Coin("tree", 9500))))
-	require.True(t, input.bk.GetCoins(ctx, contract).IsEqual(sdk.NewCoins(sdk.NewInt64Coin("tree", 500))))
+	require.True(t, input.bk.GetCoins(ctx, addr).IsEqual(sdk.NewCoins(sdk.NewInt64Coin("earth", 9500))))
+	require.True(t, input.bk.GetCoins(ctx, contract).IsEqual(sdk.NewCoins(sdk.NewInt64Coin("earth", 500))))
 	require.True(t, input.bk.GetCoins(ctx, addr2).IsEqual(sdk.NewCoins()))
 
-	res = input.ck.SendContract(input.ctx, addr, contract, []byte("{}"), sdk.NewCoins(sdk.NewInt64Coin("tree", 5)))
+	res = input.ck.SendContract(input.ctx, addr, contract, []byte("{}"), sdk.NewCoins(sdk.NewInt64Coin("earth", 5)))
 	require.True(t, res.IsOK(), "%v", res)
 
-	require.True(t, input.bk.GetCoins(ctx, addr).IsEqual(sdk.NewCoins(sdk.NewInt64Coin("tree", 9495))))
-	require.True(t, input.bk.GetCoins(ctx, contract).IsEqual(sdk.NewCoins(sdk.NewInt64Coin("tree", 0))))
-	require.True(t, input.bk.GetCoins(ctx, addr2).IsEqual(sdk.NewCoins(sdk.NewInt64Coin("tree", 505))))
+	require.True(t, input.bk.GetCoins(ctx, addr).IsEqual(sdk.NewCoins(sdk.NewInt64Coin("earth", 9495))))
+	require.True(t, input.bk.GetCoins(ctx, contract).IsEqual(sdk.NewCoins(sdk.NewInt64Coin("earth", 0))))
+	require.True(t, input.bk.GetCoins(ctx, addr2).IsEqual(sdk.NewCoins(sdk.NewInt64Coin("earth", 505))))
+
+	// the code was compiled once
+	require.Equal(t, CacheMetrics{Hits: 1, Misses: 1}, input.ck.ModuleCacheMetrics())
+	bz, sdkErr := NewQuerier(input.ck)(ctx, []string{QueryCacheMetrics}, abci.RequestQuery{})
+	require.Nil(t, sdkErr)
+	var metrics CacheMetrics
+	require.NoError(t, json.Unmarshal(bz, &metrics))
+	require.Equal(t, CacheMetrics{Hits: 1, Misses: 1}, metrics)
+}
+
+func TestKeeperRegenFunders(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	verifier, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	beneficiary, err := sdk.AccAddressFromBech32(recipient)
+	require.NoError(t, err)
+	funder := sdk.AccAddress(crypto.AddressHash([]byte("funder")))
+	input.bk.SetCoins(ctx, verifier, sdk.NewCoins(sdk.NewInt64Coin("earth", 10000)))
+	input.bk.SetCoins(ctx, funder, sdk.NewCoins(sdk.NewInt64Coin("earth", 10000)))
+
+	regen, err := ReadWasmFromFile("examples/regen/build/regen.wasm")
+	require.NoError(t, err)
+	codeID, err := input.ck.StoreCode(ctx, verifier, regen, "", "")
+	require.NoError(t, err)
+	initMsg, err := input.cdc.MarshalJSON(regenInitMsg{Verifier: verifier, Beneficiary: beneficiary})
+	require.NoError(t, err)
+	contract, res := input.ck.CreateContract(ctx, verifier, nil, codeID, initMsg, sdk.NewCoins(sdk.NewInt64Coin("earth", 500)))
+	require.True(t, res.IsOK(), "%v", res)
+
+	fund := []byte(`{"fund":{}}`)
+	res = input.ck.SendContract(ctx, funder, contract, fund, sdk.NewCoins(sdk.NewInt64Coin("earth", 200)))
+	require.True(t, res.IsOK(), "%v", res)
+	res = input.ck.SendContract(ctx, funder, contract, fund, sdk.NewCoins(sdk.NewInt64Coin("earth", 100)))
+	require.True(t, res.IsOK(), "%v", res)
+
+	// each funder has a record of its own
+	store := input.ck.contractStore(ctx, contract)
+	require.Equal(t, []byte("500"), store.Get([]byte("funds/"+verifier.String())))
+	require.Equal(t, []byte("300"), store.Get([]byte("funds/"+funder.String())))
+
+	queryFunds := func(addr sdk.AccAddress) string {
+		bz, err := input.ck.QuerySmart(ctx, contract, []byte(fmt.Sprintf(`{"funds":%q}`, addr.String())))
+		require.Nil(t, err)
+		return string(bz)
+	}
+	require.JSONEq(t, `{"amount":500}`, queryFunds(verifier))
+	require.JSONEq(t, `{"amount":300}`, queryFunds(funder))
+	require.JSONEq(t, `{"amount":0}`, queryFunds(beneficiary))
+
+	// only the verifier releases the funds of all funders
+	res = input.ck.SendContract(ctx, funder, contract, []byte("{}"), nil)
+	require.False(t, res.IsOK())
+	res = input.ck.SendContract(ctx, verifier, contract, []byte("{}"), nil)
+	require.True(t, res.IsOK(), "%v", res)
+
+	require.True(t, input.bk.GetCoins(ctx, beneficiary).IsEqual(sdk.NewCoins(sdk.NewInt64Coin("earth", 800))))
+	require.Nil(t, store.Get([]byte("funds/"+verifier.String())))
+	require.Nil(t, store.Get([]byte("funds/"+funder.String())))
+	require.JSONEq(t, `{"amount":0}`, queryFunds(funder))
 }
 
 type regenInitMsg struct {
 	Verifier    sdk.AccAddress `json:"verifier"`
 	Beneficiary sdk.AccAddress `json:"beneficiary"`
 }
+
+func TestKeeperKVStore(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	input.bk.SetCoins(ctx, addr, sdk.NewCoins(sdk.NewInt64Coin("earth", 10000)))
+
+	code, err := ReadWasmFromFile("examples/kvstore/build/kvstore.wasm")
+	require.NoError(t, err)
+	codeID, err := input.ck.StoreCode(ctx, addr, code, "", "")
+	require.NoError(t, err)
+
+	contract, res := input.ck.CreateContract(ctx, addr, nil, codeID, []byte("{}"), sdk.NewCoins(sdk.NewInt64Coin("earth", 1)))
+	require.True(t, res.IsOK(), "%v", res)
+
+	store := input.ck.contractStore(ctx, contract)
+	require.Equal(t, []byte("bar"), store.Get([]byte("foo")))
+	require.Equal(t, []byte("baz"), store.Get([]byte("foz")))
+
+	res = input.ck.SendContract(ctx, addr, contract, []byte("{}"), sdk.NewCoins(sdk.NewInt64Coin("earth", 1)))
+	require.True(t, res.IsOK(), "%v", res)
+
+	require.Equal(t, []byte("bar"), store.Get([]byte("copy")))
+	require.Nil(t, store.Get([]byte("foz")))
+	require.Equal(t, []byte("1"), store.Get([]byte("count")))
+
+	// storage is scoped to the contract
+	other := input.ck.contractStore(ctx, addrFromUint64(99))
+	require.Nil(t, other.Get([]byte("foo")))
+}
+
+func TestKeeperOutOfGas(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	input.bk.SetCoins(ctx, addr, sdk.NewCoins(sdk.NewInt64Coin("earth", 10000)))
+	input.ck.SetParams(ctx, NewParams(1, 1000000, DefaultMaxCodeSize, DefaultQueryCost, DefaultMaxMemoryPages, DefaultMaxTableSize))
+
+	code, err := ReadWasmFromFile("examples/loop/build/loop.wasm")
+	require.NoError(t, err)
+	codeID, err := input.ck.StoreCode(ctx, addr, code, "", "")
+	require.NoError(t, err)
+
+	contract, res := input.ck.CreateContract(ctx, addr, nil, codeID, []byte("{}"), sdk.NewCoins(sdk.NewInt64Coin("earth", 1)))
+	require.True(t, res.IsOK(), "%v", res)
+	store := input.ck.contractStore(ctx, contract)
+	require.Equal(t, []byte("bar"), store.Get([]byte("foo")))
+
+	// the call is stopped at the contract gas limit and its writes are reverted
+	ctx = ctx.WithGasMeter(sdk.NewGasMeter(5000000))
+	res = input.ck.SendContract(ctx, addr, contract, []byte("{}"), sdk.NewCoins(sdk.NewInt64Coin("earth", 1)))
+	require.Equal(t, sdk.CodeOutOfGas, res.Code)
+	require.Equal(t, []byte("bar"), store.Get([]byte("foo")))
+	require.True(t, ctx.GasMeter().GasConsumed() > 1000000)
+
+	// the tx gas limit applies if it is lower
+	ctx = ctx.WithGasMeter(sdk.NewGasMeter(500000))
+	res = input.ck.SendContract(ctx, addr, contract, []byte("{}"), sdk.NewCoins(sdk.NewInt64Coin("earth", 1)))
+	require.Equal(t, sdk.CodeOutOfGas, res.Code)
+	require.True(t, ctx.GasMeter().IsOutOfGas())
+}
+
+func TestKeeperQuerySmart(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	input.bk.SetCoins(ctx, addr, sdk.NewCoins(sdk.NewInt64Coin("earth", 10000)))
+
+	code, err := ReadWasmFromFile("examples/kvstore/build/kvstore.wasm")
+	require.NoError(t, err)
+	codeID, err := input.ck.StoreCode(ctx, addr, code, "", "")
+	require.NoError(t, err)
+	contract, res := input.ck.CreateContract(ctx, addr, nil, codeID, []byte("{}"), sdk.NewCoins(sdk.NewInt64Coin("earth", 1)))
+	require.True(t, res.IsOK(), "%v", res)
+
+	querier := NewQuerier(input.ck)
+	path := []string{QuerySmart, contract.String()}
+	bz, sdkErr := querier(ctx, path, abci.RequestQuery{Data: []byte(`"answer"`)})
+	require.Nil(t, sdkErr)
+	require.JSONEq(t, `{"foo":"bar"}`, string(bz))
+
+	// errors set by the contract are returned
+	_, sdkErr = querier(ctx, path, abci.RequestQuery{Data: []byte(`"question"`)})
+	require.NotNil(t, sdkErr)
+	require.Contains(t, sdkErr.Error(), "not found")
+
+	_, sdkErr = querier(ctx, path, abci.RequestQuery{Data: []byte(`answer`)})
+	require.NotNil(t, sdkErr)
+
+	_, sdkErr = querier(ctx, []string{QuerySmart, addr.String()}, abci.RequestQuery{Data: []byte(`"answer"`)})
+	require.NotNil(t, sdkErr)
+}
+
+func TestKeeperMultiDenomFunds(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	input.bk.SetCoins(ctx, addr, sdk.NewCoins(sdk.NewInt64Coin("earth", 10000), sdk.NewInt64Coin("wind", 500)))
+
+	code, err := ReadWasmFromFile("examples/kvstore/build/kvstore.wasm")
+	require.NoError(t, err)
+	codeID, err := input.ck.StoreCode(ctx, addr, code, "", "")
+	require.NoError(t, err)
+
+	funds := sdk.NewCoins(sdk.NewInt64Coin("earth", 3), sdk.NewInt64Coin("wind", 2))
+	contract, res := input.ck.CreateContract(ctx, addr, nil, codeID, []byte(`{"a":1}`), funds)
+	require.True(t, res.IsOK(), "%v", res)
+	require.True(t, input.bk.GetCoins(ctx, contract).IsEqual(funds))
+
+	// the contract sees all denominations sent and its own balance
+	store := input.ck.contractStore(ctx, contract)
+	var msg contractMsg
+	require.NoError(t, json.Unmarshal(store.Get([]byte("init")), &msg))
+	require.Equal(t, contract, msg.ContractAddress)
+	require.Equal(t, addr, msg.Sender)
+	require.JSONEq(t, `{"a":1}`, string(msg.Msg))
+	require.True(t, msg.Funds.IsEqual(funds))
+	require.Equal(t, int64(0), msg.SentFunds)
+	require.JSONEq(t, `[{"denom":"earth","amount":"3"},{"denom":"wind","amount":"2"}]`, string(store.Get([]byte("balance"))))
+
+	// no funds at all
+	contract, res = input.ck.CreateContract(ctx, addr, nil, codeID, []byte(`{}`), nil)
+	require.True(t, res.IsOK(), "%v", res)
+	store = input.ck.contractStore(ctx, contract)
+	require.JSONEq(t, `[]`, string(store.Get([]byte("balance"))))
+	require.NoError(t, json.Unmarshal(store.Get([]byte("init")), &msg))
+	require.True(t, msg.Funds.Empty())
+}
+
+func TestKeeperCodeInfo(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	input.bk.SetCoins(ctx, addr, sdk.NewCoins(sdk.NewInt64Coin("earth", 10000)))
+
+	kvstore, err := ReadWasmFromFile("examples/kvstore/build/kvstore.wasm")
+	require.NoError(t, err)
+	loop, err := ReadWasmFromFile("examples/loop/build/loop.wasm")
+	require.NoError(t, err)
+
+	kvstoreID, err := input.ck.StoreCode(ctx, addr, kvstore, "https://example.com/kvstore", "example/builder:0.1")
+	require.NoError(t, err)
+	loopID, err := input.ck.StoreCode(ctx, addr, loop, "", "")
+	require.NoError(t, err)
+
+	hash := sha256.Sum256(kvstore)
+	info := input.ck.GetCodeInfo(ctx, kvstoreID)
+	require.NotNil(t, info)
+	require.Equal(t, CodeInfo{
+		CodeHash: hash[:],
+		Creator:  addr,
+		Source:   "https://example.com/kvstore",
+		Builder:  "example/builder:0.1",
+	}, *info)
+	require.Nil(t, input.ck.GetCodeInfo(ctx, loopID+1))
+
+	infos := input.ck.ListCodeInfos(ctx)
+	require.Len(t, infos, 2)
+	require.Equal(t, kvstoreID, infos[0].ID)
+	require.Equal(t, loopID, infos[1].ID)
+
+	var contracts []sdk.AccAddress
+	for i := 0; i < 2; i++ {
+		contract, res := input.ck.CreateContract(ctx, addr, nil, kvstoreID, []byte("{}"), nil)
+		require.True(t, res.IsOK(), "%v", res)
+		contracts = append(contracts, contract)
+	}
+	require.Equal(t, contracts, input.ck.ListContractsByCode(ctx, kvstoreID))
+	require.Empty(t, input.ck.ListContractsByCode(ctx, loopID))
+
+	querier := NewQuerier(input.ck)
+	bz, sdkErr := querier(ctx, []string{QueryCode, "0"}, abci.RequestQuery{})
+	require.Nil(t, sdkErr)
+	require.Contains(t, string(bz), fmt.Sprintf("%X", hash[:]))
+	_, sdkErr = querier(ctx, []string{QueryCode, "7"}, abci.RequestQuery{})
+	require.NotNil(t, sdkErr)
+
+	bz, sdkErr = querier(ctx, []string{QueryListContractsByCode, "0"}, abci.RequestQuery{})
+	require.Nil(t, sdkErr)
+	var addrs []string
+	require.NoError(t, json.Unmarshal(bz, &addrs))
+	require.Equal(t, []string{contracts[0].String(), contracts[1].String()}, addrs)
+}
+
+func TestKeeperStoreCodeValidation(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+	addr := sdk.AccAddress([]byte("sender______________"))
+
+	kvstore, err := ReadWasmFromFile("examples/kvstore/build/kvstore.wasm")
+	require.NoError(t, err)
+
+	_, err = input.ck.StoreCode(ctx, addr, []byte("not wasm"), "", "")
+	require.Error(t, err)
+
+	// a valid module without the contract entry points
+	_, err = input.ck.StoreCode(ctx, addr, []byte("\x00asm\x01\x00\x00\x00"), "", "")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "init_wrapper")
+
+	params := DefaultParams()
+	params.MaxCodeSize = uint64(len(kvstore) - 1)
+	input.ck.SetParams(ctx, params)
+	_, err = input.ck.StoreCode(ctx, addr, kvstore, "", "")
+	require.Error(t, err)
+	input.ck.SetParams(ctx, DefaultParams())
+
+	// code that could execute differently on different validators
+	for name, msg := range map[string]string{
+		"float":  "function 2, instruction 1: floating point instruction 0xb2",
+		"import": "import env.c_random: unknown host function",
+		"memory": "initial memory of 64 pages exceeds the limit of 32 pages",
+		"select": "function 2, instruction 3: floating point instruction 0x1c",
+	} {
+		code, err := ReadWasmFromFile(fmt.Sprintf("examples/invalid/build/%s.wasm", name))
+		require.NoError(t, err)
+		_, err = input.ck.StoreCode(ctx, addr, code, "", "")
+		require.Error(t, err, name)
+		require.Contains(t, err.Error(), msg, name)
+	}
+	require.Empty(t, input.ck.ListCodeInfos(ctx))
+}
+
+func TestKeeperGzipCode(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	input.bk.SetCoins(ctx, addr, sdk.NewCoins(sdk.NewInt64Coin("earth", 10000)))
+
+	kvstore, err := ReadWasmFromFile("examples/kvstore/build/kvstore.wasm")
+	require.NoError(t, err)
+	zipped, err := GzipCode(kvstore)
+	require.NoError(t, err)
+	require.True(t, IsGzip(zipped))
+	require.True(t, len(zipped) < len(kvstore))
+
+	// the code is decompressed before it is hashed and stored
+	codeID, err := input.ck.StoreCode(ctx, addr, zipped, "", "")
+	require.NoError(t, err)
+	hash := sha256.Sum256(kvstore)
+	require.Equal(t, hash[:], []byte(input.ck.GetCodeInfo(ctx, codeID).CodeHash))
+	require.Equal(t, kvstore, ctx.KVStore(input.ck.storeKey).Get(KeyOriginalCode(codeID)))
+
+	contract, res := input.ck.CreateContract(ctx, addr, nil, codeID, []byte("{}"), nil)
+	require.True(t, res.IsOK(), "%v", res)
+
+	querier := NewQuerier(input.ck)
+	bz, sdkErr := querier(ctx, []string{QueryListState}, abci.RequestQuery{})
+	require.Nil(t, sdkErr)
+	require.JSONEq(t, fmt.Sprintf(`["%s"]`, contract), string(bz))
+	bz, sdkErr = querier(ctx, []string{QueryAllContractState, contract.String()}, abci.RequestQuery{})
+	require.Nil(t, sdkErr)
+	var state []Model
+	require.NoError(t, json.Unmarshal(bz, &state))
+	require.Equal(t, input.ck.ContractState(ctx, contract), state)
+	_, sdkErr = querier(ctx, []string{QueryAllContractState, addr.String()}, abci.RequestQuery{})
+	require.NotNil(t, sdkErr)
+
+	// routes without their address or code id fail instead of panicking
+	for _, route := range []string{QueryGetState, QuerySmart, QueryCode, QueryListContractsByCode, QueryContractInfo, QueryContractHistory, QueryAllContractState} {
+		_, sdkErr = querier(ctx, []string{route}, abci.RequestQuery{})
+		require.NotNil(t, sdkErr, route)
+		require.Equal(t, sdk.CodeUnknownRequest, sdkErr.Code(), route)
+	}
+	_, sdkErr = querier(ctx, nil, abci.RequestQuery{})
+	require.NotNil(t, sdkErr)
+
+	// the size limit applies to the decompressed code
+	params := DefaultParams()
+	params.MaxCodeSize = uint64(len(kvstore) - 1)
+	input.ck.SetParams(ctx, params)
+	_, err = input.ck.StoreCode(ctx, addr, zipped, "", "")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "uncompressed code exceeds the limit")
+	input.ck.SetParams(ctx, DefaultParams())
+
+	_, err = input.ck.StoreCode(ctx, addr, append(gzipHeader, "garbage"...), "", "")
+	require.Error(t, err)
+}
+
+func TestKeeperPaginatedQueries(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	kvstore, err := ReadWasmFromFile("examples/kvstore/build/kvstore.wasm")
+	require.NoError(t, err)
+
+	var contracts []string
+	for i := 0; i < 3; i++ {
+		codeID, err := input.ck.StoreCode(ctx, addr, kvstore, "", "")
+		require.NoError(t, err)
+		contract, res := input.ck.CreateContract(ctx, addr, nil, codeID, []byte("{}"), nil)
+		require.True(t, res.IsOK(), "%v", res)
+		contracts = append(contracts, contract.String())
+	}
+
+	querier := NewQuerier(input.ck)
+	query := func(path []string, page, limit int, result interface{}) {
+		data, err := json.Marshal(QueryListParams{Page: page, Limit: limit})
+		require.NoError(t, err)
+		bz, sdkErr := querier(ctx, path, abci.RequestQuery{Data: data})
+		require.Nil(t, sdkErr)
+		require.NoError(t, json.Unmarshal(bz, result))
+	}
+
+	var infos []CodeInfoResponse
+	query([]string{QueryListCode}, 0, 0, &infos)
+	require.Equal(t, input.ck.ListCodeInfos(ctx), infos)
+	query([]string{QueryListCode}, 2, 2, &infos)
+	require.Equal(t, input.ck.ListCodeInfos(ctx)[2:], infos)
+
+	var addrs []string
+	query([]string{QueryListState}, 1, 2, &addrs)
+	require.Len(t, addrs, 2)
+	query([]string{QueryListState}, 3, 1, &addrs)
+	require.Len(t, addrs, 1)
+	query([]string{QueryListState}, 4, 1, &addrs)
+	require.Empty(t, addrs)
+	query([]string{QueryListContractsByCode, "1"}, 1, 10, &addrs)
+	require.Equal(t, []string{contracts[1]}, addrs)
+
+	state := input.ck.ContractState(ctx, input.ck.ListContracts(ctx)[0])
+	require.True(t, len(state) > 3)
+	var page []Model
+	query([]string{QueryAllContractState, input.ck.ListContracts(ctx)[0].String()}, 2, 2, &page)
+	require.Equal(t, state[2:4], page)
+
+	// limits are capped and pages beyond the counter are rejected
+	p, sdkErr := newPager(abci.RequestQuery{Data: []byte(`{"Page":2,"Limit":100000}`)})
+	require.Nil(t, sdkErr)
+	require.Equal(t, pager{start: MaxQueryLimit, end: 2 * MaxQueryLimit}, *p)
+	_, sdkErr = querier(ctx, []string{QueryListCode}, abci.RequestQuery{Data: []byte(fmt.Sprintf(`{"Page":%d,"Limit":2}`, int(^uint(0)>>1)))})
+	require.NotNil(t, sdkErr)
+	_, sdkErr = querier(ctx, []string{QueryListCode}, abci.RequestQuery{Data: []byte("garbage")})
+	require.NotNil(t, sdkErr)
+}
+
+func TestKeeperMemoryLimit(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	input.bk.SetCoins(ctx, addr, sdk.NewCoins(sdk.NewInt64Coin("earth", 10000)))
+
+	code, err := ReadWasmFromFile("examples/memory/build/memory.wasm")
+	require.NoError(t, err)
+	grow := func() []byte {
+		codeID, err := input.ck.StoreCode(ctx, addr, code, "", "")
+		require.NoError(t, err)
+		contract, res := input.ck.CreateContract(ctx, addr, nil, codeID, []byte("{}"), nil)
+		require.True(t, res.IsOK(), "%v", res)
+		res = input.ck.SendContract(ctx, addr, contract, []byte("{}"), nil)
+		require.True(t, res.IsOK(), "%v", res)
+		return input.ck.contractStore(ctx, contract).Get([]byte("grown"))
+	}
+
+	// growing to 33 pages exceeds the default limit of 32 pages
+	require.Equal(t, []byte("no"), grow())
+
+	// the limit applies to code stored afterwards
+	params := DefaultParams()
+	params.MaxMemoryPages = 40
+	input.ck.SetParams(ctx, params)
+	require.Equal(t, []byte("yes"), grow())
+}
+
+func TestKeeperMigrateContract(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	admin, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	other, err := sdk.AccAddressFromBech32(recipient)
+	require.NoError(t, err)
+	input.bk.SetCoins(ctx, admin, sdk.NewCoins(sdk.NewInt64Coin("earth", 10000)))
+
+	loop, err := ReadWasmFromFile("examples/loop/build/loop.wasm")
+	require.NoError(t, err)
+	loopID, err := input.ck.StoreCode(ctx, admin, loop, "", "")
+	require.NoError(t, err)
+	kvstore, err := ReadWasmFromFile("examples/kvstore/build/kvstore.wasm")
+	require.NoError(t, err)
+	kvstoreID, err := input.ck.StoreCode(ctx, admin, kvstore, "", "")
+	require.NoError(t, err)
+
+	contract, res := input.ck.CreateContract(ctx, admin, admin, loopID, []byte("{}"), nil)
+	require.True(t, res.IsOK(), "%v", res)
+	require.Equal(t, admin, input.ck.GetContractAdmin(ctx, contract))
+
+	// only the admin may migrate
+	res = input.ck.MigrateContract(ctx, other, contract, kvstoreID, []byte(`{"v":2}`))
+	require.Equal(t, sdk.CodeUnauthorized, res.Code)
+
+	// the new code must export migrate
+	res = input.ck.MigrateContract(ctx, admin, contract, loopID, []byte(`{}`))
+	require.False(t, res.IsOK())
+
+	res = input.ck.MigrateContract(ctx, admin, contract, kvstoreID, []byte(`{"v":2}`))
+	require.True(t, res.IsOK(), "%v", res)
+	store := input.ck.contractStore(ctx, contract)
+	var msg contractMsg
+	require.NoError(t, json.Unmarshal(store.Get([]byte("migrated")), &msg))
+	require.JSONEq(t, `{"v":2}`, string(msg.Msg))
+	require.Equal(t, admin, msg.Sender)
+	require.Empty(t, input.ck.ListContractsByCode(ctx, loopID))
+	require.Equal(t, []sdk.AccAddress{contract}, input.ck.ListContractsByCode(ctx, kvstoreID))
+
+	// the contract keeps its state and runs the new code
+	res = input.ck.SendContract(ctx, admin, contract, []byte("{}"), nil)
+	require.True(t, res.IsOK(), "%v", res)
+	require.Equal(t, []byte("bar"), store.Get([]byte("copy")))
+
+	require.Nil(t, input.ck.UpdateContractAdmin(ctx, admin, contract, other))
+	res = input.ck.MigrateContract(ctx, admin, contract, kvstoreID, []byte(`{}`))
+	require.Equal(t, sdk.CodeUnauthorized, res.Code)
+	require.NotNil(t, input.ck.UpdateContractAdmin(ctx, admin, contract, nil))
+	require.Nil(t, input.ck.UpdateContractAdmin(ctx, other, contract, nil))
+	require.Nil(t, input.ck.GetContractAdmin(ctx, contract))
+	res = input.ck.MigrateContract(ctx, other, contract, kvstoreID, []byte(`{}`))
+	require.Equal(t, sdk.CodeUnauthorized, res.Code)
+
+	var ops []string
+	for _, entry := range input.ck.GetContractHistory(ctx, contract) {
+		ops = append(ops, entry.Operation)
+	}
+	require.Equal(t, []string{OperationInit, OperationMigrate, OperationUpdateAdmin, OperationClearAdmin}, ops)
+	// every change is stored under its own key
+	kv := ctx.KVStore(input.ck.storeKey)
+	require.NotNil(t, kv.Get(KeyContractHistoryEntry(contract, 3)))
+	require.Nil(t, kv.Get(KeyContractHistoryEntry(contract, 4)))
+	imported := setupTestInput()
+	InitGenesis(imported.ctx, imported.ck, ExportGenesis(ctx, input.ck))
+	require.Equal(t, input.ck.GetContractHistory(ctx, contract), imported.ck.GetContractHistory(imported.ctx, contract))
+
+	querier := NewQuerier(input.ck)
+	bz, sdkErr := querier(ctx, []string{QueryContractInfo, contract.String()}, abci.RequestQuery{})
+	require.Nil(t, sdkErr)
+	var info ContractInfoResponse
+	require.NoError(t, json.Unmarshal(bz, &info))
+	require.Equal(t, contract, info.Address)
+	require.Equal(t, kvstoreID, info.CodeID)
+	require.True(t, info.Admin.Empty())
+
+	bz, sdkErr = querier(ctx, []string{QueryContractHistory, contract.String()}, abci.RequestQuery{})
+	require.Nil(t, sdkErr)
+	var history []ContractHistoryEntry
+	require.NoError(t, json.Unmarshal(bz, &history))
+	require.Len(t, history, 4)
+	require.Equal(t, other, history[2].Admin)
+
+	// contracts created without admin are immutable
+	immutable, res := input.ck.CreateContract(ctx, admin, nil, loopID, []byte("{}"), nil)
+	require.True(t, res.IsOK(), "%v", res)
+	res = input.ck.MigrateContract(ctx, admin, immutable, kvstoreID, []byte(`{}`))
+	require.Equal(t, sdk.CodeUnauthorized, res.Code)
+}
+
+func TestKeeperSubMsgs(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	input.bk.SetCoins(ctx, addr, sdk.NewCoins(sdk.NewInt64Coin("earth", 10000)))
+
+	code, err := ReadWasmFromFile("examples/scripted/build/scripted.wasm")
+	require.NoError(t, err)
+	codeID, err := input.ck.StoreCode(ctx, addr, code, "", "")
+	require.NoError(t, err)
+
+	caller, res := input.ck.CreateContract(ctx, addr, nil, codeID, []byte("{}"), sdk.NewCoins(sdk.NewInt64Coin("earth", 100)))
+	require.True(t, res.IsOK(), "%v", res)
+	callee, res := input.ck.CreateContract(ctx, addr, nil, codeID, []byte("{}"), nil)
+	require.True(t, res.IsOK(), "%v", res)
+	callerStore := input.ck.contractStore(ctx, caller)
+	calleeStore := input.ck.contractStore(ctx, callee)
+
+	// the caller pays the callee through a submessage
+	payment := sdk.NewCoins(sdk.NewInt64Coin("earth", 10))
+	callerStore.Set([]byte("send"), input.cdc.MustMarshalJSON(SendResponse{
+		SubMsgs: []SubMsg{{ID: 7, Msg: MsgSendContract{Sender: caller, Contract: callee, Msg: []byte("{}"), Payment: payment}}},
+	}))
+	// which fails as the callee tries to send more than it has
+	calleeStore.Set([]byte("send"), input.cdc.MustMarshalJSON(SendResponse{
+		Msgs: []sdk.Msg{bank.NewMsgSend(callee, addr, sdk.NewCoins(sdk.NewInt64Coin("earth", 1000)))},
+	}))
+
+	res = input.ck.SendContract(ctx, addr, caller, []byte("{}"), nil)
+	require.True(t, res.IsOK(), "%v", res)
+
+	// the submessage is rolled back and the caller learns about the failure
+	var reply contractReply
+	require.NoError(t, json.Unmarshal(callerStore.Get([]byte("reply")), &reply))
+	require.Equal(t, uint64(7), reply.ID)
+	require.Equal(t, caller, reply.ContractAddress)
+	require.NotEmpty(t, reply.Error)
+	require.Nil(t, calleeStore.Get([]byte("sent")))
+	require.True(t, input.bk.GetCoins(ctx, callee).Empty())
+	require.True(t, input.bk.GetCoins(ctx, caller).IsEqual(sdk.NewCoins(sdk.NewInt64Coin("earth", 100))))
+
+	// once the callee succeeds its changes are kept
+	calleeStore.Delete([]byte("send"))
+	res = input.ck.SendContract(ctx, addr, caller, []byte("{}"), nil)
+	require.True(t, res.IsOK(), "%v", res)
+	require.NoError(t, json.Unmarshal(callerStore.Get([]byte("reply")), &reply))
+	require.Empty(t, reply.Error)
+	require.NotNil(t, calleeStore.Get([]byte("sent")))
+	require.True(t, input.bk.GetCoins(ctx, callee).IsEqual(payment))
+	require.True(t, input.bk.GetCoins(ctx, caller).IsEqual(sdk.NewCoins(sdk.NewInt64Coin("earth", 90))))
+}
+
+func TestKeeperCallDepth(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	input.bk.SetCoins(ctx, addr, sdk.NewCoins(sdk.NewInt64Coin("earth", 10000)))
+
+	code, err := ReadWasmFromFile("examples/scripted/build/scripted.wasm")
+	require.NoError(t, err)
+	codeID, err := input.ck.StoreCode(ctx, addr, code, "", "")
+	require.NoError(t, err)
+	contract, res := input.ck.CreateContract(ctx, addr, nil, codeID, []byte("{}"), nil)
+	require.True(t, res.IsOK(), "%v", res)
+
+	// the contract calls itself over and over
+	store := input.ck.contractStore(ctx, contract)
+	store.Set([]byte("send"), input.cdc.MustMarshalJSON(SendResponse{
+		Msgs: []sdk.Msg{MsgSendContract{Sender: contract, Contract: contract, Msg: []byte("{}")}},
+	}))
+
+	res = input.ck.SendContract(ctx, addr, contract, []byte("{}"), nil)
+	require.False(t, res.IsOK())
+	require.Contains(t, res.Log, "call depth")
+}
+
+func TestKeeperLogAndData(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	input.bk.SetCoins(ctx, addr, sdk.NewCoins(sdk.NewInt64Coin("earth", 10000)))
+
+	code, err := ReadWasmFromFile("examples/scripted/build/scripted.wasm")
+	require.NoError(t, err)
+	codeID, err := input.ck.StoreCode(ctx, addr, code, "", "")
+	require.NoError(t, err)
+	contract, res := input.ck.CreateContract(ctx, addr, nil, codeID, []byte("{}"), nil)
+	require.True(t, res.IsOK(), "%v", res)
+
+	store := input.ck.contractStore(ctx, contract)
+	store.Set([]byte("send"), input.cdc.MustMarshalJSON(SendResponse{
+		Log:  []LogAttribute{{Key: "action", Value: "ping"}},
+		Data: []byte("pong"),
+	}))
+	res = input.ck.SendContract(ctx, addr, contract, []byte("{}"), nil)
+	require.True(t, res.IsOK(), "%v", res)
+	require.Equal(t, []byte("pong"), res.Data)
+	key := fmt.Sprintf("contract.%s.action", contract)
+	require.Contains(t, res.Tags, sdk.MakeTag(key, "ping"))
+
+	// attributes need a key
+	store.Set([]byte("send"), []byte(`{"log":[{"key":"","value":"ping"}]}`))
+	res = input.ck.SendContract(ctx, addr, contract, []byte("{}"), nil)
+	require.False(t, res.IsOK())
+}
+
+func TestKeeperEnvAndQueries(t *testing.T) {
+	input := setupTestInput()
+	blockTime := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+	ctx := input.ctx.WithBlockHeader(abci.Header{ChainID: "test-chain-id", Height: 5, Time: blockTime}).WithBlockHeight(5)
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	input.bk.SetCoins(ctx, addr, sdk.NewCoins(sdk.NewInt64Coin("earth", 10000)))
+
+	code, err := ReadWasmFromFile("examples/scripted/build/scripted.wasm")
+	require.NoError(t, err)
+	codeID, err := input.ck.StoreCode(ctx, addr, code, "", "")
+	require.NoError(t, err)
+	contract, res := input.ck.CreateContract(ctx, addr, nil, codeID, []byte("{}"), nil)
+	require.True(t, res.IsOK(), "%v", res)
+
+	kvCode, err := ReadWasmFromFile("examples/kvstore/build/kvstore.wasm")
+	require.NoError(t, err)
+	kvCodeID, err := input.ck.StoreCode(ctx, addr, kvCode, "", "")
+	require.NoError(t, err)
+	kvContract, res := input.ck.CreateContract(ctx, addr, nil, kvCodeID, []byte("{}"), nil)
+	require.True(t, res.IsOK(), "%v", res)
+
+	// the block and the balance of the contract are passed with the message
+	funds := sdk.NewCoins(sdk.NewInt64Coin("earth", 5))
+	res = input.ck.SendContract(ctx, addr, contract, []byte("{}"), funds)
+	require.True(t, res.IsOK(), "%v", res)
+	store := input.ck.contractStore(ctx, contract)
+	var msg contractMsg
+	require.NoError(t, json.Unmarshal(store.Get([]byte("sent")), &msg))
+	require.Equal(t, ContractEnv{
+		Block:    BlockInfo{Height: 5, Time: blockTime.Unix(), ChainID: "test-chain-id"},
+		Contract: contract,
+		Balance:  funds,
+	}, msg.Env)
+
+	query := func(path string, data string) QueryResponse {
+		store.Set([]byte("query"), []byte(fmt.Sprintf(`{"path":%q,"data":%s}`, path, data)))
+		res := input.ck.SendContract(ctx, addr, contract, []byte("{}"), nil)
+		require.True(t, res.IsOK(), "%v", res)
+		var out QueryResponse
+		require.NoError(t, json.Unmarshal(store.Get([]byte("queried")), &out))
+		return out
+	}
+
+	// smart queries of other contracts
+	out := query(fmt.Sprintf("contract/smart/%s", kvContract), `"answer"`)
+	require.Empty(t, out.Error)
+	require.JSONEq(t, `{"foo":"bar"}`, string(out.Result))
+
+	// accounts of the auth module
+	out = query("acc/account", fmt.Sprintf(`{"Address":%q}`, addr.String()))
+	require.Empty(t, out.Error)
+	var acc auth.BaseAccount
+	require.NoError(t, input.cdc.UnmarshalJSON(out.Result, &acc))
+	require.Equal(t, addr, acc.Address)
+
+	// failures are reported to the contract
+	out = query("unknown/route", `{}`)
+	require.NotEmpty(t, out.Error)
+	out = query("contract/smart", `{}`)
+	require.NotEmpty(t, out.Error)
+
+	// every query is charged
+	store.Delete([]byte("query"))
+	gasCtx := ctx.WithGasMeter(sdk.NewInfiniteGasMeter())
+	res = input.ck.SendContract(gasCtx, addr, contract, []byte("{}"), nil)
+	require.True(t, res.IsOK(), "%v", res)
+	withoutQuery := gasCtx.GasMeter().GasConsumed()
+
+	store.Set([]byte("query"), []byte(`{"path":"unknown","data":{}}`))
+	gasCtx = ctx.WithGasMeter(sdk.NewInfiniteGasMeter())
+	res = input.ck.SendContract(gasCtx, addr, contract, []byte("{}"), nil)
+	require.True(t, res.IsOK(), "%v", res)
+	require.True(t, gasCtx.GasMeter().GasConsumed() >= withoutQuery+DefaultQueryCost)
+}
@@ -35,8 +35,8 @@ func (am AppModuleBasic) GetTxCmd(cdc *codec.Codec) *cobra.Command {
 	return GetTxCmd(cdc)
 }
 
-func (am AppModuleBasic) GetQueryCmd(*codec.Codec) *cobra.Command {
-	return nil
+func (am AppModuleBasic) GetQueryCmd(cdc *codec.Codec) *cobra.Command {
+	return GetQueryCmd(QuerierRoute, cdc)
 }
 
 var _ module.AppModuleBasic = AppModuleBasic{}
@@ -88,8 +88,8 @@ func (am AppModule) RegisterRESTRoutes(context.CLIContext, *mux.Router) {
 // 	return nil
 // }
 
-func (am AppModule) GetQueryCmd(*codec.Codec) *cobra.Command {
-	return nil
+func (am AppModule) GetQueryCmd(cdc *codec.Codec) *cobra.Command {
+	return GetQueryCmd(QuerierRoute, cdc)
 }
 
 func (am AppModule) RegisterInvariants(sdk.InvariantRegistry) {
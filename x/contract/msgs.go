@@ -1,15 +1,26 @@
 package contract
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"net/url"
+
 	sdk "github.com/cosmos/cosmos-sdk/types"
 )
 
 type MsgStoreCode struct {
 	Sender       sdk.AccAddress `json:"sender"`
-	WASMByteCode []byte `json:"wasm_byte_code"`
+	WASMByteCode []byte         `json:"wasm_byte_code"`
+	// Source is an optional URL of the source code
+	Source string `json:"source"`
+	// Builder is an optional docker image the code was built with
+	Builder string `json:"builder"`
 }
 
+// maxBuilderLength is the maximum length of MsgStoreCode.Builder
+const maxBuilderLength = 128
+
 func (msg MsgStoreCode) Route() string {
 	return "contract"
 }
@@ -19,6 +30,25 @@ func (msg MsgStoreCode) Type() string {
 }
 
 func (msg MsgStoreCode) ValidateBasic() sdk.Error {
+	if msg.Sender.Empty() {
+		return sdk.ErrInvalidAddress("missing sender address")
+	}
+	if len(msg.WASMByteCode) == 0 {
+		return sdk.ErrUnknownRequest("missing wasm code")
+	}
+	// the module itself is validated by the keeper, after decompressing it
+	if !bytes.HasPrefix(msg.WASMByteCode, wasmHeader) && !IsGzip(msg.WASMByteCode) {
+		return sdk.ErrUnknownRequest("code is not a wasm module or gzip compressed")
+	}
+	if msg.Source != "" {
+		u, err := url.Parse(msg.Source)
+		if err != nil || !u.IsAbs() || u.Host == "" {
+			return sdk.ErrUnknownRequest("source must be an absolute url")
+		}
+	}
+	if len(msg.Builder) > maxBuilderLength {
+		return sdk.ErrUnknownRequest(fmt.Sprintf("builder longer than %d characters", maxBuilderLength))
+	}
 	return nil
 }
 
@@ -39,6 +69,8 @@ type MsgCreateContract struct {
 	Code      CodeID
 	InitMsg   []byte
 	InitFunds sdk.Coins
+	// Admin may migrate the contract, it is immutable if Admin is empty
+	Admin sdk.AccAddress
 }
 
 func (msg MsgCreateContract) Route() string {
@@ -50,7 +82,16 @@ func (msg MsgCreateContract) Type() string {
 }
 
 func (msg MsgCreateContract) ValidateBasic() sdk.Error {
-    return nil
+	if msg.Sender.Empty() {
+		return sdk.ErrInvalidAddress("missing sender address")
+	}
+	if !msg.InitFunds.IsValid() {
+		return sdk.ErrInvalidCoins(msg.InitFunds.String())
+	}
+	if !json.Valid(msg.InitMsg) {
+		return sdk.ErrUnknownRequest("init msg must be valid json")
+	}
+	return nil
 }
 
 func (msg MsgCreateContract) GetSignBytes() []byte {
@@ -81,6 +122,18 @@ func (msg MsgSendContract) Type() string {
 }
 
 func (msg MsgSendContract) ValidateBasic() sdk.Error {
+	if msg.Sender.Empty() {
+		return sdk.ErrInvalidAddress("missing sender address")
+	}
+	if msg.Contract.Empty() {
+		return sdk.ErrInvalidAddress("missing contract address")
+	}
+	if !msg.Payment.IsValid() {
+		return sdk.ErrInvalidCoins(msg.Payment.String())
+	}
+	if !json.Valid(msg.Msg) {
+		return sdk.ErrUnknownRequest("msg must be valid json")
+	}
 	return nil
 }
 
@@ -96,3 +149,123 @@ func (msg MsgSendContract) GetSigners() []sdk.AccAddress {
 	return []sdk.AccAddress{msg.Sender}
 }
 
+
+// MsgMigrateContract switches a contract to other code and calls its migrate
+// entry point. Sender must be the admin of the contract.
+type MsgMigrateContract struct {
+	Sender     sdk.AccAddress
+	Contract   sdk.AccAddress
+	Code       CodeID
+	MigrateMsg []byte
+}
+
+func (msg MsgMigrateContract) Route() string {
+	return "contract"
+}
+
+func (msg MsgMigrateContract) Type() string {
+	return "migrate"
+}
+
+func (msg MsgMigrateContract) ValidateBasic() sdk.Error {
+	if msg.Sender.Empty() {
+		return sdk.ErrInvalidAddress("missing sender address")
+	}
+	if msg.Contract.Empty() {
+		return sdk.ErrInvalidAddress("missing contract address")
+	}
+	if !json.Valid(msg.MigrateMsg) {
+		return sdk.ErrUnknownRequest("migrate msg must be valid json")
+	}
+	return nil
+}
+
+func (msg MsgMigrateContract) GetSignBytes() []byte {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	return sdk.MustSortJSON(b)
+}
+
+func (msg MsgMigrateContract) GetSigners() []sdk.AccAddress {
+	return []sdk.AccAddress{msg.Sender}
+}
+
+// MsgUpdateAdmin hands the admin rights of a contract to NewAdmin. Sender
+// must be the current admin.
+type MsgUpdateAdmin struct {
+	Sender   sdk.AccAddress
+	Contract sdk.AccAddress
+	NewAdmin sdk.AccAddress
+}
+
+func (msg MsgUpdateAdmin) Route() string {
+	return "contract"
+}
+
+func (msg MsgUpdateAdmin) Type() string {
+	return "update-admin"
+}
+
+func (msg MsgUpdateAdmin) ValidateBasic() sdk.Error {
+	if msg.Sender.Empty() {
+		return sdk.ErrInvalidAddress("missing sender address")
+	}
+	if msg.Contract.Empty() {
+		return sdk.ErrInvalidAddress("missing contract address")
+	}
+	if msg.NewAdmin.Empty() {
+		return sdk.ErrInvalidAddress("missing new admin address")
+	}
+	return nil
+}
+
+func (msg MsgUpdateAdmin) GetSignBytes() []byte {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	return sdk.MustSortJSON(b)
+}
+
+func (msg MsgUpdateAdmin) GetSigners() []sdk.AccAddress {
+	return []sdk.AccAddress{msg.Sender}
+}
+
+// MsgClearAdmin removes the admin of a contract, making it immutable.
+// Sender must be the current admin.
+type MsgClearAdmin struct {
+	Sender   sdk.AccAddress
+	Contract sdk.AccAddress
+}
+
+func (msg MsgClearAdmin) Route() string {
+	return "contract"
+}
+
+func (msg MsgClearAdmin) Type() string {
+	return "clear-admin"
+}
+
+func (msg MsgClearAdmin) ValidateBasic() sdk.Error {
+	if msg.Sender.Empty() {
+		return sdk.ErrInvalidAddress("missing sender address")
+	}
+	if msg.Contract.Empty() {
+		return sdk.ErrInvalidAddress("missing contract address")
+	}
+	return nil
+}
+
+func (msg MsgClearAdmin) GetSignBytes() []byte {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	return sdk.MustSortJSON(b)
+}
+
+func (msg MsgClearAdmin) GetSigners() []sdk.AccAddress {
+	return []sdk.AccAddress{msg.Sender}
+}
@@ -0,0 +1,158 @@
+package contract
+
+import (
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/delegation"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+func TestMsgStoreCodeValidateBasic(t *testing.T) {
+	addr := sdk.AccAddress([]byte("sender______________"))
+	code := []byte("\x00asm\x01\x00\x00\x00")
+
+	cases := map[string]struct {
+		msg   MsgStoreCode
+		valid bool
+	}{
+		"valid":           {MsgStoreCode{Sender: addr, WASMByteCode: code}, true},
+		"with source":     {MsgStoreCode{Sender: addr, WASMByteCode: code, Source: "https://example.com/code", Builder: "example/builder:0.1"}, true},
+		"no sender":       {MsgStoreCode{WASMByteCode: code}, false},
+		"no code":         {MsgStoreCode{Sender: addr}, false},
+		"gzipped":         {MsgStoreCode{Sender: addr, WASMByteCode: append(gzipHeader, code...)}, true},
+		"not wasm":        {MsgStoreCode{Sender: addr, WASMByteCode: []byte("not wasm")}, false},
+		"relative source": {MsgStoreCode{Sender: addr, WASMByteCode: code, Source: "example/code"}, false},
+		"long builder":    {MsgStoreCode{Sender: addr, WASMByteCode: code, Builder: string(make([]byte, maxBuilderLength+1))}, false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.msg.ValidateBasic()
+			if tc.valid {
+				require.Nil(t, err)
+			} else {
+				require.NotNil(t, err)
+			}
+		})
+	}
+}
+
+func TestMsgCreateContractValidateBasic(t *testing.T) {
+	addr := sdk.AccAddress([]byte("sender______________"))
+	coins := sdk.NewCoins(sdk.NewInt64Coin("earth", 10), sdk.NewInt64Coin("wind", 5))
+
+	cases := map[string]struct {
+		msg   MsgCreateContract
+		valid bool
+	}{
+		"valid":         {MsgCreateContract{Sender: addr, InitMsg: []byte("{}"), InitFunds: coins}, true},
+		"no funds":      {MsgCreateContract{Sender: addr, InitMsg: []byte("{}")}, true},
+		"no sender":     {MsgCreateContract{InitMsg: []byte("{}"), InitFunds: coins}, false},
+		"invalid json":  {MsgCreateContract{Sender: addr, InitMsg: []byte("{"), InitFunds: coins}, false},
+		"invalid funds": {MsgCreateContract{Sender: addr, InitMsg: []byte("{}"), InitFunds: sdk.Coins{sdk.NewInt64Coin("wind", 5), sdk.NewInt64Coin("earth", 10)}}, false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.msg.ValidateBasic()
+			if tc.valid {
+				require.Nil(t, err)
+			} else {
+				require.NotNil(t, err)
+			}
+		})
+	}
+}
+
+func TestMsgSendContractValidateBasic(t *testing.T) {
+	addr := sdk.AccAddress([]byte("sender______________"))
+	contract := addrFromUint64(1)
+	coins := sdk.NewCoins(sdk.NewInt64Coin("earth", 10), sdk.NewInt64Coin("wind", 5))
+
+	cases := map[string]struct {
+		msg   MsgSendContract
+		valid bool
+	}{
+		"valid":         {MsgSendContract{Sender: addr, Contract: contract, Msg: []byte("{}"), Payment: coins}, true},
+		"no payment":    {MsgSendContract{Sender: addr, Contract: contract, Msg: []byte("{}")}, true},
+		"no sender":     {MsgSendContract{Contract: contract, Msg: []byte("{}"), Payment: coins}, false},
+		"no contract":   {MsgSendContract{Sender: addr, Msg: []byte("{}"), Payment: coins}, false},
+		"invalid json":  {MsgSendContract{Sender: addr, Contract: contract, Msg: []byte("}"), Payment: coins}, false},
+		"invalid funds": {MsgSendContract{Sender: addr, Contract: contract, Msg: []byte("{}"), Payment: sdk.Coins{sdk.NewInt64Coin("earth", 0)}}, false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.msg.ValidateBasic()
+			if tc.valid {
+				require.Nil(t, err)
+			} else {
+				require.NotNil(t, err)
+			}
+		})
+	}
+}
+
+func TestMsgAdminValidateBasic(t *testing.T) {
+	addr := sdk.AccAddress([]byte("sender______________"))
+	contract := addrFromUint64(1)
+
+	require.Nil(t, MsgMigrateContract{Sender: addr, Contract: contract, MigrateMsg: []byte("{}")}.ValidateBasic())
+	require.NotNil(t, MsgMigrateContract{Sender: addr, MigrateMsg: []byte("{}")}.ValidateBasic())
+	require.NotNil(t, MsgMigrateContract{Sender: addr, Contract: contract}.ValidateBasic())
+
+	require.Nil(t, MsgUpdateAdmin{Sender: addr, Contract: contract, NewAdmin: addr}.ValidateBasic())
+	require.NotNil(t, MsgUpdateAdmin{Sender: addr, Contract: contract}.ValidateBasic())
+
+	require.Nil(t, MsgClearAdmin{Sender: addr, Contract: contract}.ValidateBasic())
+	require.NotNil(t, MsgClearAdmin{Contract: contract}.ValidateBasic())
+}
+
+func TestSendContractCapability(t *testing.T) {
+	addr := sdk.AccAddress([]byte("sender______________"))
+	contract := addrFromUint64(1)
+	cap := SendContractCapability{Contract: contract}
+	require.Equal(t, MsgSendContract{}, cap.MsgType())
+
+	allow, updated, del := cap.Accept(MsgSendContract{Sender: addr, Contract: contract, Msg: []byte("{}")}, abci.Header{})
+	require.True(t, allow)
+	require.Nil(t, updated)
+	require.False(t, del)
+
+	allow, _, _ = cap.Accept(MsgSendContract{Sender: addr, Contract: addrFromUint64(2), Msg: []byte("{}")}, abci.Header{})
+	require.False(t, allow)
+	allow, _, _ = cap.Accept(MsgMigrateContract{Sender: addr, Contract: contract}, abci.Header{})
+	require.False(t, allow)
+	allow, _, _ = cap.Accept(MsgSendContract{Sender: addr, Contract: contract, Msg: []byte("{}"), Payment: sdk.NewCoins(sdk.NewInt64Coin("tree", 1))}, abci.Header{})
+	require.False(t, allow)
+}
+
+func TestSendContractCapabilitySpendLimit(t *testing.T) {
+	addr := sdk.AccAddress([]byte("sender______________"))
+	contract := addrFromUint64(1)
+	pay := func(amount int64) MsgSendContract {
+		return MsgSendContract{Sender: addr, Contract: contract, Msg: []byte("{}"), Payment: sdk.NewCoins(sdk.NewInt64Coin("tree", amount))}
+	}
+	var cap delegation.Capability = SendContractCapability{Contract: contract, SpendLimit: sdk.NewCoins(sdk.NewInt64Coin("tree", 100))}
+
+	allow, updated, del := cap.Accept(pay(60), abci.Header{})
+	require.True(t, allow)
+	require.False(t, del)
+	require.Equal(t, SendContractCapability{Contract: contract, SpendLimit: sdk.NewCoins(sdk.NewInt64Coin("tree", 40))}, updated)
+	cap = updated
+
+	// msgs without payment leave the limit untouched
+	allow, updated, del = cap.Accept(MsgSendContract{Sender: addr, Contract: contract, Msg: []byte("{}")}, abci.Header{})
+	require.True(t, allow)
+	require.Nil(t, updated)
+	require.False(t, del)
+
+	allow, _, _ = cap.Accept(pay(41), abci.Header{})
+	require.False(t, allow)
+	allow, _, _ = cap.Accept(MsgSendContract{Sender: addr, Contract: contract, Msg: []byte("{}"), Payment: sdk.NewCoins(sdk.NewInt64Coin("leaf", 1))}, abci.Header{})
+	require.False(t, allow)
+
+	allow, updated, del = cap.Accept(pay(40), abci.Header{})
+	require.True(t, allow)
+	require.Nil(t, updated)
+	require.True(t, del)
+}
@@ -0,0 +1,106 @@
+package contract
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cosmos/cosmos-sdk/x/params/subspace"
+)
+
+// DefaultParamspace defines the default contract module parameter subspace
+const DefaultParamspace = ModuleName
+
+// Default parameter values
+const (
+	DefaultInstructionCost uint64 = 1
+	DefaultMaxContractGas  uint64 = 50000000
+	DefaultMaxCodeSize     uint64 = 1024 * 1024
+	DefaultQueryCost       uint64 = 1000
+	DefaultMaxMemoryPages  uint64 = 32
+	DefaultMaxTableSize    uint64 = 4096
+)
+
+// Parameter keys
+var (
+	KeyInstructionCost = []byte("InstructionCost")
+	KeyMaxContractGas  = []byte("MaxContractGas")
+	KeyMaxCodeSize     = []byte("MaxCodeSize")
+	KeyQueryCost       = []byte("QueryCost")
+	KeyMaxMemoryPages  = []byte("MaxMemoryPages")
+	KeyMaxTableSize    = []byte("MaxTableSize")
+)
+
+var _ subspace.ParamSet = &Params{}
+
+// Params defines the parameters for the contract module.
+type Params struct {
+	// InstructionCost is the gas charged per executed wasm instruction
+	InstructionCost uint64 `json:"instruction_cost"`
+	// MaxContractGas caps the gas a single contract call may consume
+	MaxContractGas uint64 `json:"max_contract_gas"`
+	// MaxCodeSize is the maximum size in bytes of uploaded code
+	MaxCodeSize uint64 `json:"max_code_size"`
+	// QueryCost is the gas charged for every query made by a contract, on
+	// top of the gas used to answer it
+	QueryCost uint64 `json:"query_cost"`
+	// MaxMemoryPages is the maximum memory of a contract in pages of 64KiB
+	MaxMemoryPages uint64 `json:"max_memory_pages"`
+	// MaxTableSize is the maximum number of elements of the function table
+	MaxTableSize uint64 `json:"max_table_size"`
+}
+
+// NewParams creates a new Params object
+func NewParams(instructionCost, maxContractGas, maxCodeSize, queryCost, maxMemoryPages, maxTableSize uint64) Params {
+	return Params{
+		InstructionCost: instructionCost,
+		MaxContractGas:  maxContractGas,
+		MaxCodeSize:     maxCodeSize,
+		QueryCost:       queryCost,
+		MaxMemoryPages:  maxMemoryPages,
+		MaxTableSize:    maxTableSize,
+	}
+}
+
+// ParamKeyTable for contract module
+func ParamKeyTable() subspace.KeyTable {
+	return subspace.NewKeyTable().RegisterParamSet(&Params{})
+}
+
+// ParamSetPairs implements the ParamSet interface and returns all the key/value pairs
+// pairs of contract module's parameters.
+// nolint
+func (p *Params) ParamSetPairs() subspace.ParamSetPairs {
+	return subspace.ParamSetPairs{
+		{Key: KeyInstructionCost, Value: &p.InstructionCost},
+		{Key: KeyMaxContractGas, Value: &p.MaxContractGas},
+		{Key: KeyMaxCodeSize, Value: &p.MaxCodeSize},
+		{Key: KeyQueryCost, Value: &p.QueryCost},
+		{Key: KeyMaxMemoryPages, Value: &p.MaxMemoryPages},
+		{Key: KeyMaxTableSize, Value: &p.MaxTableSize},
+	}
+}
+
+// DefaultParams returns a default set of parameters.
+func DefaultParams() Params {
+	return Params{
+		InstructionCost: DefaultInstructionCost,
+		MaxContractGas:  DefaultMaxContractGas,
+		MaxCodeSize:     DefaultMaxCodeSize,
+		QueryCost:       DefaultQueryCost,
+		MaxMemoryPages:  DefaultMaxMemoryPages,
+		MaxTableSize:    DefaultMaxTableSize,
+	}
+}
+
+// String implements the stringer interface.
+func (p Params) String() string {
+	var sb strings.Builder
+	sb.WriteString("Params: \n")
+	sb.WriteString(fmt.Sprintf("InstructionCost: %d\n", p.InstructionCost))
+	sb.WriteString(fmt.Sprintf("MaxContractGas: %d\n", p.MaxContractGas))
+	sb.WriteString(fmt.Sprintf("MaxCodeSize: %d\n", p.MaxCodeSize))
+	sb.WriteString(fmt.Sprintf("QueryCost: %d\n", p.QueryCost))
+	sb.WriteString(fmt.Sprintf("MaxMemoryPages: %d\n", p.MaxMemoryPages))
+	sb.WriteString(fmt.Sprintf("MaxTableSize: %d\n", p.MaxTableSize))
+	return sb.String()
+}
@@ -3,6 +3,8 @@ package contract
 import (
 	//"github.com/cosmos/cosmos-sdk/codec"
 	"encoding/json"
+	"fmt"
+	"strconv"
 
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	abci "github.com/tendermint/tendermint/abci/types"
@@ -11,16 +13,108 @@ import (
 const (
 	QueryGetState  = "state"
 	QueryListState = "list"
+	QuerySmart     = "smart"
+	QueryCode      = "code"
+	QueryListCode  = "codes"
+	// QueryListContractsByCode lists the contracts created from a code
+	QueryListContractsByCode = "contracts-by-code"
+	QueryContractInfo        = "contract"
+	QueryContractHistory     = "history"
+	// QueryAllContractState lists all key/value pairs stored by a contract
+	QueryAllContractState = "all-state"
+	// QueryCacheMetrics returns the hit counters of the compiled module cache
+	// of the queried node
+	QueryCacheMetrics = "cache-metrics"
 )
 
+const (
+	// DefaultQueryLimit is the number of results on a page of paginated
+	// queries that don't set a limit
+	DefaultQueryLimit = 100
+	// MaxQueryLimit is the maximum number of results on a page, higher
+	// limits are lowered to it
+	MaxQueryLimit = 1000
+)
+
+// QueryListParams select the page of the list, codes, contracts-by-code and
+// all-state queries. Page starts at one and holds up to Limit results.
+type QueryListParams struct {
+	Page, Limit int
+}
+
+// pager counts the results of an iteration to find the ones on a page
+type pager struct {
+	start, end, n int
+}
+
+// newPager returns a pager for the page in the request data, rejecting pages
+// whose results can't be counted without overflowing
+func newPager(req abci.RequestQuery) (*pager, sdk.Error) {
+	var params QueryListParams
+	if len(req.Data) > 0 {
+		if err := json.Unmarshal(req.Data, &params); err != nil {
+			return nil, sdk.ErrUnknownRequest(fmt.Sprintf("invalid query params: %s", err))
+		}
+	}
+	page, limit := params.Page, params.Limit
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = DefaultQueryLimit
+	}
+	if limit > MaxQueryLimit {
+		limit = MaxQueryLimit
+	}
+	if maxInt := int(^uint(0) >> 1); page > maxInt/limit {
+		return nil, sdk.ErrUnknownRequest(fmt.Sprintf("page %d is out of range", page))
+	}
+	start := (page - 1) * limit
+	return &pager{start: start, end: start + limit}, nil
+}
+
+// next returns whether the next result is on the page and whether the
+// iteration can stop after it
+func (p *pager) next() (onPage bool, stop bool) {
+	p.n++
+	return p.n > p.start, p.n >= p.end
+}
+
 // NewQuerier creates a new querier
 func NewQuerier(keeper Keeper) sdk.Querier {
 	return func(ctx sdk.Context, path []string, req abci.RequestQuery) ([]byte, sdk.Error) {
+		if len(path) == 0 {
+			return nil, sdk.ErrUnknownRequest("unknown data query endpoint")
+		}
+		switch path[0] {
+		case QueryGetState, QuerySmart, QueryCode, QueryListContractsByCode,
+			QueryContractInfo, QueryContractHistory, QueryAllContractState:
+			// these routes take a contract address or code id
+			if len(path) < 2 {
+				return nil, sdk.ErrUnknownRequest(fmt.Sprintf("missing argument of %s query", path[0]))
+			}
+		}
 		switch path[0] {
 		case QueryGetState:
 			return queryContractState(ctx, path[1], req, keeper)
 		case QueryListState:
 			return queryContractList(ctx, req, keeper)
+		case QuerySmart:
+			return queryContractSmart(ctx, path[1], req, keeper)
+		case QueryCode:
+			return queryCode(ctx, path[1], keeper)
+		case QueryListCode:
+			return queryCodeList(ctx, req, keeper)
+		case QueryListContractsByCode:
+			return queryContractsByCode(ctx, path[1], req, keeper)
+		case QueryContractInfo:
+			return queryContractInfo(ctx, path[1], keeper)
+		case QueryContractHistory:
+			return queryContractHistory(ctx, path[1], keeper)
+		case QueryAllContractState:
+			return queryAllContractState(ctx, path[1], req, keeper)
+		case QueryCacheMetrics:
+			return marshalQueryResult(keeper.ModuleCacheMetrics())
 		default:
 			return nil, sdk.ErrUnknownRequest("unknown data query endpoint")
 		}
@@ -36,24 +130,146 @@ func queryContractState(ctx sdk.Context, bech string, req abci.RequestQuery, kee
 	return res, nil
 }
 
+// queryContractSmart passes the json query in the request data to the
+// contract and returns its result
+func queryContractSmart(ctx sdk.Context, bech string, req abci.RequestQuery, keeper Keeper) (res []byte, err sdk.Error) {
+	addr, e := sdk.AccAddressFromBech32(bech)
+	if e != nil {
+		return nil, sdk.ErrUnknownRequest(e.Error())
+	}
+	if !json.Valid(req.Data) {
+		return nil, sdk.ErrUnknownRequest("query data must be valid json")
+	}
+	return keeper.QuerySmart(ctx, addr, req.Data)
+}
+
 func queryContractList(ctx sdk.Context, req abci.RequestQuery, keeper Keeper) (res []byte, err sdk.Error) {
-	var addrs []string
+	p, err := newPager(req)
+	if err != nil {
+		return nil, err
+	}
+	addrs := []string{}
+	keeper.IterateContracts(ctx, func(addr sdk.AccAddress) bool {
+		onPage, stop := p.next()
+		if onPage {
+			addrs = append(addrs, addr.String())
+		}
+		return stop
+	})
+	return marshalQueryResult(addrs)
+}
 
-	var i uint64
-	for true {
-		addr := addrFromUint64(i)
-		i++
-		res = keeper.GetContractState(ctx, addr)
-		if res == nil {
-			break
+func parseCodeID(s string) (CodeID, sdk.Error) {
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, sdk.ErrUnknownRequest(fmt.Sprintf("invalid code id %q", s))
+	}
+	return CodeID(id), nil
+}
+
+func queryCode(ctx sdk.Context, idStr string, keeper Keeper) ([]byte, sdk.Error) {
+	id, err := parseCodeID(idStr)
+	if err != nil {
+		return nil, err
+	}
+	info := keeper.GetCodeInfo(ctx, id)
+	if info == nil {
+		return nil, sdk.ErrUnknownRequest(fmt.Sprintf("code %d doesn't exist", id))
+	}
+	return marshalQueryResult(CodeInfoResponse{ID: id, CodeInfo: *info})
+}
+
+func queryCodeList(ctx sdk.Context, req abci.RequestQuery, keeper Keeper) ([]byte, sdk.Error) {
+	p, err := newPager(req)
+	if err != nil {
+		return nil, err
+	}
+	infos := []CodeInfoResponse{}
+	keeper.IterateCodeInfos(ctx, func(info CodeInfoResponse) bool {
+		onPage, stop := p.next()
+		if onPage {
+			infos = append(infos, info)
+		}
+		return stop
+	})
+	return marshalQueryResult(infos)
+}
+
+func queryContractsByCode(ctx sdk.Context, idStr string, req abci.RequestQuery, keeper Keeper) ([]byte, sdk.Error) {
+	id, err := parseCodeID(idStr)
+	if err != nil {
+		return nil, err
+	}
+	p, err := newPager(req)
+	if err != nil {
+		return nil, err
+	}
+	addrs := []string{}
+	keeper.IterateContractsByCode(ctx, id, func(addr sdk.AccAddress) bool {
+		onPage, stop := p.next()
+		if onPage {
+			addrs = append(addrs, addr.String())
 		}
-		addrs = append(addrs, addr.String())
+		return stop
+	})
+	return marshalQueryResult(addrs)
+}
+
+func queryContractInfo(ctx sdk.Context, bech string, keeper Keeper) ([]byte, sdk.Error) {
+	addr, e := sdk.AccAddressFromBech32(bech)
+	if e != nil {
+		return nil, sdk.ErrUnknownRequest(e.Error())
 	}
+	codeID, err := keeper.contractCodeID(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	return marshalQueryResult(ContractInfoResponse{
+		Address: addr,
+		CodeID:  codeID,
+		Admin:   keeper.GetContractAdmin(ctx, addr),
+	})
+}
+
+func queryContractHistory(ctx sdk.Context, bech string, keeper Keeper) ([]byte, sdk.Error) {
+	addr, e := sdk.AccAddressFromBech32(bech)
+	if e != nil {
+		return nil, sdk.ErrUnknownRequest(e.Error())
+	}
+	history := keeper.GetContractHistory(ctx, addr)
+	if history == nil {
+		return nil, sdk.ErrUnknownRequest(fmt.Sprintf("contract %s doesn't exist", addr))
+	}
+	return marshalQueryResult(history)
+}
 
-	bz, e := json.MarshalIndent(addrs, "", "  ")
+func queryAllContractState(ctx sdk.Context, bech string, req abci.RequestQuery, keeper Keeper) ([]byte, sdk.Error) {
+	addr, e := sdk.AccAddressFromBech32(bech)
 	if e != nil {
-		return nil, sdk.ErrInvalidAddress(e.Error())
+		return nil, sdk.ErrUnknownRequest(e.Error())
+	}
+	if _, err := keeper.contractCodeID(ctx, addr); err != nil {
+		return nil, err
 	}
+	p, err := newPager(req)
+	if err != nil {
+		return nil, err
+	}
+	state := []Model{}
+	keeper.IterateContractState(ctx, addr, func(model Model) bool {
+		onPage, stop := p.next()
+		if onPage {
+			state = append(state, model)
+		}
+		return stop
+	})
+	return marshalQueryResult(state)
+}
 
+func marshalQueryResult(v interface{}) ([]byte, sdk.Error) {
+	bz, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, sdk.ErrInternal(err.Error())
+	}
 	return bz, nil
 }
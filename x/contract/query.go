@@ -0,0 +1,280 @@
+package contract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/cosmos/cosmos-sdk/client"
+	"github.com/cosmos/cosmos-sdk/client/context"
+	"github.com/cosmos/cosmos-sdk/codec"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/spf13/cobra"
+)
+
+// GetQueryCmd returns the cli query commands for this module
+func GetQueryCmd(queryRoute string, cdc *codec.Codec) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   ModuleName,
+		Short: "Querying commands for the contract module",
+	}
+
+	cmd.AddCommand(client.GetCommands(
+		GetCmdQuerySmart(queryRoute, cdc),
+		GetCmdQueryCode(queryRoute, cdc),
+		GetCmdListCode(queryRoute, cdc),
+		GetCmdListContracts(queryRoute, cdc),
+		GetCmdListContractsByCode(queryRoute, cdc),
+		GetCmdQueryContract(queryRoute, cdc),
+		GetCmdQueryContractHistory(queryRoute, cdc),
+		GetCmdQueryContractState(queryRoute, cdc),
+		GetCmdQueryCacheMetrics(queryRoute, cdc),
+	)...)
+
+	return cmd
+}
+
+// GetCmdQuerySmart asks a contract a question through its query entry point
+func GetCmdQuerySmart(queryRoute string, cdc *codec.Codec) *cobra.Command {
+	return &cobra.Command{
+		Use:   "smart [contract_addr_bech32] [json_encoded_query]",
+		Short: "Call the query entry point of a contract",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cliCtx := context.NewCLIContext().WithCodec(cdc)
+
+			addr, err := sdk.AccAddressFromBech32(args[0])
+			if err != nil {
+				return err
+			}
+			query := []byte(args[1])
+			if !json.Valid(query) {
+				return errors.New("query must be valid json")
+			}
+
+			route := fmt.Sprintf("custom/%s/%s/%s", queryRoute, QuerySmart, addr)
+			res, err := cliCtx.QueryWithData(route, query)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(res))
+
+			return nil
+		},
+	}
+}
+
+// GetCmdQueryCode shows the metadata of uploaded code
+func GetCmdQueryCode(queryRoute string, cdc *codec.Codec) *cobra.Command {
+	return &cobra.Command{
+		Use:   "code [code_id_int64]",
+		Short: "Show the metadata of uploaded code",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cliCtx := context.NewCLIContext().WithCodec(cdc)
+
+			route := fmt.Sprintf("custom/%s/%s/%s", queryRoute, QueryCode, args[0])
+			res, err := cliCtx.QueryWithData(route, nil)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(res))
+
+			return nil
+		},
+	}
+}
+
+// GetCmdListCode lists the metadata of all uploaded code
+func GetCmdListCode(queryRoute string, cdc *codec.Codec) *cobra.Command {
+	var page, limit int
+
+	cmd := &cobra.Command{
+		Use:   "list-code",
+		Short: "List all uploaded code",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cliCtx := context.NewCLIContext().WithCodec(cdc)
+
+			route := fmt.Sprintf("custom/%s/%s", queryRoute, QueryListCode)
+			bz, err := json.Marshal(QueryListParams{Page: page, Limit: limit})
+			if err != nil {
+				return err
+			}
+			res, err := cliCtx.QueryWithData(route, bz)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(res))
+
+			return nil
+		},
+	}
+	addPaginationFlags(cmd, &page, &limit)
+	return cmd
+}
+
+// GetCmdListContracts lists the addresses of all contracts
+func GetCmdListContracts(queryRoute string, cdc *codec.Codec) *cobra.Command {
+	var page, limit int
+
+	cmd := &cobra.Command{
+		Use:   "list-contracts",
+		Short: "List all contracts",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cliCtx := context.NewCLIContext().WithCodec(cdc)
+
+			route := fmt.Sprintf("custom/%s/%s", queryRoute, QueryListState)
+			bz, err := json.Marshal(QueryListParams{Page: page, Limit: limit})
+			if err != nil {
+				return err
+			}
+			res, err := cliCtx.QueryWithData(route, bz)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(res))
+
+			return nil
+		},
+	}
+	addPaginationFlags(cmd, &page, &limit)
+	return cmd
+}
+
+// GetCmdListContractsByCode lists the contracts created from a code
+func GetCmdListContractsByCode(queryRoute string, cdc *codec.Codec) *cobra.Command {
+	var page, limit int
+
+	cmd := &cobra.Command{
+		Use:   "list-contracts-by-code [code_id_int64]",
+		Short: "List the contracts created from a code",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cliCtx := context.NewCLIContext().WithCodec(cdc)
+
+			route := fmt.Sprintf("custom/%s/%s/%s", queryRoute, QueryListContractsByCode, args[0])
+			bz, err := json.Marshal(QueryListParams{Page: page, Limit: limit})
+			if err != nil {
+				return err
+			}
+			res, err := cliCtx.QueryWithData(route, bz)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(res))
+
+			return nil
+		},
+	}
+	addPaginationFlags(cmd, &page, &limit)
+	return cmd
+}
+
+// GetCmdQueryContract shows the code and admin of a contract
+func GetCmdQueryContract(queryRoute string, cdc *codec.Codec) *cobra.Command {
+	return &cobra.Command{
+		Use:   "contract [contract_addr_bech32]",
+		Short: "Show the code and admin of a contract",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cliCtx := context.NewCLIContext().WithCodec(cdc)
+
+			route := fmt.Sprintf("custom/%s/%s/%s", queryRoute, QueryContractInfo, args[0])
+			res, err := cliCtx.QueryWithData(route, nil)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(res))
+
+			return nil
+		},
+	}
+}
+
+// GetCmdQueryContractHistory shows the code and admin changes of a contract
+func GetCmdQueryContractHistory(queryRoute string, cdc *codec.Codec) *cobra.Command {
+	return &cobra.Command{
+		Use:   "history [contract_addr_bech32]",
+		Short: "Show the code and admin changes of a contract",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cliCtx := context.NewCLIContext().WithCodec(cdc)
+
+			route := fmt.Sprintf("custom/%s/%s/%s", queryRoute, QueryContractHistory, args[0])
+			res, err := cliCtx.QueryWithData(route, nil)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(res))
+
+			return nil
+		},
+	}
+}
+
+// GetCmdQueryContractState shows all key/value pairs stored by a contract
+func GetCmdQueryContractState(queryRoute string, cdc *codec.Codec) *cobra.Command {
+	var page, limit int
+
+	cmd := &cobra.Command{
+		Use:   "state [contract_addr_bech32]",
+		Short: "Show all key/value pairs stored by a contract",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cliCtx := context.NewCLIContext().WithCodec(cdc)
+
+			route := fmt.Sprintf("custom/%s/%s/%s", queryRoute, QueryAllContractState, args[0])
+			bz, err := json.Marshal(QueryListParams{Page: page, Limit: limit})
+			if err != nil {
+				return err
+			}
+			res, err := cliCtx.QueryWithData(route, bz)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(res))
+
+			return nil
+		},
+	}
+	addPaginationFlags(cmd, &page, &limit)
+	return cmd
+}
+
+func addPaginationFlags(cmd *cobra.Command, page, limit *int) {
+	cmd.Flags().IntVar(page, "page", 1, "the page of results to get")
+	cmd.Flags().IntVar(limit, "limit", DefaultQueryLimit, "the number of results per page")
+}
+
+// GetCmdQueryCacheMetrics shows how often the node found compiled contract
+// modules in its cache
+func GetCmdQueryCacheMetrics(queryRoute string, cdc *codec.Codec) *cobra.Command {
+	return &cobra.Command{
+		Use:   "cache-metrics",
+		Short: "Show the hits and misses of the compiled module cache of the node",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cliCtx := context.NewCLIContext().WithCodec(cdc)
+
+			route := fmt.Sprintf("custom/%s/%s", queryRoute, QueryCacheMetrics)
+			res, err := cliCtx.QueryWithData(route, nil)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(res))
+
+			return nil
+		},
+	}
+}
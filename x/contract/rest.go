@@ -1,16 +1,61 @@
 package contract
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/cosmos/cosmos-sdk/client/context"
+	clientrest "github.com/cosmos/cosmos-sdk/client/rest"
+	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/types/rest"
 	"github.com/gorilla/mux"
 )
 
 func RegisterRoutes(cliCtx context.CLIContext, r *mux.Router) {
 	registerQueryRoutes(cliCtx, r)
+	registerTxRoutes(cliCtx, r)
+}
+
+func registerTxRoutes(cliCtx context.CLIContext, r *mux.Router) {
+	r.HandleFunc(
+		"/contracts/code",
+		storeCodeHandlerFn(cliCtx),
+	).Methods("POST")
+	r.HandleFunc(
+		"/contracts/code/{id}",
+		instantiateContractHandlerFn(cliCtx),
+	).Methods("POST")
+	r.HandleFunc(
+		"/contracts/contract/{addr}",
+		executeContractHandlerFn(cliCtx),
+	).Methods("POST")
+}
+
+// StoreCodeReq defines the properties of a store code request's body, the
+// code may be gzip compressed
+type StoreCodeReq struct {
+	BaseReq      rest.BaseReq `json:"base_req"`
+	WASMByteCode []byte       `json:"wasm_byte_code"`
+	Source       string       `json:"source"`
+	Builder      string       `json:"builder"`
+}
+
+// InstantiateContractReq defines the properties of an instantiate request's
+// body
+type InstantiateContractReq struct {
+	BaseReq   rest.BaseReq `json:"base_req"`
+	InitFunds sdk.Coins    `json:"init_funds"`
+	InitMsg   string       `json:"init_msg"`
+	Admin     string       `json:"admin"`
+}
+
+// ExecuteContractReq defines the properties of an execute request's body
+type ExecuteContractReq struct {
+	BaseReq rest.BaseReq `json:"base_req"`
+	Payment sdk.Coins    `json:"payment"`
+	Msg     string       `json:"msg"`
 }
 
 func registerQueryRoutes(cliCtx context.CLIContext, r *mux.Router) {
@@ -20,7 +65,39 @@ func registerQueryRoutes(cliCtx context.CLIContext, r *mux.Router) {
 	).Methods("GET")
 	r.HandleFunc(
 		"/contracts/list",
-		contractListHandlerFn(cliCtx),
+		listHandlerFn(cliCtx, QueryListState),
+	).Methods("GET")
+	r.HandleFunc(
+		"/contracts/smart/{addr}",
+		contractSmartHandlerFn(cliCtx),
+	).Methods("GET").Queries("query", "{query}")
+	r.HandleFunc(
+		"/contracts/code",
+		listHandlerFn(cliCtx, QueryListCode),
+	).Methods("GET")
+	r.HandleFunc(
+		"/contracts/code/{id}",
+		queryHandlerFn(cliCtx, QueryCode, "id"),
+	).Methods("GET")
+	r.HandleFunc(
+		"/contracts/contract/{addr}",
+		queryHandlerFn(cliCtx, QueryContractInfo, "addr"),
+	).Methods("GET")
+	r.HandleFunc(
+		"/contracts/contract/{addr}/history",
+		queryHandlerFn(cliCtx, QueryContractHistory, "addr"),
+	).Methods("GET")
+	r.HandleFunc(
+		"/contracts/contract/{addr}/state",
+		listHandlerFn(cliCtx, QueryAllContractState, "addr"),
+	).Methods("GET")
+	r.HandleFunc(
+		"/contracts/code/{id}/contracts",
+		listHandlerFn(cliCtx, QueryListContractsByCode, "id"),
+	).Methods("GET")
+	r.HandleFunc(
+		"/contracts/cache-metrics",
+		queryHandlerFn(cliCtx, QueryCacheMetrics),
 	).Methods("GET")
 }
 
@@ -29,7 +106,6 @@ func contractStateHandlerFn(cliContext context.CLIContext) func(http.ResponseWri
 		vars := mux.Vars(r)
 		addr := vars["addr"]
 		route := fmt.Sprintf("custom/%s/%s/%s", "contract", "state", addr)
-		fmt.Println(route)
 
 		res, err := cliContext.QueryWithData(route, nil)
 		if err != nil {
@@ -41,10 +117,37 @@ func contractStateHandlerFn(cliContext context.CLIContext) func(http.ResponseWri
 	}
 }
 
-func contractListHandlerFn(cliContext context.CLIContext) func(http.ResponseWriter, *http.Request) {
+// contractSmartHandlerFn passes the json encoded query parameter to the query
+// entry point of the contract
+func contractSmartHandlerFn(cliContext context.CLIContext) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		route := fmt.Sprintf("custom/contract/list/abc")
-		fmt.Println(route)
+		vars := mux.Vars(r)
+		addr := vars["addr"]
+		query := []byte(vars["query"])
+		if !json.Valid(query) {
+			rest.WriteErrorResponse(w, http.StatusBadRequest, "query must be valid json")
+			return
+		}
+		route := fmt.Sprintf("custom/%s/%s/%s", "contract", QuerySmart, addr)
+
+		res, err := cliContext.QueryWithData(route, query)
+		if err != nil {
+			rest.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		rest.PostProcessResponse(w, cliContext, res)
+	}
+}
+
+// queryHandlerFn forwards the request to the contract querier, appending the
+// given route variables to the query path
+func queryHandlerFn(cliContext context.CLIContext, query string, vars ...string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		route := fmt.Sprintf("custom/%s/%s", "contract", query)
+		for _, v := range vars {
+			route += "/" + mux.Vars(r)[v]
+		}
 
 		res, err := cliContext.QueryWithData(route, nil)
 		if err != nil {
@@ -55,3 +158,156 @@ func contractListHandlerFn(cliContext context.CLIContext) func(http.ResponseWrit
 		rest.PostProcessResponse(w, cliContext, res)
 	}
 }
+
+// listHandlerFn is queryHandlerFn for paginated queries, passing the page and
+// limit of the request on
+func listHandlerFn(cliContext context.CLIContext, query string, vars ...string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		route := fmt.Sprintf("custom/%s/%s", "contract", query)
+		for _, v := range vars {
+			route += "/" + mux.Vars(r)[v]
+		}
+
+		_, page, limit, err := rest.ParseHTTPArgsWithLimit(r, 0)
+		if err != nil {
+			rest.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		bz, err := json.Marshal(QueryListParams{Page: page, Limit: limit})
+		if err != nil {
+			rest.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		res, err := cliContext.QueryWithData(route, bz)
+		if err != nil {
+			rest.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		rest.PostProcessResponse(w, cliContext, res)
+	}
+}
+
+func storeCodeHandlerFn(cliCtx context.CLIContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req StoreCodeReq
+		if !rest.ReadRESTReq(w, r, cliCtx.Codec, &req) {
+			return
+		}
+
+		req.BaseReq = req.BaseReq.Sanitize()
+		if !req.BaseReq.ValidateBasic(w) {
+			return
+		}
+
+		fromAddr, err := sdk.AccAddressFromBech32(req.BaseReq.From)
+		if err != nil {
+			rest.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		wasm, err := GzipCode(req.WASMByteCode)
+		if err != nil {
+			rest.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		msg := MsgStoreCode{
+			Sender:       fromAddr,
+			WASMByteCode: wasm,
+			Source:       req.Source,
+			Builder:      req.Builder,
+		}
+		if err := msg.ValidateBasic(); err != nil {
+			rest.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		clientrest.WriteGenerateStdTxResponse(w, cliCtx, req.BaseReq, []sdk.Msg{msg})
+	}
+}
+
+func instantiateContractHandlerFn(cliCtx context.CLIContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		codeID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			rest.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var req InstantiateContractReq
+		if !rest.ReadRESTReq(w, r, cliCtx.Codec, &req) {
+			return
+		}
+
+		req.BaseReq = req.BaseReq.Sanitize()
+		if !req.BaseReq.ValidateBasic(w) {
+			return
+		}
+
+		fromAddr, err := sdk.AccAddressFromBech32(req.BaseReq.From)
+		if err != nil {
+			rest.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var admin sdk.AccAddress
+		if req.Admin != "" {
+			admin, err = sdk.AccAddressFromBech32(req.Admin)
+			if err != nil {
+				rest.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+				return
+			}
+		}
+
+		msg := MsgCreateContract{
+			Sender:    fromAddr,
+			Code:      CodeID(codeID),
+			InitFunds: req.InitFunds,
+			InitMsg:   []byte(req.InitMsg),
+			Admin:     admin,
+		}
+		if err := msg.ValidateBasic(); err != nil {
+			rest.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		clientrest.WriteGenerateStdTxResponse(w, cliCtx, req.BaseReq, []sdk.Msg{msg})
+	}
+}
+
+func executeContractHandlerFn(cliCtx context.CLIContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		contractAddr, err := sdk.AccAddressFromBech32(mux.Vars(r)["addr"])
+		if err != nil {
+			rest.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var req ExecuteContractReq
+		if !rest.ReadRESTReq(w, r, cliCtx.Codec, &req) {
+			return
+		}
+
+		req.BaseReq = req.BaseReq.Sanitize()
+		if !req.BaseReq.ValidateBasic(w) {
+			return
+		}
+
+		fromAddr, err := sdk.AccAddressFromBech32(req.BaseReq.From)
+		if err != nil {
+			rest.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		msg := MsgSendContract{
+			Sender:   fromAddr,
+			Contract: contractAddr,
+			Payment:  req.Payment,
+			Msg:      []byte(req.Msg),
+		}
+		if err := msg.ValidateBasic(); err != nil {
+			rest.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		clientrest.WriteGenerateStdTxResponse(w, cliCtx, req.BaseReq, []sdk.Msg{msg})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"strconv"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
 	"github.com/cosmos/cosmos-sdk/client"
 	"github.com/cosmos/cosmos-sdk/client/context"
@@ -14,8 +15,13 @@ import (
 )
 
 const (
-	flagTo     = "to"
-	flagAmount = "amount"
+	flagTo      = "to"
+	flagAmount  = "amount"
+	flagSource  = "source"
+	flagBuilder = "builder"
+	flagAdmin   = "admin"
+	// flagGas is registered by client.PostCommands
+	flagGas = "gas"
 )
 
 // GetTxCmd returns the transaction commands for this module
@@ -31,11 +37,15 @@ func GetTxCmd(cdc *codec.Codec) *cobra.Command {
 		StoreCodeCmd(cdc),
 		CreateContractCmd(cdc),
 		SendContractCmd(cdc),
+		MigrateContractCmd(cdc),
+		UpdateAdminCmd(cdc),
+		ClearAdminCmd(cdc),
 	)
 	return txCmd
 }
 
-// StoreCodeCmd will upload code to be reused.
+// StoreCodeCmd will upload code to be reused. The code is gzip compressed
+// and the gas is estimated by simulation unless --gas is given.
 func StoreCodeCmd(cdc *codec.Codec) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "store [from_key_or_address] [wasm file]",
@@ -43,24 +53,37 @@ func StoreCodeCmd(cdc *codec.Codec) *cobra.Command {
 		Args:  cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			txBldr := auth.NewTxBuilderFromCLI().WithTxEncoder(utils.GetTxEncoder(cdc))
+			if !cmd.Flags().Changed(flagGas) {
+				txBldr = txBldr.WithSimulateAndExecute(true)
+			}
 			cliCtx := context.NewCLIContextWithFrom(args[0]).
 				WithCodec(cdc).
 				WithAccountDecoder(cdc)
 
-			// parse coins trying to be sent
 			wasm, err := ReadWasmFromFile(args[1])
 			if err != nil {
 				return err
 			}
+			wasm, err = GzipCode(wasm)
+			if err != nil {
+				return err
+			}
 
 			// build and sign the transaction, then broadcast to Tendermint
 			msg := MsgStoreCode{
 				Sender:       cliCtx.GetFromAddress(),
 				WASMByteCode: wasm,
+				Source:       viper.GetString(flagSource),
+				Builder:      viper.GetString(flagBuilder),
+			}
+			if err := msg.ValidateBasic(); err != nil {
+				return err
 			}
 			return utils.GenerateOrBroadcastMsgs(cliCtx, txBldr, []sdk.Msg{msg})
 		},
 	}
+	cmd.Flags().String(flagSource, "", "URL of the source code the wasm binary was built from")
+	cmd.Flags().String(flagBuilder, "", "Docker image used to build the wasm binary")
 
 	cmd = client.PostCommands(cmd)[0]
 
@@ -70,9 +93,10 @@ func StoreCodeCmd(cdc *codec.Codec) *cobra.Command {
 // CreateContractCmd will instantiate a contract from previously uploaded code.
 func CreateContractCmd(cdc *codec.Codec) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "create [from_key_or_address] [code_id_int64] [coins] [json_encoded_init_args]",
-		Short: "Instantiate a wasm contract",
-		Args:  cobra.ExactArgs(4),
+		Use:     "instantiate [from_key_or_address] [code_id_int64] [coins] [json_encoded_init_args]",
+		Aliases: []string{"create"},
+		Short:   "Instantiate a wasm contract",
+		Args:    cobra.ExactArgs(4),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			txBldr := auth.NewTxBuilderFromCLI().WithTxEncoder(utils.GetTxEncoder(cdc))
 			cliCtx := context.NewCLIContextWithFrom(args[0]).
@@ -93,28 +117,42 @@ func CreateContractCmd(cdc *codec.Codec) *cobra.Command {
 
 			initMsg := args[3]
 
+			var admin sdk.AccAddress
+			if bech := viper.GetString(flagAdmin); bech != "" {
+				admin, err = sdk.AccAddressFromBech32(bech)
+				if err != nil {
+					return err
+				}
+			}
+
 			// build and sign the transaction, then broadcast to Tendermint
 			msg := MsgCreateContract{
 				Sender:    cliCtx.GetFromAddress(),
 				Code:      CodeID(codeID),
 				InitFunds: coins,
 				InitMsg:   []byte(initMsg),
+				Admin:     admin,
+			}
+			if err := msg.ValidateBasic(); err != nil {
+				return err
 			}
 			return utils.GenerateOrBroadcastMsgs(cliCtx, txBldr, []sdk.Msg{msg})
 		},
 	}
+	cmd.Flags().String(flagAdmin, "", "Address allowed to migrate the contract, it is immutable if empty")
 
 	cmd = client.PostCommands(cmd)[0]
 
 	return cmd
 }
 
-// SendContractCmd will instantiate a contract from previously uploaded code.
+// SendContractCmd will execute a contract with a message and payment.
 func SendContractCmd(cdc *codec.Codec) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "send [from_key_or_address] [contract_addr_bech32] [coins] [json_encoded_send_args]",
-		Short: "Instantiate a wasm contract",
-		Args:  cobra.ExactArgs(4),
+		Use:     "execute [from_key_or_address] [contract_addr_bech32] [coins] [json_encoded_send_args]",
+		Aliases: []string{"send"},
+		Short:   "Execute a wasm contract",
+		Args:    cobra.ExactArgs(4),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			txBldr := auth.NewTxBuilderFromCLI().WithTxEncoder(utils.GetTxEncoder(cdc))
 			cliCtx := context.NewCLIContextWithFrom(args[0]).
@@ -142,6 +180,115 @@ func SendContractCmd(cdc *codec.Codec) *cobra.Command {
 				Payment:  coins,
 				Msg:      []byte(sendMsg),
 			}
+			if err := msg.ValidateBasic(); err != nil {
+				return err
+			}
+			return utils.GenerateOrBroadcastMsgs(cliCtx, txBldr, []sdk.Msg{msg})
+		},
+	}
+
+	cmd = client.PostCommands(cmd)[0]
+
+	return cmd
+}
+
+// MigrateContractCmd switches a contract to other code.
+func MigrateContractCmd(cdc *codec.Codec) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate [from_key_or_address] [contract_addr_bech32] [code_id_int64] [json_encoded_migrate_args]",
+		Short: "Migrate a wasm contract to other code",
+		Args:  cobra.ExactArgs(4),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			txBldr := auth.NewTxBuilderFromCLI().WithTxEncoder(utils.GetTxEncoder(cdc))
+			cliCtx := context.NewCLIContextWithFrom(args[0]).
+				WithCodec(cdc).
+				WithAccountDecoder(cdc)
+
+			contractAddr, err := sdk.AccAddressFromBech32(args[1])
+			if err != nil {
+				return err
+			}
+
+			// get the id of the code to migrate to
+			codeID, err := strconv.Atoi(args[2])
+			if err != nil {
+				return err
+			}
+
+			// build and sign the transaction, then broadcast to Tendermint
+			msg := MsgMigrateContract{
+				Sender:     cliCtx.GetFromAddress(),
+				Contract:   contractAddr,
+				Code:       CodeID(codeID),
+				MigrateMsg: []byte(args[3]),
+			}
+			return utils.GenerateOrBroadcastMsgs(cliCtx, txBldr, []sdk.Msg{msg})
+		},
+	}
+
+	cmd = client.PostCommands(cmd)[0]
+
+	return cmd
+}
+
+// UpdateAdminCmd hands the admin rights of a contract to another address.
+func UpdateAdminCmd(cdc *codec.Codec) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set-admin [from_key_or_address] [contract_addr_bech32] [new_admin_addr_bech32]",
+		Short: "Set the admin of a wasm contract",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			txBldr := auth.NewTxBuilderFromCLI().WithTxEncoder(utils.GetTxEncoder(cdc))
+			cliCtx := context.NewCLIContextWithFrom(args[0]).
+				WithCodec(cdc).
+				WithAccountDecoder(cdc)
+
+			contractAddr, err := sdk.AccAddressFromBech32(args[1])
+			if err != nil {
+				return err
+			}
+			newAdmin, err := sdk.AccAddressFromBech32(args[2])
+			if err != nil {
+				return err
+			}
+
+			// build and sign the transaction, then broadcast to Tendermint
+			msg := MsgUpdateAdmin{
+				Sender:   cliCtx.GetFromAddress(),
+				Contract: contractAddr,
+				NewAdmin: newAdmin,
+			}
+			return utils.GenerateOrBroadcastMsgs(cliCtx, txBldr, []sdk.Msg{msg})
+		},
+	}
+
+	cmd = client.PostCommands(cmd)[0]
+
+	return cmd
+}
+
+// ClearAdminCmd removes the admin of a contract, making it immutable.
+func ClearAdminCmd(cdc *codec.Codec) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clear-admin [from_key_or_address] [contract_addr_bech32]",
+		Short: "Remove the admin of a wasm contract",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			txBldr := auth.NewTxBuilderFromCLI().WithTxEncoder(utils.GetTxEncoder(cdc))
+			cliCtx := context.NewCLIContextWithFrom(args[0]).
+				WithCodec(cdc).
+				WithAccountDecoder(cdc)
+
+			contractAddr, err := sdk.AccAddressFromBech32(args[1])
+			if err != nil {
+				return err
+			}
+
+			// build and sign the transaction, then broadcast to Tendermint
+			msg := MsgClearAdmin{
+				Sender:   cliCtx.GetFromAddress(),
+				Contract: contractAddr,
+			}
 			return utils.GenerateOrBroadcastMsgs(cliCtx, txBldr, []sdk.Msg{msg})
 		},
 	}
@@ -1,12 +1,59 @@
 package contract
 
+import (
+	"encoding/json"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	cmn "github.com/tendermint/tendermint/libs/common"
+)
+
 // Defines contract module constants
 const (
 	RouterKey    = ModuleName
 	QuerierRoute = ModuleName
 )
 
-// Contract stores data about a contract
-type Contract struct {
-	ByteCode []byte
+// CodeInfo is the metadata stored along with uploaded code
+type CodeInfo struct {
+	// CodeHash is the sha256 hash of the code as uploaded, before gas
+	// metering was injected
+	CodeHash cmn.HexBytes   `json:"code_hash"`
+	Creator  sdk.AccAddress `json:"creator"`
+	// Source optionally links to the source the code was built from
+	Source string `json:"source"`
+	// Builder optionally names the docker image used to build the code
+	Builder string `json:"builder"`
+}
+
+// CodeInfoResponse is a CodeInfo listed with its code ID
+type CodeInfoResponse struct {
+	ID CodeID `json:"id"`
+	CodeInfo
+}
+
+// Operations recorded in the contract history
+const (
+	OperationInit        = "init"
+	OperationMigrate     = "migrate"
+	OperationUpdateAdmin = "update-admin"
+	OperationClearAdmin  = "clear-admin"
+)
+
+// ContractHistoryEntry records a change of the code or admin of a contract
+type ContractHistoryEntry struct {
+	Operation string `json:"operation"`
+	// CodeID is the code the contract runs after the change
+	CodeID CodeID `json:"code_id"`
+	// Admin is the admin after the change, empty if there is none
+	Admin  sdk.AccAddress `json:"admin"`
+	Height int64          `json:"height"`
+	// Msg is the init or migrate message
+	Msg json.RawMessage `json:"msg,omitempty"`
+}
+
+// ContractInfoResponse describes a contract for queries
+type ContractInfoResponse struct {
+	Address sdk.AccAddress `json:"address"`
+	CodeID  CodeID         `json:"code_id"`
+	Admin   sdk.AccAddress `json:"admin"`
 }
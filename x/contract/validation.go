@@ -0,0 +1,234 @@
+package contract
+
+import (
+	"github.com/pkg/errors"
+)
+
+// requiredExports are the functions the contract runtime calls
+var requiredExports = []string{"init_wrapper", "send_wrapper", "allocate"}
+
+// ValidateCode checks that code is a wasm module exporting the functions
+// called by the contract runtime and the memory used to pass arguments.
+// Modules must execute deterministically, so they may only import the host
+// functions and must not use floating point values or instructions.
+func ValidateCode(code []byte) error {
+	sections, err := readSections(code)
+	if err != nil {
+		return err
+	}
+	if err := validateImports(sections); err != nil {
+		return err
+	}
+	if err := validateDeterminism(sections); err != nil {
+		return err
+	}
+
+	exports, err := parseExports(findSection(sections, sectionExport))
+	if err != nil {
+		return err
+	}
+	kinds := make(map[string]byte, len(exports))
+	for _, e := range exports {
+		kinds[e.name] = e.kind
+	}
+	for _, name := range requiredExports {
+		if kind, ok := kinds[name]; !ok || kind != externFunc {
+			return errors.Errorf("missing exported function %s", name)
+		}
+	}
+	if kind, ok := kinds["memory"]; !ok || kind != externMemory {
+		return errors.New("missing exported memory")
+	}
+	return nil
+}
+
+// ValidateCodeLimits checks the size, memory and table of code against the
+// limits set in params. The maximum memory of valid code is capped with
+// limitMemory when it is stored.
+func ValidateCodeLimits(code []byte, params Params) error {
+	if uint64(len(code)) > params.MaxCodeSize {
+		return errors.Errorf("code size %d exceeds the limit of %d bytes", len(code), params.MaxCodeSize)
+	}
+	sections, err := readSections(code)
+	if err != nil {
+		return err
+	}
+	memories, err := parseLimitsSection(findSection(sections, sectionMemory), false)
+	if err != nil {
+		return errors.Wrap(err, "memory section")
+	}
+	if len(memories) > 1 {
+		return errors.New("only a single memory is supported")
+	}
+	for _, m := range memories {
+		if uint64(m.min) > params.MaxMemoryPages {
+			return errors.Errorf("initial memory of %d pages exceeds the limit of %d pages", m.min, params.MaxMemoryPages)
+		}
+	}
+	tables, err := parseLimitsSection(findSection(sections, sectionTable), true)
+	if err != nil {
+		return errors.Wrap(err, "table section")
+	}
+	if len(tables) > 1 {
+		return errors.New("only a single table is supported")
+	}
+	for _, t := range tables {
+		if uint64(t.min) > params.MaxTableSize {
+			return errors.Errorf("table size %d exceeds the limit of %d elements", t.min, params.MaxTableSize)
+		}
+	}
+	return nil
+}
+
+// limitMemory sets the maximum memory of code to maxPages, unless it
+// declares a lower maximum already, so memory.grow fails deterministically
+// once the limit is reached
+func limitMemory(code []byte, maxPages uint64) ([]byte, error) {
+	sections, err := readSections(code)
+	if err != nil {
+		return nil, err
+	}
+	data := findSection(sections, sectionMemory)
+	if data == nil {
+		return code, nil
+	}
+	memories, err := parseLimitsSection(data, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "memory section")
+	}
+	for i, m := range memories {
+		if !m.hasMax || uint64(m.max) > maxPages {
+			memories[i].max = uint32(maxPages)
+			memories[i].hasMax = true
+		}
+		if memories[i].max < m.min {
+			return nil, errors.Errorf("initial memory of %d pages exceeds the limit of %d pages", m.min, maxPages)
+		}
+	}
+	return writeSections(setSection(sections, sectionMemory, encodeMemories(memories))), nil
+}
+
+// validateImports allows only the host functions, with their signatures
+func validateImports(sections []wasmSection) error {
+	types, err := parseTypes(findSection(sections, sectionType))
+	if err != nil {
+		return err
+	}
+	imports, err := parseImports(findSection(sections, sectionImport))
+	if err != nil {
+		return err
+	}
+	for _, imp := range imports {
+		if imp.module != "env" {
+			return errors.Errorf("import %s.%s: unknown module %s", imp.module, imp.name, imp.module)
+		}
+		if imp.kind != externFunc {
+			return errors.Errorf("import %s.%s: only functions can be imported", imp.module, imp.name)
+		}
+		want, ok := hostFunctions[imp.name]
+		if !ok {
+			return errors.Errorf("import %s.%s: unknown host function", imp.module, imp.name)
+		}
+		if int(imp.typeIdx) >= len(types) {
+			return errors.Errorf("import %s.%s: unknown type %d", imp.module, imp.name, imp.typeIdx)
+		}
+		got := types[imp.typeIdx]
+		if string(got.params) != string(want.params) || string(got.results) != string(want.results) {
+			return errors.Errorf("import %s.%s: wrong signature", imp.module, imp.name)
+		}
+	}
+	return nil
+}
+
+// validateDeterminism rejects floating point values in signatures, locals and
+// globals, floating point instructions in function bodies and growing tables
+func validateDeterminism(sections []wasmSection) error {
+	types, err := parseTypes(findSection(sections, sectionType))
+	if err != nil {
+		return err
+	}
+	for i, t := range types {
+		if hasFloat(t.params) || hasFloat(t.results) {
+			return errors.Errorf("type %d: floating point values are not supported", i)
+		}
+	}
+	globals, err := parseGlobals(findSection(sections, sectionGlobal))
+	if err != nil {
+		return err
+	}
+	for i, g := range globals {
+		if isFloat(g.valueType) {
+			return errors.Errorf("global %d: floating point values are not supported", i)
+		}
+	}
+	codes, err := parseCode(findSection(sections, sectionCode))
+	if err != nil {
+		return err
+	}
+	for i, c := range codes {
+		for _, l := range c.locals {
+			if isFloat(l.valueType) {
+				return errors.Errorf("function %d: floating point locals are not supported", i)
+			}
+		}
+		for j, ins := range c.body {
+			if isFloatInstruction(ins) {
+				return errors.Errorf("function %d, instruction %d: floating point instruction 0x%x is not supported", i, j, ins.op)
+			}
+			if ins.op == opPrefixFC && ins.sub == opTableGrow {
+				return errors.Errorf("function %d, instruction %d: table.grow is not supported", i, j)
+			}
+		}
+	}
+	return nil
+}
+
+// maxWasmMemoryPages is the number of pages addressable by 32 bit wasm
+const maxWasmMemoryPages = 65536
+
+// opTableGrow is the 0xfc prefixed table.grow instruction
+const opTableGrow = 15
+
+func isFloat(t byte) bool {
+	return t == valueF32 || t == valueF64
+}
+
+func hasFloat(types []byte) bool {
+	for _, t := range types {
+		if isFloat(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// isFloatInstruction returns whether ins loads, stores, creates, compares,
+// computes or converts floating point values
+func isFloatInstruction(ins instruction) bool {
+	switch op := ins.op; {
+	case op == 0x2a || op == 0x2b || op == 0x38 || op == 0x39:
+		// f32/f64 load and store
+		return true
+	case op == 0x43 || op == 0x44:
+		// f32/f64 const
+		return true
+	case op >= 0x5b && op <= 0x66:
+		// comparisons
+		return true
+	case op >= 0x8b && op <= 0xa6:
+		// arithmetic
+		return true
+	case op >= 0xa8 && op <= 0xab, op >= 0xae && op <= 0xbf:
+		// conversions from, to and reinterpretations of floats
+		return true
+	case op == opPrefixFC && ins.sub <= 7:
+		// saturating truncations
+		return true
+	case op == opSelectTyped:
+		// select with the type of its operands, a vector of value types
+		r := newWasmReader(ins.imm)
+		r.u32()
+		return hasFloat(r.data[r.pos:])
+	}
+	return false
+}
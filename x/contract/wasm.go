@@ -1,8 +1,8 @@
 package contract
 
 import (
+	"bytes"
 	"fmt"
-	"strings"
 
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/pkg/errors"
@@ -10,33 +10,11 @@ import (
 	wasm "github.com/wasmerio/go-ext-wasm/wasmer"
 )
 
-var (
-	curInstance *wasm.Instance
-	curStore    sdk.KVStore
-	curKey      []byte
-)
-
 // ReadWasmFromFile loads a wasm file
 func ReadWasmFromFile(filename string) ([]byte, error) {
 	return wasm.ReadBytes(filename)
 }
 
-func ReadDB() string {
-	bz := curStore.Get(curKey)
-	return string(bz)
-}
-
-func WriteDB(val string) {
-	curStore.Set(curKey, []byte(val))
-}
-
-// WasmString can be called by a go function provided into Imports
-// It will allocate space in wasm, copy the string there, and return a pointer
-// The pointer can be returned to the wasm caller to receive the string
-func WasmString(res string) int32 {
-	return prepareString(*curInstance, res)
-}
-
 type ResultParser func(wasm.Instance, wasm.Value) (interface{}, error)
 
 func AsInt32(_ wasm.Instance, res wasm.Value) (interface{}, error) {
@@ -50,8 +28,10 @@ func AsInt64(_ wasm.Instance, res wasm.Value) (interface{}, error) {
 func AsString(instance wasm.Instance, res wasm.Value) (interface{}, error) {
 	outputPointer := res.ToI32()
 
-	memory := instance.Memory.Data()[outputPointer:]
-	str := readString(memory)
+	str, err := readString(instance.Memory.Data(), outputPointer)
+	if err != nil {
+		return nil, err
+	}
 
 	// Deallocate the subject, and the output.
 	deallocate, ok := instance.Exports["deallocate"]
@@ -65,45 +45,79 @@ func AsString(instance wasm.Instance, res wasm.Value) (interface{}, error) {
 
 // Run will execute the named function on the wasm bytes with the passed arguments.
 // Parses json response. Also returns error is the contract sets "error" in json response
-func Run(cdc *amino.Codec, store sdk.KVStore, key []byte, code []byte, call string, args []interface{}) (*SendResponse, sdk.Error) {
-	curStore = store
-	curKey = key
-	defer func() {
-		curStore = nil
-		curKey = nil
-	}()
+// Host functions called by the contract operate on the passed environment.
+// Returns an out of gas error if the gas meter of env ran out during the call.
+func Run(cdc *amino.Codec, env *Env, code []byte, call string, args []interface{}) (*SendResponse, sdk.Error) {
+	return RunCached(cdc, nil, env, code, call, args)
+}
 
-	res, err := run(code, call, args, AsString)
+// RunCached is Run, taking the compiled module from cache
+func RunCached(cdc *amino.Codec, cache *ModuleCache, env *Env, code []byte, call string, args []interface{}) (*SendResponse, sdk.Error) {
+	res, sdkErr := runString(cache, env, code, call, args)
+	if sdkErr != nil {
+		return nil, sdkErr
+	}
+	env.logger().Debug("contract response", "call", call, "response", res)
+	out, err := ParseResponse(cdc, res)
 	if err != nil {
 		return nil, sdk.ErrUnknownRequest(err.Error())
 	}
-	fmt.Printf("From wasm: %s\n", res.(string))
-	out, err := ParseResponse(cdc, res.(string))
+	return out, nil
+}
+
+// Query calls the query entry point of the contract with the json encoded
+// msg and returns the result of the json response. The store of env should
+// be read-only, writes make the query fail.
+func Query(cache *ModuleCache, env *Env, code []byte, msg []byte) ([]byte, sdk.Error) {
+	res, sdkErr := runString(cache, env, code, "query", []interface{}{msg})
+	if sdkErr != nil {
+		return nil, sdkErr
+	}
+	out, err := ParseQueryResponse(res)
 	if err != nil {
 		return nil, sdk.ErrUnknownRequest(err.Error())
 	}
 	return out, nil
 }
 
+// runString executes the call and returns the string result, converting
+// failures recorded by host functions into errors
+func runString(cache *ModuleCache, env *Env, code []byte, call string, args []interface{}) (string, sdk.Error) {
+	res, err := run(cache, env, code, call, args, AsString)
+	if env.outOfGas {
+		return "", sdk.ErrOutOfGas(fmt.Sprintf("contract execution: %s", call))
+	}
+	if _, ok := env.failure.(readOnlyError); ok {
+		return "", sdk.ErrUnauthorized(env.failure.Error())
+	}
+	if env.failure != nil {
+		return "", sdk.ErrUnknownRequest(env.failure.Error())
+	}
+	if err != nil {
+		return "", sdk.ErrUnknownRequest(err.Error())
+	}
+	return res.(string), nil
+}
+
 // run will execute the named function on the wasm bytes with the passed arguments.
 // Returns the result or an error
-func run(code []byte, call string, args []interface{}, parse ResultParser) (interface{}, error) {
+func run(cache *ModuleCache, env *Env, code []byte, call string, args []interface{}, parse ResultParser) (interface{}, error) {
 	imports, err := wasmImports()
 	if err != nil {
 		return nil, errors.Wrap(err, "creating imports")
 	}
 
 	// Instantiates the WebAssembly module.
-	instance, err := wasm.NewInstanceWithImports(code, imports)
+	instance, err := cache.instantiate(code, imports)
 	if err != nil {
 		return nil, errors.Wrap(err, "init wasmer")
 	}
 
-	// we give access to some globals for go callbacks
-	curInstance = &instance
+	// host functions find the environment through the instance context
+	detach := attachEnv(&instance, env)
 	defer func() {
+		detach()
 		instance.Close()
-		curInstance = nil
 	}()
 
 	f, ok := instance.Exports[call]
@@ -111,7 +125,10 @@ func run(code []byte, call string, args []interface{}, parse ResultParser) (inte
 		return nil, errors.Errorf("Function %s not in Exports", call)
 	}
 
-	fArgs := prepareArgs(instance, args)
+	fArgs, err := prepareArgs(instance, args)
+	if err != nil {
+		return nil, errors.Wrap(err, "preparing arguments")
+	}
 
 	ret, err := f(fArgs...)
 	if err != nil {
@@ -121,49 +138,65 @@ func run(code []byte, call string, args []interface{}, parse ResultParser) (inte
 	return parse(instance, ret)
 }
 
-func prepareArgs(instance wasm.Instance, args []interface{}) []interface{} {
+func prepareArgs(instance wasm.Instance, args []interface{}) ([]interface{}, error) {
 	out := make([]interface{}, len(args))
 
 	for i, arg := range args {
+		var err error
 		switch t := arg.(type) {
 		case int32, int64:
 			out[i] = arg
 		case string:
-			out[i] = prepareString(instance, t)
+			out[i], err = prepareString(instance, t)
 		case []byte:
-			out[i] = prepareString(instance, string(t))
+			out[i], err = prepareString(instance, string(t))
 		default:
 			panic(fmt.Sprintf("Unsupported type: %T", arg))
 		}
+		if err != nil {
+			return nil, err
+		}
 	}
-	return out
+	return out, nil
 }
 
-func prepareString(instance wasm.Instance, arg string) int32 {
+// prepareString copies arg into memory allocated by the contract and returns
+// its pointer. Pointers outside of the memory returned by the allocator of
+// the contract are an error.
+func prepareString(instance wasm.Instance, arg string) (int32, error) {
 	l := len(arg)
-	allocateResult, _ := instance.Exports["allocate"](l)
+	allocate, ok := instance.Exports["allocate"]
+	if !ok {
+		return 0, errors.New("allocate not in Exports")
+	}
+	allocateResult, err := allocate(l)
+	if err != nil {
+		return 0, errors.Wrap(err, "allocate")
+	}
 	inputPointer := allocateResult.ToI32()
 
-	// Write the subject into the memory.
-	memory := instance.Memory.Data()[inputPointer:]
-	copy(memory, arg)
+	// Write the subject into the memory, leaving room for the NUL.
+	memory := instance.Memory.Data()
+	if inputPointer <= 0 || int(inputPointer)+l >= len(memory) {
+		return 0, errors.Errorf("allocate returned invalid pointer %d for %d bytes", inputPointer, l)
+	}
+	copy(memory[inputPointer:], arg)
 
 	// C-string terminates by NULL.
-	memory[l] = 0
+	memory[int(inputPointer)+l] = 0
 
-	return inputPointer
+	return inputPointer, nil
 }
 
-func readString(memory []byte) string {
-	nth := 0
-	var output strings.Builder
-	for {
-		if memory[nth] == 0 {
-			break
-		}
-
-		output.WriteByte(memory[nth])
-		nth++
+// readString returns the NUL terminated string at ptr. Pointers outside of
+// memory and strings without a terminator are an error.
+func readString(memory []byte, ptr int32) (string, error) {
+	if ptr <= 0 || int(ptr) >= len(memory) {
+		return "", errors.Errorf("pointer %d is outside of the contract memory", ptr)
+	}
+	n := bytes.IndexByte(memory[ptr:], 0)
+	if n < 0 {
+		return "", errors.Errorf("string at %d is not terminated", ptr)
 	}
-	return output.String()
+	return string(memory[ptr : int(ptr)+n]), nil
 }
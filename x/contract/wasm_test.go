@@ -1,16 +1,26 @@
 package contract
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/cosmos/cosmos-sdk/store/transient"
 	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/stretchr/testify/require"
 )
 
-func mockKVStore() (sdk.KVStore, []byte) {
-	store := transient.NewStore()
-	key := []byte("12345")
-	return store, key
+func mockEnv() *Env {
+	return &Env{
+		Store:    transient.NewStore(),
+		Key:      []byte("12345"),
+		GasMeter: sdk.NewInfiniteGasMeter(),
+	}
 }
 
 func TestRegenInit(t *testing.T) {
@@ -19,7 +29,7 @@ func TestRegenInit(t *testing.T) {
 		t.Fatalf("%+v", err)
 	}
 
-	store, key := mockKVStore()
+	env := mockEnv()
 
 	initMsg := `{
 		"contract_address": "cosmos1qz58hjld64vqmynzk5xdesvkr9walfmrl5pefr",
@@ -31,7 +41,7 @@ func TestRegenInit(t *testing.T) {
 		}
 	}`
 
-	res, err := Run(MockCodec(), store, key, regen, "init_wrapper", []interface{}{initMsg})
+	res, err := Run(MockCodec(), env, regen, "init_wrapper", []interface{}{initMsg})
 	if err != nil {
 		t.Fatalf("%+v", err)
 	}
@@ -43,7 +53,7 @@ func TestRegenInit(t *testing.T) {
 		invalid: 123
 	}`
 
-	res, err = Run(MockCodec(), store, key, regen, "send_wrapper", []interface{}{badSend})
+	res, err = Run(MockCodec(), env, regen, "send_wrapper", []interface{}{badSend})
 	if err == nil {
 		t.Fatal("Allowed bad json")
 	}
@@ -55,7 +65,7 @@ func TestRegenInit(t *testing.T) {
 		"msg": {}
 	}`
 
-	res, err = Run(MockCodec(), store, key, regen, "send_wrapper", []interface{}{unauthSend})
+	res, err = Run(MockCodec(), env, regen, "send_wrapper", []interface{}{unauthSend})
 	if err == nil {
 		t.Fatal("Allowed no auth")
 	}
@@ -67,7 +77,7 @@ func TestRegenInit(t *testing.T) {
 		"msg": {}
 	}`
 
-	res, err = Run(MockCodec(), store, key, regen, "send_wrapper", []interface{}{goodSend})
+	res, err = Run(MockCodec(), env, regen, "send_wrapper", []interface{}{goodSend})
 	if err != nil {
 		t.Fatalf("%+v", err)
 	}
@@ -76,3 +86,320 @@ func TestRegenInit(t *testing.T) {
 		t.Fatalf("Unexpected result: %v", res)
 	}
 }
+
+func TestRegenConcurrent(t *testing.T) {
+	regen, err := ReadWasmFromFile("examples/regen/build/regen.wasm")
+	require.NoError(t, err)
+
+	const n = 8
+	envs := make([]*Env, n)
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		envs[i] = mockEnv()
+		wg.Add(1)
+		go func(env *Env, funds int) {
+			defer wg.Done()
+			initMsg := fmt.Sprintf(`{
+				"contract_address": "cosmos1qz58hjld64vqmynzk5xdesvkr9walfmrl5pefr",
+				"sender": "cosmos1qtkc837fpfprvr2fcmuw6hgkesen4pxnhe2skl",
+				"sent_funds": %d,
+				"msg": {
+					"verifier": "cosmos1qw4eww34ug66edg9mgsapgcgjuqcpyqxtcz6a5",
+					"beneficiary": "cosmos1qjzjfn55hygaak9l9x04z792mexce2zddws9pt"
+				}
+			}`, funds)
+			if _, err := Run(MockCodec(), env, regen, "init_wrapper", []interface{}{initMsg}); err != nil {
+				errs <- err
+			}
+		}(envs[i], 1000+i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	// every call must have written to its own store only
+	for i, env := range envs {
+		funds := env.Get([]byte("funds/cosmos1qtkc837fpfprvr2fcmuw6hgkesen4pxnhe2skl"))
+		require.Equal(t, fmt.Sprintf("%d", 1000+i), string(funds))
+	}
+}
+
+func TestEnvRange(t *testing.T) {
+	env := mockEnv()
+	env.Set([]byte("a"), []byte("1"))
+	env.Set([]byte("b"), []byte("2"))
+	env.Set([]byte("c"), []byte("3"))
+	env.Delete([]byte("c"))
+
+	iter := env.Range([]byte("a"), nil)
+	require.Nil(t, env.Value(iter))
+	var keys, values []string
+	for {
+		key, ok := env.Next(iter)
+		if !ok {
+			break
+		}
+		keys = append(keys, string(key))
+		values = append(values, string(env.Value(iter)))
+	}
+	require.Equal(t, []string{"a", "b"}, keys)
+	require.Equal(t, []string{"1", "2"}, values)
+	require.Nil(t, env.Value(iter))
+
+	// unknown handles are exhausted
+	_, ok := env.Next(iter + 1)
+	require.False(t, ok)
+
+	env.closeIterators()
+	_, ok = env.Next(iter)
+	require.False(t, ok)
+}
+
+func TestGasMetering(t *testing.T) {
+	regen, err := ReadWasmFromFile("examples/regen/build/regen.wasm")
+	require.NoError(t, err)
+	metered, err := InjectGasMetering(regen)
+	require.NoError(t, err)
+
+	// metering is only injected once
+	_, err = InjectGasMetering(metered)
+	require.Error(t, err)
+
+	initMsg := `{
+		"contract_address": "cosmos1qz58hjld64vqmynzk5xdesvkr9walfmrl5pefr",
+		"sender": "cosmos1qtkc837fpfprvr2fcmuw6hgkesen4pxnhe2skl",
+		"sent_funds": 1000,
+		"msg": {
+			"verifier": "cosmos1qw4eww34ug66edg9mgsapgcgjuqcpyqxtcz6a5",
+			"beneficiary": "cosmos1qjzjfn55hygaak9l9x04z792mexce2zddws9pt"
+		}
+	}`
+
+	env := mockEnv()
+	env.InstructionCost = 1
+	_, sdkErr := Run(MockCodec(), env, metered, "init_wrapper", []interface{}{initMsg})
+	require.Nil(t, sdkErr)
+	used := env.GasMeter.GasConsumed()
+	require.True(t, used > 0)
+
+	// the same call fails with a limit below the gas it needs
+	env = mockEnv()
+	env.InstructionCost = 1
+	env.GasMeter = sdk.NewGasMeter(used / 2)
+	_, sdkErr = Run(MockCodec(), env, metered, "init_wrapper", []interface{}{initMsg})
+	require.NotNil(t, sdkErr)
+	require.Equal(t, sdk.CodeOutOfGas, sdkErr.Code())
+}
+
+func TestGasMeteringLoop(t *testing.T) {
+	code, err := ReadWasmFromFile("examples/loop/build/loop.wasm")
+	require.NoError(t, err)
+	metered, err := InjectGasMetering(code)
+	require.NoError(t, err)
+
+	env := mockEnv()
+	env.InstructionCost = 10
+	env.GasMeter = sdk.NewGasMeter(100000)
+	_, sdkErr := Run(MockCodec(), env, metered, "send_wrapper", []interface{}{"{}"})
+	require.NotNil(t, sdkErr)
+	require.Equal(t, sdk.CodeOutOfGas, sdkErr.Code())
+	require.Equal(t, uint64(100000), env.GasMeter.GasConsumedToLimit())
+}
+
+const regenInit = `{
+	"contract_address": "cosmos1qz58hjld64vqmynzk5xdesvkr9walfmrl5pefr",
+	"sender": "cosmos1qtkc837fpfprvr2fcmuw6hgkesen4pxnhe2skl",
+	"sent_funds": 1000,
+	"msg": {
+		"verifier": "cosmos1qw4eww34ug66edg9mgsapgcgjuqcpyqxtcz6a5",
+		"beneficiary": "cosmos1qjzjfn55hygaak9l9x04z792mexce2zddws9pt"
+	}
+}`
+
+func TestModuleCache(t *testing.T) {
+	regen, err := ReadWasmFromFile("examples/regen/build/regen.wasm")
+	require.NoError(t, err)
+	kvstore, err := ReadWasmFromFile("examples/kvstore/build/kvstore.wasm")
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "wasm")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cache, err := NewModuleCache(1, dir)
+	require.NoError(t, err)
+
+	_, sdkErr := RunCached(MockCodec(), cache, mockEnv(), regen, "init_wrapper", []interface{}{regenInit})
+	require.Nil(t, sdkErr)
+	_, sdkErr = RunCached(MockCodec(), cache, mockEnv(), regen, "init_wrapper", []interface{}{regenInit})
+	require.Nil(t, sdkErr)
+	require.Equal(t, CacheMetrics{Hits: 1, Misses: 1}, cache.Metrics())
+
+	// kvstore evicts regen
+	_, sdkErr = RunCached(MockCodec(), cache, mockEnv(), kvstore, "init_wrapper", []interface{}{"{}"})
+	require.Nil(t, sdkErr)
+	require.Equal(t, CacheMetrics{Hits: 1, Misses: 2}, cache.Metrics())
+	require.Equal(t, 1, cache.Len())
+
+	// evicted and restarted nodes load the compiled module from disk
+	_, sdkErr = RunCached(MockCodec(), cache, mockEnv(), regen, "init_wrapper", []interface{}{regenInit})
+	require.Nil(t, sdkErr)
+	require.Equal(t, CacheMetrics{Hits: 1, DiskHits: 1, Misses: 2}, cache.Metrics())
+
+	restarted, err := NewModuleCache(DefaultModuleCacheSize, dir)
+	require.NoError(t, err)
+	_, sdkErr = RunCached(MockCodec(), restarted, mockEnv(), kvstore, "init_wrapper", []interface{}{"{}"})
+	require.Nil(t, sdkErr)
+	require.Equal(t, CacheMetrics{DiskHits: 1}, restarted.Metrics())
+
+	_, err = NewModuleCache(0, "")
+	require.Error(t, err)
+}
+
+func TestModuleCacheArtifactCheck(t *testing.T) {
+	regen, err := ReadWasmFromFile("examples/regen/build/regen.wasm")
+	require.NoError(t, err)
+	kvstore, err := ReadWasmFromFile("examples/kvstore/build/kvstore.wasm")
+	require.NoError(t, err)
+
+	dir, err := ioutil.TempDir("", "wasm")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	cache, err := NewModuleCache(DefaultModuleCacheSize, dir)
+	require.NoError(t, err)
+	_, sdkErr := RunCached(MockCodec(), cache, mockEnv(), regen, "init_wrapper", []interface{}{regenInit})
+	require.Nil(t, sdkErr)
+	_, sdkErr = RunCached(MockCodec(), cache, mockEnv(), kvstore, "init_wrapper", []interface{}{"{}"})
+	require.Nil(t, sdkErr)
+
+	artifact := func(code []byte) string {
+		hash := sha256.Sum256(code)
+		return filepath.Join(dir, hex.EncodeToString(hash[:])+".module")
+	}
+	regenArtifact, err := ioutil.ReadFile(artifact(regen))
+	require.NoError(t, err)
+	kvstoreArtifact, err := ioutil.ReadFile(artifact(kvstore))
+	require.NoError(t, err)
+
+	cases := map[string][]byte{
+		// the artifact of other code put in place of regen's
+		"swapped": kvstoreArtifact,
+		// the compiled module was changed after it was written
+		"modified":  append(append([]byte{}, regenArtifact[:len(regenArtifact)-1]...), regenArtifact[len(regenArtifact)-1]^1),
+		"truncated": regenArtifact[:sha256.Size],
+	}
+	for name, bz := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, ioutil.WriteFile(artifact(regen), bz, 0644))
+
+			restarted, err := NewModuleCache(DefaultModuleCacheSize, dir)
+			require.NoError(t, err)
+			res, sdkErr := RunCached(MockCodec(), restarted, mockEnv(), regen, "init_wrapper", []interface{}{regenInit})
+			require.Nil(t, sdkErr)
+			require.Empty(t, res.Msgs)
+			require.Equal(t, CacheMetrics{Misses: 1}, restarted.Metrics())
+
+			// the artifact is written again from the compiled code
+			bz, err = ioutil.ReadFile(artifact(regen))
+			require.NoError(t, err)
+			require.Equal(t, regenArtifact[:sha256.Size], bz[:sha256.Size])
+		})
+	}
+}
+
+func TestModuleCacheConcurrent(t *testing.T) {
+	regen, err := ReadWasmFromFile("examples/regen/build/regen.wasm")
+	require.NoError(t, err)
+	kvstore, err := ReadWasmFromFile("examples/kvstore/build/kvstore.wasm")
+	require.NoError(t, err)
+
+	// a single slot keeps evicting modules while other calls use them
+	cache, err := NewModuleCache(1, "")
+	require.NoError(t, err)
+
+	const n = 8
+	errs := make(chan sdk.Error, n*4)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 4; j++ {
+				var sdkErr sdk.Error
+				if (i+j)%2 == 0 {
+					_, sdkErr = RunCached(MockCodec(), cache, mockEnv(), regen, "init_wrapper", []interface{}{regenInit})
+				} else {
+					_, sdkErr = RunCached(MockCodec(), cache, mockEnv(), kvstore, "init_wrapper", []interface{}{"{}"})
+				}
+				errs <- sdkErr
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for sdkErr := range errs {
+		require.Nil(t, sdkErr)
+	}
+
+	metrics := cache.Metrics()
+	require.Equal(t, uint64(32), metrics.Hits+metrics.Misses)
+}
+
+func BenchmarkRegenInit(b *testing.B) {
+	benchmarkRegenInit(b, nil)
+}
+
+func BenchmarkRegenInitCached(b *testing.B) {
+	cache, err := NewModuleCache(DefaultModuleCacheSize, "")
+	require.NoError(b, err)
+	benchmarkRegenInit(b, cache)
+}
+
+func benchmarkRegenInit(b *testing.B, cache *ModuleCache) {
+	regen, err := ReadWasmFromFile("examples/regen/build/regen.wasm")
+	require.NoError(b, err)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, sdkErr := RunCached(MockCodec(), cache, mockEnv(), regen, "init_wrapper", []interface{}{regenInit})
+		require.Nil(b, sdkErr)
+	}
+}
+
+func TestReadOnlyStore(t *testing.T) {
+	code, err := ReadWasmFromFile("examples/kvstore/build/kvstore.wasm")
+	require.NoError(t, err)
+
+	env := mockEnv()
+	store := env.Store
+	env.Store = NewReadOnlyStore(store)
+
+	// init writes to the store, which fails the call instead of crashing
+	_, sdkErr := Run(MockCodec(), env, code, "init_wrapper", []interface{}{"{}"})
+	require.NotNil(t, sdkErr)
+	require.Equal(t, sdk.CodeUnauthorized, sdkErr.Code())
+	require.Nil(t, store.Get([]byte("foo")))
+
+	require.Panics(t, func() { env.Store.Delete([]byte("foo")) })
+}
+
+func TestInvalidPointers(t *testing.T) {
+	code, err := ReadWasmFromFile("examples/pointers/build/pointers.wasm")
+	require.NoError(t, err)
+
+	calls := []string{"set_negative", "get_unterminated", "set_missing", "write_outside", "return_outside", "get_bad_allocation"}
+	for _, call := range calls {
+		t.Run(call, func(t *testing.T) {
+			env := mockEnv()
+			env.Store.Set([]byte("foo"), []byte("bar"))
+			_, sdkErr := Run(MockCodec(), env, code, call, nil)
+			require.NotNil(t, sdkErr)
+			require.Equal(t, sdk.CodeUnknownRequest, sdkErr.Code())
+			require.Nil(t, env.Store.Get([]byte("bar")))
+		})
+	}
+}
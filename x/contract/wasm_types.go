@@ -1,6 +1,7 @@
 package contract
 
 import (
+	"encoding/json"
 	"errors"
 
 	"github.com/cosmos/cosmos-sdk/codec"
@@ -13,6 +14,55 @@ type SendResponse struct {
 	Error string    `json:"error"`
 	Msgs  []sdk.Msg `json:"msgs"`
 	// Msgs []json.RawMessage `json:"msgs"`
+	// SubMsgs are dispatched after Msgs, each reporting its result back to
+	// the reply entry point of the contract
+	SubMsgs []SubMsg `json:"submsgs"`
+	// Log attributes are added to the tags of the result, namespaced with
+	// the address of the contract
+	Log []LogAttribute `json:"log"`
+	// Data is returned to the caller as the data of the result
+	Data []byte `json:"data"`
+}
+
+// LogAttribute is a key/value pair a contract adds to the result tags
+type LogAttribute struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ContractEnv is passed to the entry points of contracts along with their
+// message
+type ContractEnv struct {
+	Block    BlockInfo      `json:"block"`
+	Contract sdk.AccAddress `json:"contract"`
+	// Balance is the balance of the contract, including the funds sent with
+	// the message
+	Balance sdk.Coins `json:"balance"`
+}
+
+// BlockInfo describes the block a contract runs in
+type BlockInfo struct {
+	Height int64 `json:"height"`
+	// Time is the block time in seconds since the unix epoch
+	Time    int64  `json:"time"`
+	ChainID string `json:"chain_id"`
+}
+
+// ContractQuery is a query a contract makes through c_query. It is answered
+// like the abci query custom/<path> with data, e.g. the path
+// "contract/smart/<address>" calls the query entry point of another contract.
+type ContractQuery struct {
+	Path string          `json:"path"`
+	Data json.RawMessage `json:"data"`
+}
+
+// SubMsg is a message a contract dispatches without failing if the message
+// fails. The contract learns the outcome through its reply entry point.
+type SubMsg struct {
+	// ID is passed back in the reply to tell submessages apart. Like all 64
+	// bit integers in amino json it is encoded as a string.
+	ID  uint64  `json:"id"`
+	Msg sdk.Msg `json:"msg"`
 }
 
 func MockCodec() *codec.Codec {
@@ -33,5 +83,28 @@ func ParseResponse(cdc *codec.Codec, raw string) (*SendResponse, error) {
 	if out.Error != "" {
 		return nil, errors.New(out.Error)
 	}
+	for _, attr := range out.Log {
+		if attr.Key == "" {
+			return nil, errors.New("log attribute without key")
+		}
+	}
 	return &out, nil
 }
+
+// QueryResponse is the json response of the query entry point
+type QueryResponse struct {
+	Error  string          `json:"error"`
+	Result json.RawMessage `json:"result"`
+}
+
+func ParseQueryResponse(raw string) ([]byte, error) {
+	var out QueryResponse
+	err := json.Unmarshal([]byte(raw), &out)
+	if err != nil {
+		return nil, err
+	}
+	if out.Error != "" {
+		return nil, errors.New(out.Error)
+	}
+	return out.Result, nil
+}
@@ -1,8 +1,13 @@
 package delegation
 
 import (
+	"fmt"
+	"time"
+
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/x/bank"
+	"github.com/cosmos/cosmos-sdk/x/gov"
+	"github.com/cosmos/cosmos-sdk/x/staking"
 	abci "github.com/tendermint/tendermint/abci/types"
 )
 
@@ -34,6 +39,98 @@ func (cap SendCapability) Accept(msg sdk.Msg, block abci.Header) (allow bool, up
 	return false, nil, false
 }
 
+// GenericCapability allows any msg with the given route and type, without
+// restricting its contents
+type GenericCapability struct {
+	Route string
+	Type  string
+}
+
+var _ Capability = GenericCapability{}
+
+func (cap GenericCapability) MsgType() sdk.Msg {
+	return msgType{route: cap.Route, typ: cap.Type}
+}
+
+func (cap GenericCapability) Accept(msg sdk.Msg, block abci.Header) (allow bool, updated Capability, delete bool) {
+	return msg.Route() == cap.Route && msg.Type() == cap.Type, nil, false
+}
+
+// StakingDelegateCapability allows delegating tokens to validators
+type StakingDelegateCapability struct {
+	// Validators are the validators tokens can be delegated to. If it is
+	// empty, tokens can be delegated to any validator.
+	Validators []sdk.ValAddress
+	// MaxAmount specifies the maximum amount of tokens that can be delegated
+	// by this capability and will be updated as tokens are delegated. If it
+	// is nil, any amount can be delegated.
+	MaxAmount *sdk.Coin
+}
+
+var _ Capability = StakingDelegateCapability{}
+
+func (cap StakingDelegateCapability) MsgType() sdk.Msg {
+	return staking.MsgDelegate{}
+}
+
+func (cap StakingDelegateCapability) Accept(msg sdk.Msg, block abci.Header) (allow bool, updated Capability, delete bool) {
+	switch msg := msg.(type) {
+	case staking.MsgDelegate:
+		if len(cap.Validators) != 0 && !containsValAddress(cap.Validators, msg.ValidatorAddress) {
+			return false, nil, false
+		}
+		if cap.MaxAmount == nil {
+			return true, nil, false
+		}
+		if msg.Amount.Denom != cap.MaxAmount.Denom || msg.Amount.Amount.GT(cap.MaxAmount.Amount) {
+			return false, nil, false
+		}
+		left := cap.MaxAmount.Sub(msg.Amount)
+		if left.IsZero() {
+			return true, nil, true
+		}
+		return true, StakingDelegateCapability{Validators: cap.Validators, MaxAmount: &left}, false
+	}
+	return false, nil, false
+}
+
+func containsValAddress(addrs []sdk.ValAddress, addr sdk.ValAddress) bool {
+	for _, a := range addrs {
+		if a.Equals(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// GovVoteCapability allows voting on governance proposals
+type GovVoteCapability struct {
+	// ProposalIDs are the proposals that can be voted on. If it is empty,
+	// any proposal can be voted on.
+	ProposalIDs []uint64
+}
+
+var _ Capability = GovVoteCapability{}
+
+func (cap GovVoteCapability) MsgType() sdk.Msg {
+	return gov.MsgVote{}
+}
+
+func (cap GovVoteCapability) Accept(msg sdk.Msg, block abci.Header) (allow bool, updated Capability, delete bool) {
+	switch msg := msg.(type) {
+	case gov.MsgVote:
+		if len(cap.ProposalIDs) == 0 {
+			return true, nil, false
+		}
+		for _, id := range cap.ProposalIDs {
+			if id == msg.ProposalID {
+				return true, nil, false
+			}
+		}
+	}
+	return false, nil, false
+}
+
 type BasicFeeAllowance struct {
 	// SpendLimit specifies the maximum amount of tokens that can be spent
 	// by this capability and will be updated as tokens are spent. If it is
@@ -43,7 +140,7 @@ type BasicFeeAllowance struct {
 
 var _ FeeAllowance = BasicFeeAllowance{}
 
-func (cap BasicFeeAllowance) Accept(fee sdk.Coins, block abci.Header) (allow bool, updated FeeAllowance, delete bool) {
+func (cap BasicFeeAllowance) Accept(fee sdk.Coins, msgs []sdk.Msg, block abci.Header) (allow bool, updated FeeAllowance, delete bool) {
 	left, invalid := cap.SpendLimit.SafeSub(fee)
 	if invalid {
 		return false, nil, false
@@ -54,3 +151,154 @@ func (cap BasicFeeAllowance) Accept(fee sdk.Coins, block abci.Header) (allow boo
 	return true, BasicFeeAllowance{SpendLimit: left}, false
 }
 
+func (cap BasicFeeAllowance) Remaining(block abci.Header) sdk.Coins {
+	return cap.SpendLimit
+}
+
+// PeriodicFeeAllowance allows spending up to PeriodSpendLimit every Period,
+// optionally within a lifetime SpendLimit
+type PeriodicFeeAllowance struct {
+	// SpendLimit specifies the maximum amount of tokens that can be spent
+	// by this allowance over its lifetime and will be updated as tokens are
+	// spent. If it is empty, only the period limit applies.
+	SpendLimit sdk.Coins
+	// Period is the duration after which PeriodCanSpend is replenished
+	Period time.Duration
+	// PeriodSpendLimit is the amount of tokens that can be spent per period
+	PeriodSpendLimit sdk.Coins
+	// PeriodCanSpend is the amount of tokens left in the current period
+	PeriodCanSpend sdk.Coins
+	// PeriodReset is the block time at which the current period ends. If it
+	// is zero, the first period starts with the first fee that is paid.
+	PeriodReset time.Time
+	// CarryOver keeps the tokens that weren't spent in a period for the
+	// following periods, otherwise PeriodCanSpend is reset to
+	// PeriodSpendLimit
+	CarryOver bool
+	// MaxCarryOver is the maximum amount of unspent tokens that is kept on
+	// top of PeriodSpendLimit when CarryOver is set. If it is empty, at most
+	// one PeriodSpendLimit is carried over.
+	MaxCarryOver sdk.Coins
+}
+
+var _ FeeAllowance = PeriodicFeeAllowance{}
+
+func (cap PeriodicFeeAllowance) Accept(fee sdk.Coins, msgs []sdk.Msg, block abci.Header) (allow bool, updated FeeAllowance, delete bool) {
+	if cap.Period <= 0 {
+		return false, nil, false
+	}
+	cap.tryResetPeriod(block.Time)
+
+	canSpend, invalid := cap.PeriodCanSpend.SafeSub(fee)
+	if invalid {
+		return false, nil, false
+	}
+	cap.PeriodCanSpend = canSpend
+
+	if !cap.SpendLimit.Empty() {
+		left, invalid := cap.SpendLimit.SafeSub(fee)
+		if invalid {
+			return false, nil, false
+		}
+		if left.IsZero() {
+			return true, nil, true
+		}
+		cap.SpendLimit = left
+	}
+	return true, cap, false
+}
+
+// Remaining returns what is left of the period limit, reduced to what is left
+// of the lifetime limit
+func (cap PeriodicFeeAllowance) Remaining(block abci.Header) sdk.Coins {
+	if cap.Period <= 0 {
+		return nil
+	}
+	cap.tryResetPeriod(block.Time)
+	if cap.SpendLimit.Empty() {
+		return cap.PeriodCanSpend
+	}
+	var remaining sdk.Coins
+	for _, coin := range cap.PeriodCanSpend {
+		amount := sdk.MinInt(coin.Amount, cap.SpendLimit.AmountOf(coin.Denom))
+		remaining = remaining.Add(sdk.NewCoins(sdk.NewCoin(coin.Denom, amount)))
+	}
+	return remaining
+}
+
+// tryResetPeriod replenishes PeriodCanSpend for every period that ended
+// before blockTime
+func (cap *PeriodicFeeAllowance) tryResetPeriod(blockTime time.Time) {
+	if cap.PeriodReset.IsZero() {
+		cap.PeriodCanSpend = cap.PeriodSpendLimit
+		cap.PeriodReset = blockTime.Add(cap.Period)
+		return
+	}
+	if blockTime.Before(cap.PeriodReset) {
+		return
+	}
+	periods := int64(blockTime.Sub(cap.PeriodReset)/cap.Period) + 1
+	if cap.CarryOver {
+		maxCarryOver := cap.MaxCarryOver
+		if maxCarryOver.Empty() {
+			maxCarryOver = cap.PeriodSpendLimit
+		}
+		var canSpend sdk.Coins
+		for _, coin := range cap.PeriodSpendLimit {
+			amount := cap.PeriodCanSpend.AmountOf(coin.Denom).Add(coin.Amount.MulRaw(periods))
+			max := coin.Amount.Add(maxCarryOver.AmountOf(coin.Denom))
+			canSpend = canSpend.Add(sdk.NewCoins(sdk.NewCoin(coin.Denom, sdk.MinInt(amount, max))))
+		}
+		cap.PeriodCanSpend = canSpend
+	} else {
+		cap.PeriodCanSpend = cap.PeriodSpendLimit
+	}
+	cap.PeriodReset = cap.PeriodReset.Add(time.Duration(periods) * cap.Period)
+}
+
+// MsgFeeAllowance restricts Allowance to transactions that only contain msgs
+// of the given types
+type MsgFeeAllowance struct {
+	Allowance FeeAllowance
+	// MsgTypes are the allowed msgs, formatted as route/type, for example
+	// bank/send
+	MsgTypes []string
+}
+
+var _ FeeAllowance = MsgFeeAllowance{}
+
+func (cap MsgFeeAllowance) Accept(fee sdk.Coins, msgs []sdk.Msg, block abci.Header) (allow bool, updated FeeAllowance, delete bool) {
+	if cap.Allowance == nil || len(msgs) == 0 {
+		return false, nil, false
+	}
+	for _, msg := range msgs {
+		if !cap.allows(msg) {
+			return false, nil, false
+		}
+	}
+	allow, updatedAllowance, delete := cap.Allowance.Accept(fee, msgs, block)
+	if !allow || delete {
+		return allow, nil, delete
+	}
+	if updatedAllowance != nil {
+		updated = MsgFeeAllowance{Allowance: updatedAllowance, MsgTypes: cap.MsgTypes}
+	}
+	return true, updated, false
+}
+
+func (cap MsgFeeAllowance) Remaining(block abci.Header) sdk.Coins {
+	if cap.Allowance == nil {
+		return nil
+	}
+	return cap.Allowance.Remaining(block)
+}
+
+func (cap MsgFeeAllowance) allows(msg sdk.Msg) bool {
+	typ := fmt.Sprintf("%s/%s", msg.Route(), msg.Type())
+	for _, t := range cap.MsgTypes {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,319 @@
+package delegation_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/bank"
+	"github.com/cosmos/cosmos-sdk/x/delegation"
+	"github.com/cosmos/cosmos-sdk/x/gov"
+	"github.com/cosmos/cosmos-sdk/x/staking"
+)
+
+func trees(amount int64) sdk.Coins {
+	return sdk.NewCoins(sdk.NewInt64Coin("tree", amount))
+}
+
+func TestPeriodicFeeAllowance(t *testing.T) {
+	now := time.Date(2019, 8, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	cases := map[string]struct {
+		allowance delegation.PeriodicFeeAllowance
+		fee       sdk.Coins
+		blockTime time.Time
+		allow     bool
+		updated   delegation.FeeAllowance
+		delete    bool
+	}{
+		"first period starts with the first fee": {
+			allowance: delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10)},
+			fee:       trees(3),
+			blockTime: now,
+			allow:     true,
+			updated:   delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10), PeriodCanSpend: trees(7), PeriodReset: now.Add(day)},
+		},
+		"over the period limit": {
+			allowance: delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10)},
+			fee:       trees(11),
+			blockTime: now,
+		},
+		"within the period": {
+			allowance: delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10), PeriodCanSpend: trees(7), PeriodReset: now.Add(day)},
+			fee:       trees(7),
+			blockTime: now.Add(time.Hour),
+			allow:     true,
+			updated:   delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10), PeriodCanSpend: nil, PeriodReset: now.Add(day)},
+		},
+		"spent in the period": {
+			allowance: delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10), PeriodCanSpend: trees(2), PeriodReset: now.Add(day)},
+			fee:       trees(3),
+			blockTime: now.Add(day - time.Second),
+		},
+		"reset at the end of the period": {
+			allowance: delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10), PeriodCanSpend: trees(2), PeriodReset: now.Add(day)},
+			fee:       trees(3),
+			blockTime: now.Add(day),
+			allow:     true,
+			updated:   delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10), PeriodCanSpend: trees(7), PeriodReset: now.Add(2 * day)},
+		},
+		"reset after several periods": {
+			allowance: delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10), PeriodCanSpend: trees(2), PeriodReset: now.Add(day)},
+			fee:       trees(11),
+			blockTime: now.Add(3*day + time.Hour),
+		},
+		"carry over": {
+			allowance: delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10), PeriodCanSpend: trees(2), PeriodReset: now.Add(day), CarryOver: true},
+			fee:       trees(8),
+			blockTime: now.Add(day),
+			allow:     true,
+			updated:   delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10), PeriodCanSpend: trees(4), PeriodReset: now.Add(2 * day), CarryOver: true},
+		},
+		"carry over at most one period": {
+			allowance: delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10), PeriodCanSpend: trees(2), PeriodReset: now.Add(day), CarryOver: true},
+			fee:       trees(21),
+			blockTime: now.Add(3*day + time.Hour),
+		},
+		"carry over up to one period": {
+			allowance: delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10), PeriodCanSpend: trees(2), PeriodReset: now.Add(day), CarryOver: true},
+			fee:       trees(15),
+			blockTime: now.Add(3*day + time.Hour),
+			allow:     true,
+			updated:   delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10), PeriodCanSpend: trees(5), PeriodReset: now.Add(4 * day), CarryOver: true},
+		},
+		"carry over up to max": {
+			allowance: delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10), PeriodCanSpend: trees(2), PeriodReset: now.Add(day), CarryOver: true, MaxCarryOver: trees(25)},
+			fee:       trees(25),
+			blockTime: now.Add(3*day + time.Hour),
+			allow:     true,
+			updated:   delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10), PeriodCanSpend: trees(7), PeriodReset: now.Add(4 * day), CarryOver: true, MaxCarryOver: trees(25)},
+		},
+		"carry over beyond max": {
+			allowance: delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10), PeriodCanSpend: trees(2), PeriodReset: now.Add(day), CarryOver: true, MaxCarryOver: trees(15)},
+			fee:       trees(26),
+			blockTime: now.Add(3*day + time.Hour),
+		},
+		"lifetime limit": {
+			allowance: delegation.PeriodicFeeAllowance{SpendLimit: trees(5), Period: day, PeriodSpendLimit: trees(10)},
+			fee:       trees(6),
+			blockTime: now,
+		},
+		"lifetime limit spent": {
+			allowance: delegation.PeriodicFeeAllowance{SpendLimit: trees(5), Period: day, PeriodSpendLimit: trees(10)},
+			fee:       trees(5),
+			blockTime: now,
+			allow:     true,
+			delete:    true,
+		},
+		"lifetime limit left": {
+			allowance: delegation.PeriodicFeeAllowance{SpendLimit: trees(20), Period: day, PeriodSpendLimit: trees(10)},
+			fee:       trees(5),
+			blockTime: now,
+			allow:     true,
+			updated:   delegation.PeriodicFeeAllowance{SpendLimit: trees(15), Period: day, PeriodSpendLimit: trees(10), PeriodCanSpend: trees(5), PeriodReset: now.Add(day)},
+		},
+		"no period": {
+			allowance: delegation.PeriodicFeeAllowance{PeriodSpendLimit: trees(10)},
+			fee:       trees(1),
+			blockTime: now,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			allow, updated, del := tc.allowance.Accept(tc.fee, nil, abci.Header{Time: tc.blockTime})
+			require.Equal(t, tc.allow, allow)
+			require.Equal(t, tc.updated, updated)
+			require.Equal(t, tc.delete, del)
+		})
+	}
+}
+
+func TestMsgFeeAllowance(t *testing.T) {
+	send := bank.MsgSend{}
+	multiSend := bank.MsgMultiSend{}
+	allowance := delegation.MsgFeeAllowance{
+		Allowance: delegation.BasicFeeAllowance{SpendLimit: trees(10)},
+		MsgTypes:  []string{"bank/send"},
+	}
+
+	cases := map[string]struct {
+		allowance delegation.MsgFeeAllowance
+		fee       sdk.Coins
+		msgs      []sdk.Msg
+		allow     bool
+		updated   delegation.FeeAllowance
+		delete    bool
+	}{
+		"allowed msg": {
+			allowance: allowance,
+			fee:       trees(3),
+			msgs:      []sdk.Msg{send, send},
+			allow:     true,
+			updated:   delegation.MsgFeeAllowance{Allowance: delegation.BasicFeeAllowance{SpendLimit: trees(7)}, MsgTypes: []string{"bank/send"}},
+		},
+		"other msg": {
+			allowance: allowance,
+			fee:       trees(3),
+			msgs:      []sdk.Msg{multiSend},
+		},
+		"allowed and other msg": {
+			allowance: allowance,
+			fee:       trees(3),
+			msgs:      []sdk.Msg{send, multiSend},
+		},
+		"no msgs": {
+			allowance: allowance,
+			fee:       trees(3),
+		},
+		"over the limit": {
+			allowance: allowance,
+			fee:       trees(11),
+			msgs:      []sdk.Msg{send},
+		},
+		"limit spent": {
+			allowance: allowance,
+			fee:       trees(10),
+			msgs:      []sdk.Msg{send},
+			allow:     true,
+			delete:    true,
+		},
+		"no allowance": {
+			allowance: delegation.MsgFeeAllowance{MsgTypes: []string{"bank/send"}},
+			fee:       trees(1),
+			msgs:      []sdk.Msg{send},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			allow, updated, del := tc.allowance.Accept(tc.fee, tc.msgs, abci.Header{})
+			require.Equal(t, tc.allow, allow)
+			require.Equal(t, tc.updated, updated)
+			require.Equal(t, tc.delete, del)
+		})
+	}
+}
+
+func TestFeeAllowanceRemaining(t *testing.T) {
+	now := time.Date(2019, 8, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	cases := map[string]struct {
+		allowance delegation.FeeAllowance
+		remaining sdk.Coins
+	}{
+		"basic": {
+			allowance: delegation.BasicFeeAllowance{SpendLimit: trees(10)},
+			remaining: trees(10),
+		},
+		"period not started": {
+			allowance: delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10)},
+			remaining: trees(10),
+		},
+		"within the period": {
+			allowance: delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10), PeriodCanSpend: trees(4), PeriodReset: now.Add(time.Hour)},
+			remaining: trees(4),
+		},
+		"after the period": {
+			allowance: delegation.PeriodicFeeAllowance{Period: day, PeriodSpendLimit: trees(10), PeriodCanSpend: trees(4), PeriodReset: now},
+			remaining: trees(10),
+		},
+		"lifetime limit": {
+			allowance: delegation.PeriodicFeeAllowance{SpendLimit: trees(3), Period: day, PeriodSpendLimit: trees(10)},
+			remaining: trees(3),
+		},
+		"msg": {
+			allowance: delegation.MsgFeeAllowance{Allowance: delegation.BasicFeeAllowance{SpendLimit: trees(10)}, MsgTypes: []string{"bank/send"}},
+			remaining: trees(10),
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.remaining, tc.allowance.Remaining(abci.Header{Time: now}))
+		})
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	val1 := sdk.ValAddress([]byte("validator1__________"))
+	val2 := sdk.ValAddress([]byte("validator2__________"))
+	stake := func(amount int64) *sdk.Coin {
+		coin := sdk.NewInt64Coin("stake", amount)
+		return &coin
+	}
+	delegate := func(val sdk.ValAddress, amount int64) sdk.Msg {
+		return staking.MsgDelegate{ValidatorAddress: val, Amount: *stake(amount)}
+	}
+
+	cases := map[string]struct {
+		capability delegation.Capability
+		msg        sdk.Msg
+		allow      bool
+		updated    delegation.Capability
+		delete     bool
+	}{
+		"generic": {
+			capability: delegation.GenericCapability{Route: "bank", Type: "send"},
+			msg:        bank.MsgSend{},
+			allow:      true,
+		},
+		"generic other type": {
+			capability: delegation.GenericCapability{Route: "bank", Type: "send"},
+			msg:        bank.MsgMultiSend{},
+		},
+		"delegate to any validator": {
+			capability: delegation.StakingDelegateCapability{},
+			msg:        delegate(val2, 1000),
+			allow:      true,
+		},
+		"delegate to allowed validator": {
+			capability: delegation.StakingDelegateCapability{Validators: []sdk.ValAddress{val1}, MaxAmount: stake(100)},
+			msg:        delegate(val1, 40),
+			allow:      true,
+			updated:    delegation.StakingDelegateCapability{Validators: []sdk.ValAddress{val1}, MaxAmount: stake(60)},
+		},
+		"delegate max amount": {
+			capability: delegation.StakingDelegateCapability{Validators: []sdk.ValAddress{val1}, MaxAmount: stake(100)},
+			msg:        delegate(val1, 100),
+			allow:      true,
+			delete:     true,
+		},
+		"delegate over max amount": {
+			capability: delegation.StakingDelegateCapability{MaxAmount: stake(100)},
+			msg:        delegate(val1, 101),
+		},
+		"delegate other denom": {
+			capability: delegation.StakingDelegateCapability{MaxAmount: stake(100)},
+			msg:        staking.MsgDelegate{ValidatorAddress: val1, Amount: sdk.NewInt64Coin("tree", 1)},
+		},
+		"delegate to other validator": {
+			capability: delegation.StakingDelegateCapability{Validators: []sdk.ValAddress{val1}},
+			msg:        delegate(val2, 1),
+		},
+		"vote on any proposal": {
+			capability: delegation.GovVoteCapability{},
+			msg:        gov.MsgVote{ProposalID: 3},
+			allow:      true,
+		},
+		"vote on allowed proposal": {
+			capability: delegation.GovVoteCapability{ProposalIDs: []uint64{1, 3}},
+			msg:        gov.MsgVote{ProposalID: 3},
+			allow:      true,
+		},
+		"vote on other proposal": {
+			capability: delegation.GovVoteCapability{ProposalIDs: []uint64{1, 3}},
+			msg:        gov.MsgVote{ProposalID: 2},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			allow, updated, del := tc.capability.Accept(tc.msg, abci.Header{})
+			require.Equal(t, tc.allow, allow)
+			require.Equal(t, tc.updated, updated)
+			require.Equal(t, tc.delete, del)
+		})
+	}
+}
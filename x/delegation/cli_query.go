@@ -18,6 +18,9 @@ func GetQueryCmd(queryRoute string, cdc *codec.Codec) *cobra.Command {
 
 	cmd.AddCommand(client.GetCommands(
 		GetCmdGetFeeAllowances(queryRoute, cdc),
+		GetCmdGetFeeAllowancesGrantedBy(queryRoute, cdc),
+		GetCmdGetCapabilities(queryRoute, cdc),
+		GetCmdGetCapabilitiesGrantedBy(queryRoute, cdc),
 	)...)
 
 	return cmd
@@ -44,3 +47,66 @@ func GetCmdGetFeeAllowances(queryRoute string, cdc *codec.Codec) *cobra.Command
 		},
 	}
 }
+
+func GetCmdGetFeeAllowancesGrantedBy(queryRoute string, cdc *codec.Codec) *cobra.Command {
+	return &cobra.Command{
+		Use:   "fees-granted-by [address]",
+		Short: "get fee allowances granted by this address and the fees left",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cliCtx := context.NewCLIContext().WithCodec(cdc)
+
+			route := fmt.Sprintf("custom/delegation/%s/%s", QueryGetFeeAllowancesByGranter, args[0])
+			res, err := cliCtx.QueryWithData(route, nil)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(res))
+
+			return nil
+		},
+	}
+}
+
+func GetCmdGetCapabilities(queryRoute string, cdc *codec.Codec) *cobra.Command {
+	return &cobra.Command{
+		Use:   "capabilities [address]",
+		Short: "get capabilities granted to this address",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cliCtx := context.NewCLIContext().WithCodec(cdc)
+
+			route := fmt.Sprintf("custom/delegation/%s/%s", QueryGetCapsByGrantee, args[0])
+			res, err := cliCtx.QueryWithData(route, nil)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(res))
+
+			return nil
+		},
+	}
+}
+
+func GetCmdGetCapabilitiesGrantedBy(queryRoute string, cdc *codec.Codec) *cobra.Command {
+	return &cobra.Command{
+		Use:   "capabilities-granted-by [address]",
+		Short: "get capabilities granted by this address",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cliCtx := context.NewCLIContext().WithCodec(cdc)
+
+			route := fmt.Sprintf("custom/delegation/%s/%s", QueryGetCapsByGranter, args[0])
+			res, err := cliCtx.QueryWithData(route, nil)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(res))
+
+			return nil
+		},
+	}
+}
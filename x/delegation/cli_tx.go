@@ -7,9 +7,12 @@ import (
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/x/auth"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 	"time"
 )
 
+const flagExpiration = "expiration"
+
 func GetCmdExecDelefgated(cdc *codec.Codec) *cobra.Command {
 	var exec bool
 
@@ -31,7 +34,7 @@ func GetCmdExecDelefgated(cdc *codec.Codec) *cobra.Command {
 
 			msg := MsgExecDelegatedAction{
 				Signer: account,
-				Msgs:     []sdk.Msg{action},
+				Msgs:   []sdk.Msg{action},
 			}
 			err = action.ValidateBasic()
 			if err != nil {
@@ -51,7 +54,9 @@ func GetCmdDelegate(cdc *codec.Codec) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "delegate [grantee] [capability]",
 		Short: "Delegate a capability to a grantee",
-		Args:  cobra.ExactArgs(2),
+		Long: `Delegate a capability to a grantee. The capability is JSON encoded, for example
+{"type":"delegation/StakingDelegateCapability","value":{"Validators":["cosmosvaloper1..."],"MaxAmount":{"denom":"stake","amount":"100"}}}`,
+		Args: cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cliCtx := context.NewCLIContext().WithCodec(cdc).WithAccountDecoder(cdc)
 
@@ -74,22 +79,71 @@ func GetCmdDelegate(cdc *codec.Codec) *cobra.Command {
 				return err
 			}
 
-			//var expiration time.Time
-			//expirationStr := cmd.Flags().GetString("expiration")
+			expiration, err := parseExpiration()
+			if err != nil {
+				return err
+			}
+
+			msg := NewMsgDelegate(account, grantee, capability, expiration)
+
+			cliCtx.PrintResponse = true
+
+			return utils.CompleteAndBroadcastTxCLI(txBldr, cliCtx, []sdk.Msg{msg})
+		},
+	}
+	cmd.Flags().String(flagExpiration, "", "The RFC 3339 time at which the delegation expires")
+	return cmd
+}
+
+func GetCmdDelegateGeneric(cdc *codec.Codec) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delegate-generic [grantee] [msg-route] [msg-type]",
+		Short: "Delegate the capability to send any msg of a route and type to a grantee",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cliCtx := context.NewCLIContext().WithCodec(cdc).WithAccountDecoder(cdc)
+
+			txBldr := auth.NewTxBuilderFromCLI().WithTxEncoder(utils.GetTxEncoder(cdc))
+
+			if err := cliCtx.EnsureAccountExists(); err != nil {
+				return err
+			}
+
+			account := cliCtx.GetFromAddress()
+
+			grantee, err := sdk.AccAddressFromBech32(args[0])
+			if err != nil {
+				return err
+			}
+
+			expiration, err := parseExpiration()
+			if err != nil {
+				return err
+			}
 
-			msg := NewMsgDelegate(account, grantee, capability, time.Time{})
+			capability := GenericCapability{Route: args[1], Type: args[2]}
+			msg := NewMsgDelegate(account, grantee, capability, expiration)
 
 			cliCtx.PrintResponse = true
 
 			return utils.CompleteAndBroadcastTxCLI(txBldr, cliCtx, []sdk.Msg{msg})
 		},
 	}
-	cmd.Flags().String("expiration", "", "The expiration data of the delegation")
+	cmd.Flags().String(flagExpiration, "", "The RFC 3339 time at which the delegation expires")
 	return cmd
 }
 
+// parseExpiration reads the expiration flag, which is zero if it isn't set
+func parseExpiration() (time.Time, error) {
+	s := viper.GetString(flagExpiration)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
 func GetCmdDelegateFees(cdc *codec.Codec) *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "delegate-fees [grantee] [fee-allowance]",
 		Short: "delegate-fees",
 		Args:  cobra.ExactArgs(2),
@@ -115,11 +169,18 @@ func GetCmdDelegateFees(cdc *codec.Codec) *cobra.Command {
 				return err
 			}
 
-			msg := NewMsgDelegateFeeAllowance(account, grantee, allowance)
+			expiration, err := parseExpiration()
+			if err != nil {
+				return err
+			}
+
+			msg := NewMsgDelegateFeeAllowance(account, grantee, allowance, expiration)
 
 			cliCtx.PrintResponse = true
 
 			return utils.CompleteAndBroadcastTxCLI(txBldr, cliCtx, []sdk.Msg{msg})
 		},
 	}
+	cmd.Flags().String(flagExpiration, "", "The RFC 3339 time at which the fee allowance expires")
+	return cmd
 }
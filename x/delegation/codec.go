@@ -13,7 +13,13 @@ func RegisterCodec(cdc *codec.Codec) {
 	cdc.RegisterConcrete(MsgRevokeFeeAllowance{}, "delegation/MsgRevokeFeeAllowance", nil)
 	cdc.RegisterConcrete(capabilityGrant{}, "delegation/capabilityGrant", nil)
 	cdc.RegisterConcrete(SendCapability{}, "delegation/SendCapability", nil)
+	cdc.RegisterConcrete(GenericCapability{}, "delegation/GenericCapability", nil)
+	cdc.RegisterConcrete(StakingDelegateCapability{}, "delegation/StakingDelegateCapability", nil)
+	cdc.RegisterConcrete(GovVoteCapability{}, "delegation/GovVoteCapability", nil)
+	cdc.RegisterConcrete(feeAllowanceGrant{}, "delegation/feeAllowanceGrant", nil)
 	cdc.RegisterConcrete(BasicFeeAllowance{}, "delegation/BasicFeeAllowance", nil)
+	cdc.RegisterConcrete(PeriodicFeeAllowance{}, "delegation/PeriodicFeeAllowance", nil)
+	cdc.RegisterConcrete(MsgFeeAllowance{}, "delegation/MsgFeeAllowance", nil)
 	cdc.RegisterInterface((*Capability)(nil), nil)
 	cdc.RegisterInterface((*FeeAllowance)(nil), nil)
 }
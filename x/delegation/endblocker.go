@@ -0,0 +1,15 @@
+package delegation
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// EndBlocker removes the capabilities and fee allowances that expired before
+// the time of the block, instead of waiting for them to be used
+func EndBlocker(ctx sdk.Context, k Keeper) sdk.Tags {
+	resTags := sdk.NewTags()
+	for _, key := range k.expiredGrantKeys(ctx) {
+		resTags = resTags.AppendTags(k.removeExpiredGrant(ctx, key))
+	}
+	return resTags
+}
@@ -10,18 +10,26 @@ func NewHandler(k Keeper) sdk.Handler {
 		switch msg := msg.(type) {
 		case MsgDelegate:
 			k.Delegate(ctx, msg.Grantee, msg.Granter, msg.Capability, msg.Expiration)
-			return sdk.Result{}
+			return sdk.Result{
+				Tags: capabilityTags(ActionCapabilityGranted, msg.Grantee, msg.Granter, msg.Capability.MsgType()),
+			}
 		case MsgExecDelegatedAction:
 			return k.DispatchActions(ctx, msg.Signer, msg.Msgs)
 		case MsgRevoke:
 			k.Revoke(ctx, msg.Grantee, msg.Granter, msg.MsgType)
-			return sdk.Result{}
+			return sdk.Result{
+				Tags: capabilityTags(ActionCapabilityRevoked, msg.Grantee, msg.Granter, msg.MsgType),
+			}
 		case MsgDelegateFeeAllowance:
-			k.DelegateFeeAllowance(ctx, msg.Grantee, msg.Granter, msg.Allowance)
-			return sdk.Result{}
+			k.DelegateFeeAllowance(ctx, msg.Grantee, msg.Granter, msg.Allowance, msg.Expiration)
+			return sdk.Result{
+				Tags: feeAllowanceTags(ActionFeeAllowanceGranted, msg.Grantee, msg.Granter),
+			}
 		case MsgRevokeFeeAllowance:
 			k.RevokeFeeAllowance(ctx, msg.Grantee, msg.Granter)
-			return sdk.Result{}
+			return sdk.Result{
+				Tags: feeAllowanceTags(ActionFeeAllowanceRevoked, msg.Grantee, msg.Granter),
+			}
 		default:
 			errMsg := fmt.Sprintf("Unrecognized data Msg type: %v", msg.Type())
 			return sdk.ErrUnknownRequest(errMsg).Result()
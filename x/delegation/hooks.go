@@ -0,0 +1,55 @@
+package delegation
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// Hooks are called when capabilities and fee allowances are granted or
+// removed, whether revoked, used up or expired
+type Hooks interface {
+	AfterCapabilityGranted(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, capability Capability)
+	AfterCapabilityRevoked(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, msgType sdk.Msg)
+	AfterFeeAllowanceGranted(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, allowance FeeAllowance)
+	AfterFeeAllowanceRevoked(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress)
+}
+
+// AddHooks lets hooks react to changes of grants
+func (k Keeper) AddHooks(hooks Hooks) {
+	*k.hooks = append(*k.hooks, hooks)
+}
+
+func (k Keeper) afterCapabilityGranted(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, capability Capability) {
+	if k.hooks == nil {
+		return
+	}
+	for _, h := range *k.hooks {
+		h.AfterCapabilityGranted(ctx, grantee, granter, capability)
+	}
+}
+
+func (k Keeper) afterCapabilityRevoked(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, msgType sdk.Msg) {
+	if k.hooks == nil {
+		return
+	}
+	for _, h := range *k.hooks {
+		h.AfterCapabilityRevoked(ctx, grantee, granter, msgType)
+	}
+}
+
+func (k Keeper) afterFeeAllowanceGranted(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, allowance FeeAllowance) {
+	if k.hooks == nil {
+		return
+	}
+	for _, h := range *k.hooks {
+		h.AfterFeeAllowanceGranted(ctx, grantee, granter, allowance)
+	}
+}
+
+func (k Keeper) afterFeeAllowanceRevoked(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress) {
+	if k.hooks == nil {
+		return
+	}
+	for _, h := range *k.hooks {
+		h.AfterFeeAllowanceRevoked(ctx, grantee, granter)
+	}
+}
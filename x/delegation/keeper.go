@@ -3,6 +3,7 @@ package delegation
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/cosmos/cosmos-sdk/codec"
@@ -13,6 +14,11 @@ type Keeper struct {
 	storeKey sdk.StoreKey
 	cdc      *codec.Codec
 	router   sdk.Router
+	// authorizers are shared by all copies of the keeper, so they can be
+	// added once the modules implementing them have been created
+	authorizers *[]Authorizer
+	// hooks are shared in the same way as authorizers
+	hooks *[]Hooks
 }
 
 type capabilityGrant struct {
@@ -22,7 +28,13 @@ type capabilityGrant struct {
 }
 
 func NewKeeper(storeKey sdk.StoreKey, cdc *codec.Codec, router sdk.Router) Keeper {
-	return Keeper{storeKey, cdc, router}
+	return Keeper{storeKey, cdc, router, &[]Authorizer{}, &[]Hooks{}}
+}
+
+// AddAuthorizer lets authorizer allow the sender of DispatchActions to act
+// for signers it has no capability from
+func (k Keeper) AddAuthorizer(authorizer Authorizer) {
+	*k.authorizers = append(*k.authorizers, authorizer)
 }
 
 func ActorCapabilityKey(grantee sdk.AccAddress, granter sdk.AccAddress, msg sdk.Msg) []byte {
@@ -33,10 +45,48 @@ func actorCapabilityKey(grantee sdk.AccAddress, granter sdk.AccAddress, route, t
 	return []byte(fmt.Sprintf("c/%x/%x/%s/%s", grantee, granter, route, typ))
 }
 
+// granterCapabilityKey indexes the grants of a granter, the value is empty
+// and the grant is stored under actorCapabilityKey
+func granterCapabilityKey(granter sdk.AccAddress, grantee sdk.AccAddress, route, typ string) []byte {
+	return []byte(fmt.Sprintf("cg/%x/%x/%s/%s", granter, grantee, route, typ))
+}
+
 func FeeAllowanceKey(grantee sdk.AccAddress, granter sdk.AccAddress) []byte {
 	return []byte(fmt.Sprintf("f/%x/%x", grantee, granter))
 }
 
+// granterFeeAllowanceKey indexes the fee allowances of a granter, the value
+// is empty and the allowance is stored under FeeAllowanceKey
+func granterFeeAllowanceKey(granter sdk.AccAddress, grantee sdk.AccAddress) []byte {
+	return []byte(fmt.Sprintf("fg/%x/%x", granter, grantee))
+}
+
+// expirationQueuePrefix orders the keys of grants that expire by their
+// expiration, so EndBlocker can remove them once they expired
+var expirationQueuePrefix = []byte("q/")
+
+func expirationQueueTimeKey(expiration time.Time) []byte {
+	return append(append([]byte{}, expirationQueuePrefix...), sdk.FormatTimeBytes(expiration)...)
+}
+
+// expirationQueueKey queues the grant stored under grantKey, the value is
+// empty
+func expirationQueueKey(expiration time.Time, grantKey []byte) []byte {
+	return append(expirationQueueTimeKey(expiration), grantKey...)
+}
+
+// queueExpiration moves the grant stored under grantKey in the expiration
+// queue from oldExpiration to expiration, grants that never expire aren't
+// queued
+func queueExpiration(store sdk.KVStore, grantKey []byte, oldExpiration time.Time, expiration time.Time) {
+	if !oldExpiration.IsZero() {
+		store.Delete(expirationQueueKey(oldExpiration, grantKey))
+	}
+	if !expiration.IsZero() {
+		store.Set(expirationQueueKey(expiration, grantKey), []byte{})
+	}
+}
+
 // grantee sdk.AccAddress, granter sdk.AccAddress, msgType sdk.Msg
 func (k Keeper) getCapabilityGrant(ctx sdk.Context, actor []byte) (grant capabilityGrant, found bool) {
 	store := ctx.KVStore(k.storeKey)
@@ -51,21 +101,109 @@ func (k Keeper) getCapabilityGrant(ctx sdk.Context, actor []byte) (grant capabil
 func (k Keeper) Delegate(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, capability Capability, expiration time.Time) {
 	store := ctx.KVStore(k.storeKey)
 	bz := k.cdc.MustMarshalBinaryBare(capabilityGrant{capability, expiration})
-	actor := ActorCapabilityKey(grantee, granter, capability.MsgType())
+	msg := capability.MsgType()
+	actor := ActorCapabilityKey(grantee, granter, msg)
+	old, _ := k.getCapabilityGrant(ctx, actor)
 	store.Set(actor, bz)
+	store.Set(granterCapabilityKey(granter, grantee, msg.Route(), msg.Type()), []byte{})
+	queueExpiration(store, actor, old.Expiration, expiration)
+	k.afterCapabilityGranted(ctx, grantee, granter, capability)
 }
 
+// update replaces the capability of an existing grant, keeping its expiration
 func (k Keeper) update(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, updated Capability) {
-	grant, found := k.getCapabilityGrant(ctx, ActorCapabilityKey(grantee, granter, updated.MsgType()))
+	actor := ActorCapabilityKey(grantee, granter, updated.MsgType())
+	grant, found := k.getCapabilityGrant(ctx, actor)
 	if !found {
 		return
 	}
 	grant.Capability = updated
+	ctx.KVStore(k.storeKey).Set(actor, k.cdc.MustMarshalBinaryBare(grant))
 }
 
 func (k Keeper) Revoke(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, msgType sdk.Msg) {
 	store := ctx.KVStore(k.storeKey)
-	store.Delete(ActorCapabilityKey(grantee, granter, msgType))
+	actor := ActorCapabilityKey(grantee, granter, msgType)
+	grant, found := k.getCapabilityGrant(ctx, actor)
+	if !found {
+		return
+	}
+	store.Delete(actor)
+	store.Delete(granterCapabilityKey(granter, grantee, msgType.Route(), msgType.Type()))
+	queueExpiration(store, actor, grant.Expiration, time.Time{})
+	k.afterCapabilityRevoked(ctx, grantee, granter, msgType)
+}
+
+// CapabilityGrant is a capability along with the accounts it was granted by
+// and to
+type CapabilityGrant struct {
+	Grantee    sdk.AccAddress `json:"grantee"`
+	Granter    sdk.AccAddress `json:"granter"`
+	Capability Capability     `json:"capability"`
+	Expiration time.Time      `json:"expiration"`
+}
+
+// GetCapabilityGrants returns the unexpired capabilities held by grantee
+func (k Keeper) GetCapabilityGrants(ctx sdk.Context, grantee sdk.AccAddress) []CapabilityGrant {
+	prefix := fmt.Sprintf("c/%x/", grantee)
+	iter := sdk.KVStorePrefixIterator(ctx.KVStore(k.storeKey), []byte(prefix))
+	defer iter.Close()
+
+	var grants []CapabilityGrant
+	for ; iter.Valid(); iter.Next() {
+		parts := strings.SplitN(string(iter.Key()[len(prefix):]), "/", 2)
+		granter, err := sdk.AccAddressFromHex(parts[0])
+		if err != nil {
+			panic(err)
+		}
+		var grant capabilityGrant
+		k.cdc.MustUnmarshalBinaryBare(iter.Value(), &grant)
+		if isExpired(ctx, grant) {
+			continue
+		}
+		grants = append(grants, CapabilityGrant{
+			Grantee:    grantee,
+			Granter:    granter,
+			Capability: grant.Capability,
+			Expiration: grant.Expiration,
+		})
+	}
+	return grants
+}
+
+// GetCapabilityGrantsByGranter returns the unexpired capabilities granted by
+// granter
+func (k Keeper) GetCapabilityGrantsByGranter(ctx sdk.Context, granter sdk.AccAddress) []CapabilityGrant {
+	prefix := fmt.Sprintf("cg/%x/", granter)
+	iter := sdk.KVStorePrefixIterator(ctx.KVStore(k.storeKey), []byte(prefix))
+	defer iter.Close()
+
+	var grants []CapabilityGrant
+	for ; iter.Valid(); iter.Next() {
+		parts := strings.SplitN(string(iter.Key()[len(prefix):]), "/", 3)
+		if len(parts) != 3 {
+			panic(fmt.Sprintf("invalid capability index key %s", iter.Key()))
+		}
+		grantee, err := sdk.AccAddressFromHex(parts[0])
+		if err != nil {
+			panic(err)
+		}
+		grant, found := k.getCapabilityGrant(ctx, actorCapabilityKey(grantee, granter, parts[1], parts[2]))
+		if !found || isExpired(ctx, grant) {
+			continue
+		}
+		grants = append(grants, CapabilityGrant{
+			Grantee:    grantee,
+			Granter:    granter,
+			Capability: grant.Capability,
+			Expiration: grant.Expiration,
+		})
+	}
+	return grants
+}
+
+func isExpired(ctx sdk.Context, grant capabilityGrant) bool {
+	return !grant.Expiration.IsZero() && grant.Expiration.Before(ctx.BlockHeader().Time)
 }
 
 func (k Keeper) GetCapability(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, msgType sdk.Msg) Capability {
@@ -73,101 +211,282 @@ func (k Keeper) GetCapability(ctx sdk.Context, grantee sdk.AccAddress, granter s
 	if !found {
 		return nil
 	}
-	if !grant.Expiration.IsZero() && grant.Expiration.Before(ctx.BlockHeader().Time) {
+	if isExpired(ctx, grant) {
 		k.Revoke(ctx, grantee, granter, msgType)
 		return nil
 	}
 	return grant.Capability
 }
 
+// DispatchActions routes msgs on behalf of sender. Every signer of a msg
+// must be the sender, have granted the sender a capability accepting the msg
+// or authorize the sender through an Authorizer. The data of the result is
+// the JSON encoded list of MsgResults of msgs.
 func (k Keeper) DispatchActions(ctx sdk.Context, sender sdk.AccAddress, msgs []sdk.Msg) sdk.Result {
-	var res sdk.Result
+	tags := sdk.NewTags(
+		TagCategory, TxCategory,
+		TagAction, ActionDelegatedExec,
+		TagGrantee, sender.String(),
+	)
+	var granters []sdk.AccAddress
+	results := make([]MsgResult, 0, len(msgs))
 	for _, msg := range msgs {
 		signers := msg.GetSigners()
-		if len(signers) != 1 {
-			return sdk.ErrUnknownRequest("can only dispatch a delegated msg with 1 signer").Result()
-		}
-		actor := signers[0]
-		if !bytes.Equal(actor, sender) {
-			capability := k.GetCapability(ctx, sender, actor, msg)
-			if capability == nil {
-				return sdk.ErrUnauthorized("unauthorized").Result()
+		if len(signers) == 0 {
+			return sdk.ErrUnknownRequest("can't dispatch a msg without signers").Result()
+		}
+		for i, signer := range signers {
+			if containsAddress(signers[:i], signer) {
+				continue
 			}
-			allow, updated, del := capability.Accept(msg, ctx.BlockHeader())
-			if !allow {
-				return sdk.ErrUnauthorized("unauthorized").Result()
+			if err := k.authorizeSigner(ctx, sender, signer, msg); err != nil {
+				return err.Result()
 			}
-			if del {
-				k.Revoke(ctx, sender, actor, msg)
-			} else if updated != nil {
-				k.update(ctx, sender, actor, updated)
+			if !bytes.Equal(signer, sender) && !containsAddress(granters, signer) {
+				granters = append(granters, signer)
+				tags = tags.AppendTag(TagGranter, signer.String())
 			}
 		}
-		res = k.router.Route(msg.Route())(ctx, msg)
+		handler := k.router.Route(msg.Route())
+		if handler == nil {
+			return sdk.ErrUnknownRequest(fmt.Sprintf("unrecognized msg route %s", msg.Route())).Result()
+		}
+		res := handler(ctx, msg)
 		if !res.IsOK() {
 			return res
 		}
+		tags = tags.AppendTags(res.Tags)
+		results = append(results, MsgResult{Data: res.Data})
+	}
+	return sdk.Result{
+		Data: k.cdc.MustMarshalJSON(results),
+		Tags: tags,
+	}
+}
+
+// authorizeSigner checks that sender can act for signer of msg, updating or
+// revoking the capability that allows it
+func (k Keeper) authorizeSigner(ctx sdk.Context, sender sdk.AccAddress, signer sdk.AccAddress, msg sdk.Msg) sdk.Error {
+	if bytes.Equal(signer, sender) {
+		return nil
+	}
+	if capability := k.GetCapability(ctx, sender, signer, msg); capability != nil {
+		allow, updated, del := capability.Accept(msg, ctx.BlockHeader())
+		if allow {
+			if del {
+				k.Revoke(ctx, sender, signer, msg)
+			} else if updated != nil {
+				k.update(ctx, sender, signer, updated)
+			}
+			return nil
+		}
+	}
+	if k.authorizers != nil {
+		for _, authorizer := range *k.authorizers {
+			if authorizer.Authorize(ctx, signer, []sdk.AccAddress{sender}) {
+				return nil
+			}
+		}
 	}
-	return res
+	return sdk.ErrUnauthorized(fmt.Sprintf("%s is not authorized to act for %s", sender, signer))
 }
 
-func (k Keeper) DelegateFeeAllowance(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, allowance FeeAllowance) {
+func containsAddress(addrs []sdk.AccAddress, addr sdk.AccAddress) bool {
+	for _, a := range addrs {
+		if bytes.Equal(a, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+type feeAllowanceGrant struct {
+	Allowance FeeAllowance
+
+	Expiration time.Time
+}
+
+// DelegateFeeAllowance allows grantee to pay fees from the account of
+// granter until expiration, a zero expiration never expires
+func (k Keeper) DelegateFeeAllowance(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, allowance FeeAllowance, expiration time.Time) {
+	k.setFeeAllowanceGrant(ctx, grantee, granter, feeAllowanceGrant{allowance, expiration})
+	k.afterFeeAllowanceGranted(ctx, grantee, granter, allowance)
+}
+
+func (k Keeper) setFeeAllowanceGrant(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, grant feeAllowanceGrant) {
 	store := ctx.KVStore(k.storeKey)
-	bz := k.cdc.MustMarshalBinaryBare(allowance)
-	store.Set(FeeAllowanceKey(grantee, granter), bz)
+	key := FeeAllowanceKey(grantee, granter)
+	old, _ := k.getFeeAllowanceGrant(ctx, grantee, granter)
+	store.Set(key, k.cdc.MustMarshalBinaryBare(grant))
+	store.Set(granterFeeAllowanceKey(granter, grantee), []byte{})
+	queueExpiration(store, key, old.Expiration, grant.Expiration)
 }
 
 func (k Keeper) RevokeFeeAllowance(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress) {
 	store := ctx.KVStore(k.storeKey)
-	store.Delete(FeeAllowanceKey(grantee, granter))
+	key := FeeAllowanceKey(grantee, granter)
+	grant, found := k.getFeeAllowanceGrant(ctx, grantee, granter)
+	if !found {
+		return
+	}
+	store.Delete(key)
+	store.Delete(granterFeeAllowanceKey(granter, grantee))
+	queueExpiration(store, key, grant.Expiration, time.Time{})
+	k.afterFeeAllowanceRevoked(ctx, grantee, granter)
 }
 
+// FeeAllowanceGrant is a fee allowance along with the accounts it was
+// granted by and to and the fees that can still be spent
 type FeeAllowanceGrant struct {
-	Allowance FeeAllowance   `json:"allowance"`
-	Grantee   sdk.AccAddress `json:"grantee"`
-	Granter   sdk.AccAddress `json:"granter"`
+	Allowance  FeeAllowance   `json:"allowance"`
+	Grantee    sdk.AccAddress `json:"grantee"`
+	Granter    sdk.AccAddress `json:"granter"`
+	Expiration time.Time      `json:"expiration"`
+	Remaining  sdk.Coins      `json:"remaining"`
+}
+
+func (k Keeper) getFeeAllowanceGrant(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress) (grant feeAllowanceGrant, found bool) {
+	bz := ctx.KVStore(k.storeKey).Get(FeeAllowanceKey(grantee, granter))
+	if len(bz) == 0 {
+		return grant, false
+	}
+	k.cdc.MustUnmarshalBinaryBare(bz, &grant)
+	return grant, grant.Allowance != nil
 }
 
+func (k Keeper) newFeeAllowanceGrant(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, grant feeAllowanceGrant) FeeAllowanceGrant {
+	return FeeAllowanceGrant{
+		Allowance:  grant.Allowance,
+		Grantee:    grantee,
+		Granter:    granter,
+		Expiration: grant.Expiration,
+		Remaining:  grant.Allowance.Remaining(ctx.BlockHeader()),
+	}
+}
+
+// GetFeeAllowances returns the unexpired fee allowances granted to grantee
 func (k Keeper) GetFeeAllowances(ctx sdk.Context, grantee sdk.AccAddress) []FeeAllowanceGrant {
-	prefix := fmt.Sprintf("g/%x/", grantee)
-	prefixBytes := []byte(prefix)
-	store := ctx.KVStore(k.storeKey)
+	prefix := fmt.Sprintf("f/%x/", grantee)
+	iter := sdk.KVStorePrefixIterator(ctx.KVStore(k.storeKey), []byte(prefix))
+	defer iter.Close()
+
 	var grants []FeeAllowanceGrant
-	iter := sdk.KVStorePrefixIterator(store, prefixBytes)
+	for ; iter.Valid(); iter.Next() {
+		granter, err := sdk.AccAddressFromHex(string(iter.Key()[len(prefix):]))
+		if err != nil {
+			panic(err)
+		}
+		var grant feeAllowanceGrant
+		k.cdc.MustUnmarshalBinaryBare(iter.Value(), &grant)
+		if grant.Allowance == nil || isFeeAllowanceExpired(ctx, grant) {
+			continue
+		}
+		grants = append(grants, k.newFeeAllowanceGrant(ctx, grantee, granter, grant))
+	}
+	return grants
+}
+
+// GetFeeAllowancesByGranter returns the unexpired fee allowances granted by
+// granter
+func (k Keeper) GetFeeAllowancesByGranter(ctx sdk.Context, granter sdk.AccAddress) []FeeAllowanceGrant {
+	prefix := fmt.Sprintf("fg/%x/", granter)
+	iter := sdk.KVStorePrefixIterator(ctx.KVStore(k.storeKey), []byte(prefix))
 	defer iter.Close()
+
+	var grants []FeeAllowanceGrant
 	for ; iter.Valid(); iter.Next() {
-		granter, _ := sdk.AccAddressFromHex(string(iter.Key()[len(prefix):]))
-		bz := iter.Value()
-		var allowance FeeAllowance
-		k.cdc.MustUnmarshalBinaryBare(bz, &allowance)
-		grants = append(grants, FeeAllowanceGrant{
-			Allowance: allowance,
-			Grantee:   grantee,
-			Granter:   granter,
-		})
+		grantee, err := sdk.AccAddressFromHex(string(iter.Key()[len(prefix):]))
+		if err != nil {
+			panic(err)
+		}
+		grant, found := k.getFeeAllowanceGrant(ctx, grantee, granter)
+		if !found || isFeeAllowanceExpired(ctx, grant) {
+			continue
+		}
+		grants = append(grants, k.newFeeAllowanceGrant(ctx, grantee, granter, grant))
 	}
 	return grants
 }
 
-func (k Keeper) AllowDelegatedFees(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, fee sdk.Coins) bool {
-	store := ctx.KVStore(k.storeKey)
-	bz := store.Get(FeeAllowanceKey(grantee, granter))
-	if len(bz) == 0 {
-		return false
+func isFeeAllowanceExpired(ctx sdk.Context, grant feeAllowanceGrant) bool {
+	return !grant.Expiration.IsZero() && grant.Expiration.Before(ctx.BlockHeader().Time)
+}
+
+// AllowDelegatedFees checks whether grantee can pay fee for a transaction
+// with msgs from the account of granter, updating or removing the allowance
+// as it is spent. Expired allowances are removed. The tags of allowed fees
+// record the use of the allowance.
+func (k Keeper) AllowDelegatedFees(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, fee sdk.Coins, msgs []sdk.Msg) (bool, sdk.Tags) {
+	grant, found := k.getFeeAllowanceGrant(ctx, grantee, granter)
+	if !found {
+		return false, nil
 	}
-	var allowance FeeAllowance
-	k.cdc.MustUnmarshalBinaryBare(bz, &allowance)
-	if allowance == nil {
-		return false
+	if isFeeAllowanceExpired(ctx, grant) {
+		k.RevokeFeeAllowance(ctx, grantee, granter)
+		return false, nil
 	}
-	allow, updated, delete := allowance.Accept(fee, ctx.BlockHeader())
+	allow, updated, delete := grant.Allowance.Accept(fee, msgs, ctx.BlockHeader())
 	if allow == false {
-		return false
+		return false, nil
 	}
 	if delete {
 		k.RevokeFeeAllowance(ctx, grantee, granter)
 	} else if updated != nil {
-		k.DelegateFeeAllowance(ctx, grantee, granter, updated)
+		grant.Allowance = updated
+		k.setFeeAllowanceGrant(ctx, grantee, granter, grant)
+	}
+	return true, feeAllowanceTags(ActionFeeAllowanceUsed, grantee, granter)
+}
+
+// expiredGrantKeys returns the keys of the capabilities and fee allowances
+// that expired before the time of the block
+func (k Keeper) expiredGrantKeys(ctx sdk.Context) [][]byte {
+	store := ctx.KVStore(k.storeKey)
+	iter := store.Iterator(expirationQueuePrefix, expirationQueueTimeKey(ctx.BlockHeader().Time))
+	defer iter.Close()
+
+	var keys [][]byte
+	for ; iter.Valid(); iter.Next() {
+		keys = append(keys, iter.Key()[len(expirationQueueTimeKey(time.Time{})):])
+	}
+	return keys
+}
+
+// removeExpiredGrant revokes the capability or fee allowance stored under
+// grantKey
+func (k Keeper) removeExpiredGrant(ctx sdk.Context, grantKey []byte) sdk.Tags {
+	key := string(grantKey)
+	switch {
+	case strings.HasPrefix(key, "c/"):
+		parts := strings.SplitN(key[len("c/"):], "/", 4)
+		if len(parts) != 4 {
+			panic(fmt.Sprintf("invalid capability key %s", key))
+		}
+		grantee, granter := mustAccAddressesFromHex(parts[0], parts[1])
+		msg := msgType{route: parts[2], typ: parts[3]}
+		k.Revoke(ctx, grantee, granter, msg)
+		return capabilityTags(ActionCapabilityExpired, grantee, granter, msg)
+	case strings.HasPrefix(key, "f/"):
+		parts := strings.SplitN(key[len("f/"):], "/", 2)
+		if len(parts) != 2 {
+			panic(fmt.Sprintf("invalid fee allowance key %s", key))
+		}
+		grantee, granter := mustAccAddressesFromHex(parts[0], parts[1])
+		k.RevokeFeeAllowance(ctx, grantee, granter)
+		return feeAllowanceTags(ActionFeeAllowanceExpired, grantee, granter)
+	default:
+		panic(fmt.Sprintf("invalid grant key %s", key))
+	}
+}
+
+func mustAccAddressesFromHex(grantee string, granter string) (sdk.AccAddress, sdk.AccAddress) {
+	granteeAddr, err := sdk.AccAddressFromHex(grantee)
+	if err != nil {
+		panic(err)
+	}
+	granterAddr, err := sdk.AccAddressFromHex(granter)
+	if err != nil {
+		panic(err)
 	}
-	return true
+	return granteeAddr, granterAddr
 }
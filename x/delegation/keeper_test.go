@@ -16,6 +16,7 @@ import (
 	"github.com/cosmos/cosmos-sdk/store"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/x/auth"
+	authtypes "github.com/cosmos/cosmos-sdk/x/auth/types"
 	"github.com/cosmos/cosmos-sdk/x/bank"
 	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
 	"github.com/cosmos/cosmos-sdk/x/delegation"
@@ -26,6 +27,7 @@ type testInput struct {
 	cdc    *codec.Codec
 	ctx    sdk.Context
 	ak     auth.AccountKeeper
+	fck    auth.FeeCollectionKeeper
 	pk     params.Keeper
 	bk     bank.Keeper
 	dk     delegation.Keeper
@@ -70,9 +72,18 @@ func setupTestInput() testInput {
 
 	dk := delegation.NewKeeper(delCapKey, cdc, router)
 
+	fck := auth.NewFeeCollectionKeeper(cdc, fckCapKey)
+
 	ak.SetParams(ctx, auth.DefaultParams())
 
-	return testInput{cdc: cdc, ctx: ctx, ak: ak, pk: pk, bk: bk, dk: dk, router: router}
+	return testInput{cdc: cdc, ctx: ctx, ak: ak, fck: fck, pk: pk, bk: bk, dk: dk, router: router}
+}
+
+// allowFees returns whether the fee allowance of grantee from granter allows
+// fee for msgs
+func allowFees(input testInput, ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, fee sdk.Coins, msgs []sdk.Msg) bool {
+	allow, _ := input.dk.AllowDelegatedFees(ctx, grantee, granter, fee, msgs)
+	return allow
 }
 
 const (
@@ -135,6 +146,210 @@ func TestKeeperDelegation(t *testing.T) {
 	require.Nil(t, cap)
 }
 
+func TestKeeperSpendCapability(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+	handler := delegation.NewHandler(input.dk)
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	addr2, err := sdk.AccAddressFromBech32(recipient)
+	require.NoError(t, err)
+	input.bk.SetCoins(ctx, addr, sdk.NewCoins(sdk.NewInt64Coin("tree", 10000)))
+
+	expiration := ctx.BlockHeader().Time.Add(time.Hour)
+	input.dk.Delegate(ctx, addr2, addr, delegation.SendCapability{SpendLimit: sdk.NewCoins(sdk.NewInt64Coin("tree", 123))}, expiration)
+
+	exec := func(amount int64) sdk.Result {
+		send := bank.NewMsgSend(addr, addr2, sdk.NewCoins(sdk.NewInt64Coin("tree", amount)))
+		return handler(ctx, delegation.MsgExecDelegatedAction{Signer: addr2, Msgs: []sdk.Msg{send}})
+	}
+
+	// the spend limit is decremented with every send
+	require.True(t, exec(50).IsOK())
+	require.Equal(t, delegation.SendCapability{SpendLimit: sdk.NewCoins(sdk.NewInt64Coin("tree", 73))}, input.dk.GetCapability(ctx, addr2, addr, bank.MsgSend{}))
+	require.True(t, exec(50).IsOK())
+	require.Equal(t, delegation.SendCapability{SpendLimit: sdk.NewCoins(sdk.NewInt64Coin("tree", 23))}, input.dk.GetCapability(ctx, addr2, addr, bank.MsgSend{}))
+
+	// over the limit
+	require.False(t, exec(50).IsOK())
+	require.Equal(t, sdk.NewCoins(sdk.NewInt64Coin("tree", 100)), input.bk.GetCoins(ctx, addr2))
+
+	// the expiration is kept
+	grants := input.dk.GetCapabilityGrants(ctx, addr2)
+	require.Len(t, grants, 1)
+	require.Equal(t, expiration, grants[0].Expiration)
+
+	// spending the rest removes the capability
+	require.True(t, exec(23).IsOK())
+	require.Nil(t, input.dk.GetCapability(ctx, addr2, addr, bank.MsgSend{}))
+	require.Empty(t, input.dk.GetCapabilityGrants(ctx, addr2))
+	require.Empty(t, input.dk.GetCapabilityGrantsByGranter(ctx, addr))
+	require.False(t, exec(1).IsOK())
+	require.Equal(t, sdk.NewCoins(sdk.NewInt64Coin("tree", 123)), input.bk.GetCoins(ctx, addr2))
+}
+
+func TestKeeperGenericCapability(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+	handler := delegation.NewHandler(input.dk)
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	addr2, err := sdk.AccAddressFromBech32(recipient)
+	require.NoError(t, err)
+	input.bk.SetCoins(ctx, addr, sdk.NewCoins(sdk.NewInt64Coin("tree", 10000)))
+
+	capability := delegation.GenericCapability{Route: "bank", Type: "send"}
+	res := handler(ctx, delegation.NewMsgDelegate(addr, addr2, capability, time.Time{}))
+	require.True(t, res.IsOK())
+	require.Equal(t, capability, input.dk.GetCapability(ctx, addr2, addr, bank.MsgSend{}))
+
+	send := bank.NewMsgSend(addr, addr2, sdk.NewCoins(sdk.NewInt64Coin("tree", 5000)))
+	res = handler(ctx, delegation.MsgExecDelegatedAction{Signer: addr2, Msgs: []sdk.Msg{send, send}})
+	require.True(t, res.IsOK(), "%v", res)
+	require.Equal(t, sdk.NewCoins(sdk.NewInt64Coin("tree", 10000)), input.bk.GetCoins(ctx, addr2))
+
+	// the capability is stored, listed and revoked like any other
+	grants := input.dk.GetCapabilityGrantsByGranter(ctx, addr)
+	require.Len(t, grants, 1)
+	require.Equal(t, capability, grants[0].Capability)
+	bz, err := input.cdc.MarshalBinaryBare(grants[0].Capability)
+	require.NoError(t, err)
+	var decoded delegation.Capability
+	require.NoError(t, input.cdc.UnmarshalBinaryBare(bz, &decoded))
+	require.Equal(t, capability, decoded)
+
+	res = handler(ctx, delegation.MsgRevoke{Granter: addr, Grantee: addr2, MsgType: bank.MsgSend{}})
+	require.True(t, res.IsOK())
+	require.Nil(t, input.dk.GetCapability(ctx, addr2, addr, bank.MsgSend{}))
+}
+
+type mockAuthorizer struct {
+	account sdk.AccAddress
+	signer  sdk.AccAddress
+}
+
+func (a mockAuthorizer) Authorize(ctx sdk.Context, account sdk.AccAddress, signers []sdk.AccAddress) bool {
+	return account.Equals(a.account) && len(signers) == 1 && signers[0].Equals(a.signer)
+}
+
+func TestKeeperDispatchMultiSigner(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+	handler := delegation.NewHandler(input.dk)
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	addr2, err := sdk.AccAddressFromBech32(recipient)
+	require.NoError(t, err)
+	addr3 := sdk.AccAddress([]byte("third_address_______"))
+	group := sdk.AccAddress([]byte("group_address_______"))
+	for _, a := range []sdk.AccAddress{addr, addr2, group} {
+		input.bk.SetCoins(ctx, a, sdk.NewCoins(sdk.NewInt64Coin("tree", 100)))
+	}
+	trees := func(amount int64) sdk.Coins {
+		return sdk.NewCoins(sdk.NewInt64Coin("tree", amount))
+	}
+	multiSend := func(from ...sdk.AccAddress) sdk.Msg {
+		var inputs []bank.Input
+		for _, a := range from {
+			inputs = append(inputs, bank.NewInput(a, trees(10)))
+		}
+		return bank.NewMsgMultiSend(inputs, []bank.Output{bank.NewOutput(addr3, trees(int64(10*len(from))))})
+	}
+	exec := func(msgs ...sdk.Msg) sdk.Result {
+		return handler(ctx, delegation.MsgExecDelegatedAction{Signer: addr2, Msgs: msgs})
+	}
+
+	// addr2 can only sign for itself
+	require.True(t, exec(multiSend(addr2)).IsOK())
+	require.False(t, exec(multiSend(addr2, addr)).IsOK())
+
+	// a capability from addr covers its input
+	input.dk.Delegate(ctx, addr2, addr, delegation.GenericCapability{Route: "bank", Type: "multisend"}, time.Time{})
+	require.True(t, exec(multiSend(addr2, addr)).IsOK())
+	require.False(t, exec(multiSend(addr2, addr, group)).IsOK())
+
+	// an authorizer covers the input of the group
+	input.dk.AddAuthorizer(mockAuthorizer{account: group, signer: addr2})
+	res := exec(multiSend(addr2, addr, group), multiSend(addr, addr))
+	require.True(t, res.IsOK(), "%v", res)
+	require.Equal(t, trees(100-30), input.bk.GetCoins(ctx, addr2))
+	require.Equal(t, trees(100-40), input.bk.GetCoins(ctx, addr))
+	require.Equal(t, trees(100-10), input.bk.GetCoins(ctx, group))
+	require.Equal(t, trees(80), input.bk.GetCoins(ctx, addr3))
+
+	// there is a result per msg
+	results, err := delegation.DecodeMsgResults(res.Data)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	// the granters are tagged once
+	var granters []string
+	for _, tag := range res.Tags {
+		if string(tag.Key) == delegation.TagGranter {
+			granters = append(granters, string(tag.Value))
+		}
+	}
+	require.Equal(t, []string{addr.String(), group.String()}, granters)
+}
+
+func TestKeeperListCapabilities(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	addr2, err := sdk.AccAddressFromBech32(recipient)
+	require.NoError(t, err)
+	addr3 := sdk.AccAddress([]byte("third_address_______"))
+
+	now := ctx.BlockHeader().Time
+	send := delegation.SendCapability{SpendLimit: sdk.NewCoins(sdk.NewInt64Coin("tree", 123))}
+	input.dk.Delegate(ctx, addr2, addr, send, now.Add(time.Hour))
+	input.dk.Delegate(ctx, addr3, addr, send, time.Time{})
+	input.dk.Delegate(ctx, addr3, addr2, send, time.Time{})
+	// expired grants aren't listed
+	input.dk.Delegate(ctx, addr, addr2, send, now.Add(-time.Hour))
+
+	grantees := func(grants []delegation.CapabilityGrant) []sdk.AccAddress {
+		var addrs []sdk.AccAddress
+		for _, g := range grants {
+			require.Equal(t, send, g.Capability)
+			addrs = append(addrs, g.Grantee)
+		}
+		return addrs
+	}
+	granters := func(grants []delegation.CapabilityGrant) []sdk.AccAddress {
+		var addrs []sdk.AccAddress
+		for _, g := range grants {
+			addrs = append(addrs, g.Granter)
+		}
+		return addrs
+	}
+
+	require.ElementsMatch(t, []sdk.AccAddress{addr2, addr3}, grantees(input.dk.GetCapabilityGrantsByGranter(ctx, addr)))
+	require.ElementsMatch(t, []sdk.AccAddress{addr3}, grantees(input.dk.GetCapabilityGrantsByGranter(ctx, addr2)))
+	require.ElementsMatch(t, []sdk.AccAddress{addr, addr2}, granters(input.dk.GetCapabilityGrants(ctx, addr3)))
+	require.ElementsMatch(t, []sdk.AccAddress{addr}, granters(input.dk.GetCapabilityGrants(ctx, addr2)))
+	require.Empty(t, input.dk.GetCapabilityGrants(ctx, addr))
+
+	input.dk.Revoke(ctx, addr3, addr, bank.MsgSend{})
+	require.ElementsMatch(t, []sdk.AccAddress{addr2}, grantees(input.dk.GetCapabilityGrantsByGranter(ctx, addr)))
+	require.ElementsMatch(t, []sdk.AccAddress{addr2}, granters(input.dk.GetCapabilityGrants(ctx, addr3)))
+
+	querier := delegation.NewQuerier(input.dk)
+	bz, sdkErr := querier(ctx, []string{delegation.QueryGetCapsByGranter, addr2.String()}, abci.RequestQuery{})
+	require.Nil(t, sdkErr)
+	var grants []delegation.CapabilityGrant
+	require.NoError(t, input.cdc.UnmarshalJSON(bz, &grants))
+	require.Len(t, grants, 1)
+	require.Equal(t, addr3, grants[0].Grantee)
+	_, sdkErr = querier(ctx, []string{delegation.QueryGetCapsByGrantee, "invalid"}, abci.RequestQuery{})
+	require.NotNil(t, sdkErr)
+}
+
 func TestKeeperFees(t *testing.T) {
 	input := setupTestInput()
 	ctx := input.ctx
@@ -156,22 +371,282 @@ func TestKeeperFees(t *testing.T) {
 	someCoin := sdk.NewCoins(sdk.NewInt64Coin("tree", 123))
 	lotCoin := sdk.NewCoins(sdk.NewInt64Coin("tree", 4567))
 
+	msgs := []sdk.Msg{bank.NewMsgSend(addr2, addr, smallCoin)}
+
 	// not allows
-	ok := input.dk.AllowDelegatedFees(ctx, addr2, addr, smallCoin)
+	ok := allowFees(input, ctx, addr2, addr, smallCoin, msgs)
 	require.False(t, ok)
 
 	// allow it
-	input.dk.DelegateFeeAllowance(ctx, addr2, addr, delegation.BasicFeeAllowance{someCoin})
+	input.dk.DelegateFeeAllowance(ctx, addr2, addr, delegation.BasicFeeAllowance{someCoin}, time.Time{})
 
 	// okay under threshold
-	ok = input.dk.AllowDelegatedFees(ctx, addr2, addr, smallCoin)
+	ok = allowFees(input, ctx, addr2, addr, smallCoin, msgs)
 	require.True(t, ok)
 
 	// too high
-	ok = input.dk.AllowDelegatedFees(ctx, addr2, addr, lotCoin)
+	ok = allowFees(input, ctx, addr2, addr, lotCoin, msgs)
 	require.False(t, ok)
 
 	// wrong grantee
-	ok = input.dk.AllowDelegatedFees(ctx, addr2, addr2, smallCoin)
+	ok = allowFees(input, ctx, addr2, addr2, smallCoin, msgs)
 	require.False(t, ok)
 }
+
+func TestKeeperFeeAllowanceExpiration(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	addr2, err := sdk.AccAddressFromBech32(recipient)
+	require.NoError(t, err)
+
+	now := ctx.BlockHeader().Time
+	fee := sdk.NewCoins(sdk.NewInt64Coin("tree", 2))
+	msgs := []sdk.Msg{bank.NewMsgSend(addr2, addr, fee)}
+	allowance := delegation.BasicFeeAllowance{SpendLimit: sdk.NewCoins(sdk.NewInt64Coin("tree", 10))}
+
+	input.dk.DelegateFeeAllowance(ctx, addr2, addr, allowance, now.Add(time.Hour))
+	require.True(t, allowFees(input, ctx, addr2, addr, fee, msgs))
+
+	// the expiration is kept when the allowance is spent
+	later := ctx.WithBlockHeader(abci.Header{Time: now.Add(30 * time.Minute)})
+	require.True(t, allowFees(input, later, addr2, addr, fee, msgs))
+
+	expired := ctx.WithBlockHeader(abci.Header{Time: now.Add(2 * time.Hour)})
+	require.False(t, allowFees(input, expired, addr2, addr, fee, msgs))
+	// and it is removed once expired
+	require.False(t, allowFees(input, ctx, addr2, addr, fee, msgs))
+}
+
+func TestKeeperPeriodicFeeAllowance(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	addr2, err := sdk.AccAddressFromBech32(recipient)
+	require.NoError(t, err)
+
+	now := ctx.BlockHeader().Time
+	day := 24 * time.Hour
+	fee := sdk.NewCoins(sdk.NewInt64Coin("tree", 6))
+	send := []sdk.Msg{bank.NewMsgSend(addr2, addr, fee)}
+	delegate := []sdk.Msg{delegation.NewMsgDelegate(addr2, addr, delegation.SendCapability{}, time.Time{})}
+
+	input.dk.DelegateFeeAllowance(ctx, addr2, addr, delegation.MsgFeeAllowance{
+		Allowance: delegation.PeriodicFeeAllowance{
+			Period:           day,
+			PeriodSpendLimit: sdk.NewCoins(sdk.NewInt64Coin("tree", 10)),
+		},
+		MsgTypes: []string{"bank/send"},
+	}, time.Time{})
+
+	// only sends are paid for
+	require.False(t, allowFees(input, ctx, addr2, addr, fee, delegate))
+	require.False(t, allowFees(input, ctx, addr2, addr, fee, append(send, delegate...)))
+
+	// 10 per day
+	require.True(t, allowFees(input, ctx, addr2, addr, fee, send))
+	require.False(t, allowFees(input, ctx, addr2, addr, fee, send))
+	nextDay := ctx.WithBlockHeader(abci.Header{Time: now.Add(day)})
+	require.True(t, allowFees(input, nextDay, addr2, addr, fee, send))
+	require.False(t, allowFees(input, nextDay, addr2, addr, fee, send))
+}
+
+func TestAnteHandlerDelegatedFees(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+	anteHandler := auth.NewAnteHandler(input.ak, input.fck, input.dk, auth.DefaultSigVerificationGasConsumer)
+
+	granter, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	priv, _, grantee := authtypes.KeyTestPubAddr()
+	input.ak.SetAccount(ctx, input.ak.NewAccountWithAddress(ctx, grantee))
+	input.bk.SetCoins(ctx, granter, sdk.NewCoins(sdk.NewInt64Coin("tree", 1000)))
+
+	input.dk.DelegateFeeAllowance(ctx, grantee, granter, delegation.BasicFeeAllowance{
+		SpendLimit: sdk.NewCoins(sdk.NewInt64Coin("tree", 500)),
+	}, time.Time{})
+
+	fee := auth.NewStdFee(50000, sdk.NewCoins(sdk.NewInt64Coin("tree", 150)))
+	msgs := []sdk.Msg{bank.NewMsgSend(grantee, granter, sdk.NewCoins(sdk.NewInt64Coin("tree", 1)))}
+	accNum := input.ak.GetAccount(ctx, grantee).GetAccountNumber()
+	signBytes := auth.StdSignBytes(ctx.ChainID(), accNum, 0, fee, msgs, "", granter)
+	sig, err := priv.Sign(signBytes)
+	require.NoError(t, err)
+	tx := auth.NewStdTx(msgs, fee, []auth.StdSignature{{PubKey: priv.PubKey(), Signature: sig}}, "", granter)
+
+	_, res, abort := anteHandler(ctx, tx, false)
+	require.False(t, abort, "%v", res)
+	require.True(t, res.IsOK(), "%v", res)
+
+	// the granter pays the fee from its allowance
+	require.True(t, input.bk.GetCoins(ctx, granter).IsEqual(sdk.NewCoins(sdk.NewInt64Coin("tree", 850))))
+	require.True(t, input.fck.GetCollectedFees(ctx).IsEqual(sdk.NewCoins(sdk.NewInt64Coin("tree", 150))))
+	grants := input.dk.GetFeeAllowances(ctx, grantee)
+	require.Len(t, grants, 1)
+	require.True(t, grants[0].Remaining.IsEqual(sdk.NewCoins(sdk.NewInt64Coin("tree", 350))))
+}
+
+func TestKeeperListFeeAllowances(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	addr2, err := sdk.AccAddressFromBech32(recipient)
+	require.NoError(t, err)
+	addr3 := sdk.AccAddress([]byte("third_address_______"))
+
+	now := ctx.BlockHeader().Time
+	fee := sdk.NewCoins(sdk.NewInt64Coin("tree", 2))
+	msgs := []sdk.Msg{bank.NewMsgSend(addr2, addr, fee)}
+	basic := delegation.BasicFeeAllowance{SpendLimit: sdk.NewCoins(sdk.NewInt64Coin("tree", 10))}
+	periodic := delegation.PeriodicFeeAllowance{
+		SpendLimit:       sdk.NewCoins(sdk.NewInt64Coin("tree", 5)),
+		Period:           time.Hour,
+		PeriodSpendLimit: sdk.NewCoins(sdk.NewInt64Coin("tree", 8)),
+	}
+
+	input.dk.DelegateFeeAllowance(ctx, addr2, addr, basic, time.Time{})
+	input.dk.DelegateFeeAllowance(ctx, addr3, addr, periodic, now.Add(time.Hour))
+	input.dk.DelegateFeeAllowance(ctx, addr2, addr3, basic, now.Add(-time.Hour))
+	require.True(t, allowFees(input, ctx, addr2, addr, fee, msgs))
+
+	grants := input.dk.GetFeeAllowancesByGranter(ctx, addr)
+	require.Len(t, grants, 2)
+	remaining := make(map[string]sdk.Coins)
+	for _, g := range grants {
+		require.Equal(t, addr, g.Granter)
+		remaining[g.Grantee.String()] = g.Remaining
+	}
+	require.Equal(t, sdk.NewCoins(sdk.NewInt64Coin("tree", 8)), remaining[addr2.String()])
+	require.Equal(t, sdk.NewCoins(sdk.NewInt64Coin("tree", 5)), remaining[addr3.String()])
+
+	// the expired allowance of addr3 isn't listed
+	require.Empty(t, input.dk.GetFeeAllowancesByGranter(ctx, addr3))
+	grants = input.dk.GetFeeAllowances(ctx, addr2)
+	require.Len(t, grants, 1)
+	require.Equal(t, addr, grants[0].Granter)
+	require.Equal(t, sdk.NewCoins(sdk.NewInt64Coin("tree", 8)), grants[0].Remaining)
+
+	querier := delegation.NewQuerier(input.dk)
+	bz, sdkErr := querier(ctx, []string{delegation.QueryGetFeeAllowances, addr3.String()}, abci.RequestQuery{})
+	require.Nil(t, sdkErr)
+	require.NoError(t, input.cdc.UnmarshalJSON(bz, &grants))
+	require.Len(t, grants, 1)
+	require.Equal(t, periodic, grants[0].Allowance)
+	require.Equal(t, now.Add(time.Hour), grants[0].Expiration)
+
+	input.dk.RevokeFeeAllowance(ctx, addr3, addr)
+	bz, sdkErr = querier(ctx, []string{delegation.QueryGetFeeAllowancesByGranter, addr.String()}, abci.RequestQuery{})
+	require.Nil(t, sdkErr)
+	require.NoError(t, input.cdc.UnmarshalJSON(bz, &grants))
+	require.Len(t, grants, 1)
+	require.Equal(t, addr2, grants[0].Grantee)
+}
+
+type mockHooks struct {
+	calls *[]string
+}
+
+func (h mockHooks) AfterCapabilityGranted(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, capability delegation.Capability) {
+	*h.calls = append(*h.calls, "granted "+capability.MsgType().Type())
+}
+
+func (h mockHooks) AfterCapabilityRevoked(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, msgType sdk.Msg) {
+	*h.calls = append(*h.calls, "revoked "+msgType.Type())
+}
+
+func (h mockHooks) AfterFeeAllowanceGranted(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress, allowance delegation.FeeAllowance) {
+	*h.calls = append(*h.calls, "fees granted")
+}
+
+func (h mockHooks) AfterFeeAllowanceRevoked(ctx sdk.Context, grantee sdk.AccAddress, granter sdk.AccAddress) {
+	*h.calls = append(*h.calls, "fees revoked")
+}
+
+func TestEndBlockerExpiredGrants(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	addr2, err := sdk.AccAddressFromBech32(recipient)
+	require.NoError(t, err)
+	addr3 := sdk.AccAddress([]byte("third_address_______"))
+
+	var calls []string
+	input.dk.AddHooks(mockHooks{&calls})
+
+	now := ctx.BlockHeader().Time
+	trees := sdk.NewCoins(sdk.NewInt64Coin("tree", 10))
+	input.dk.Delegate(ctx, addr2, addr, delegation.SendCapability{SpendLimit: trees}, now.Add(time.Hour))
+	input.dk.Delegate(ctx, addr3, addr, delegation.GenericCapability{Route: "bank", Type: "multisend"}, now.Add(2*time.Hour))
+	input.dk.Delegate(ctx, addr3, addr2, delegation.SendCapability{SpendLimit: trees}, time.Time{})
+	input.dk.DelegateFeeAllowance(ctx, addr2, addr, delegation.BasicFeeAllowance{SpendLimit: trees}, now.Add(time.Hour))
+	// extending a grant moves it in the queue
+	input.dk.DelegateFeeAllowance(ctx, addr3, addr, delegation.BasicFeeAllowance{SpendLimit: trees}, now.Add(time.Hour))
+	input.dk.DelegateFeeAllowance(ctx, addr3, addr, delegation.BasicFeeAllowance{SpendLimit: trees}, now.Add(3*time.Hour))
+	require.Equal(t, []string{"granted send", "granted multisend", "granted send", "fees granted", "fees granted", "fees granted"}, calls)
+	calls = nil
+
+	// nothing expires before the expiration
+	require.Empty(t, delegation.EndBlocker(ctx.WithBlockHeader(abci.Header{Time: now.Add(time.Hour)}), input.dk))
+	require.Empty(t, calls)
+
+	later := ctx.WithBlockHeader(abci.Header{Time: now.Add(90 * time.Minute)})
+	tags := delegation.EndBlocker(later, input.dk)
+	require.Equal(t, []string{"revoked send", "fees revoked"}, calls)
+	var actions []string
+	for _, tag := range tags {
+		if string(tag.Key) == delegation.TagAction {
+			actions = append(actions, string(tag.Value))
+		}
+	}
+	require.Equal(t, []string{delegation.ActionCapabilityExpired, delegation.ActionFeeAllowanceExpired}, actions)
+
+	// the expired grants are gone from the store, not only hidden
+	input.dk.Revoke(later, addr2, addr, bank.MsgSend{})
+	input.dk.RevokeFeeAllowance(later, addr2, addr)
+	require.Equal(t, []string{"revoked send", "fees revoked"}, calls)
+	require.Len(t, input.dk.GetCapabilityGrantsByGranter(ctx, addr), 1)
+	require.Len(t, input.dk.GetFeeAllowancesByGranter(ctx, addr), 1)
+
+	// revoking removes a grant from the queue
+	input.dk.Revoke(later, addr3, addr, delegation.GenericCapability{Route: "bank", Type: "multisend"}.MsgType())
+	calls = nil
+	muchLater := ctx.WithBlockHeader(abci.Header{Time: now.Add(24 * time.Hour)})
+	tags = delegation.EndBlocker(muchLater, input.dk)
+	require.Equal(t, []string{"fees revoked"}, calls)
+	require.Empty(t, input.dk.GetFeeAllowancesByGranter(muchLater, addr))
+	require.Len(t, input.dk.GetCapabilityGrants(muchLater, addr3), 1)
+	require.Empty(t, delegation.EndBlocker(muchLater, input.dk))
+}
+
+func TestKeeperFeeAllowanceTags(t *testing.T) {
+	input := setupTestInput()
+	ctx := input.ctx
+
+	addr, err := sdk.AccAddressFromBech32(sender)
+	require.NoError(t, err)
+	addr2, err := sdk.AccAddressFromBech32(recipient)
+	require.NoError(t, err)
+
+	fee := sdk.NewCoins(sdk.NewInt64Coin("tree", 2))
+	msgs := []sdk.Msg{bank.NewMsgSend(addr2, addr, fee)}
+	_, tags := input.dk.AllowDelegatedFees(ctx, addr2, addr, fee, msgs)
+	require.Empty(t, tags)
+
+	input.dk.DelegateFeeAllowance(ctx, addr2, addr, delegation.BasicFeeAllowance{SpendLimit: fee}, time.Time{})
+	allow, tags := input.dk.AllowDelegatedFees(ctx, addr2, addr, fee, msgs)
+	require.True(t, allow)
+	require.Equal(t, sdk.NewTags(
+		delegation.TagCategory, delegation.TxCategory,
+		delegation.TagAction, delegation.ActionFeeAllowanceUsed,
+		delegation.TagGrantee, addr2.String(),
+		delegation.TagGranter, addr.String(),
+	), tags)
+}
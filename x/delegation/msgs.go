@@ -102,13 +102,14 @@ func (msg MsgRevoke) GetSigners() []sdk.AccAddress {
 }
 
 type MsgDelegateFeeAllowance struct {
-	Granter   sdk.AccAddress `json:"granter"`
-	Grantee   sdk.AccAddress `json:"grantee"`
-	Allowance FeeAllowance   `json:"allowance"`
+	Granter    sdk.AccAddress `json:"granter"`
+	Grantee    sdk.AccAddress `json:"grantee"`
+	Allowance  FeeAllowance   `json:"allowance"`
+	Expiration time.Time      `json:"expiration"`
 }
 
-func NewMsgDelegateFeeAllowance(granter sdk.AccAddress, grantee sdk.AccAddress, allowance FeeAllowance) MsgDelegateFeeAllowance {
-	return MsgDelegateFeeAllowance{Granter: granter, Grantee: grantee, Allowance: allowance}
+func NewMsgDelegateFeeAllowance(granter sdk.AccAddress, grantee sdk.AccAddress, allowance FeeAllowance, expiration time.Time) MsgDelegateFeeAllowance {
+	return MsgDelegateFeeAllowance{Granter: granter, Grantee: grantee, Allowance: allowance, Expiration: expiration}
 }
 
 func (msg MsgDelegateFeeAllowance) Route() string {
@@ -8,6 +8,13 @@ import (
 const (
 	QueryGetCaps          = "cap"
 	QueryGetFeeAllowances = "fees"
+	// QueryGetFeeAllowancesByGranter lists the fee allowances granted by an
+	// address
+	QueryGetFeeAllowancesByGranter = "fees-by-granter"
+	// QueryGetCapsByGrantee lists the capabilities held by an address
+	QueryGetCapsByGrantee = "caps-by-grantee"
+	// QueryGetCapsByGranter lists the capabilities granted by an address
+	QueryGetCapsByGranter = "caps-by-granter"
 )
 
 // NewQuerier creates a new querier
@@ -17,7 +24,13 @@ func NewQuerier(keeper Keeper) sdk.Querier {
 		case QueryGetCaps:
 			return queryGetCaps(ctx, req.Data, keeper)
 		case QueryGetFeeAllowances:
-			return queryGetFeeAllowances(ctx, path[1:], keeper)
+			return queryGetFeeAllowances(ctx, path[1:], keeper.GetFeeAllowances, keeper)
+		case QueryGetFeeAllowancesByGranter:
+			return queryGetFeeAllowances(ctx, path[1:], keeper.GetFeeAllowancesByGranter, keeper)
+		case QueryGetCapsByGrantee:
+			return queryGetCapabilityGrants(ctx, path[1:], keeper.GetCapabilityGrants, keeper)
+		case QueryGetCapsByGranter:
+			return queryGetCapabilityGrants(ctx, path[1:], keeper.GetCapabilityGrantsByGranter, keeper)
 		default:
 			return nil, sdk.ErrUnknownRequest("Unknown package delegation query endpoint")
 		}
@@ -41,14 +54,16 @@ func queryGetCaps(ctx sdk.Context, data []byte, keeper Keeper) ([]byte, sdk.Erro
 	return bz, nil
 }
 
-func queryGetFeeAllowances(ctx sdk.Context, args []string, keeper Keeper) ([]byte, sdk.Error) {
-	grantee := args[0]
-	granteeAddr, err := sdk.AccAddressFromBech32(grantee)
+func queryGetFeeAllowances(ctx sdk.Context, args []string, list func(sdk.Context, sdk.AccAddress) []FeeAllowanceGrant, keeper Keeper) ([]byte, sdk.Error) {
+	if len(args) != 1 {
+		return nil, sdk.ErrUnknownRequest("missing address")
+	}
+	addr, err := sdk.AccAddressFromBech32(args[0])
 	if err != nil {
 		return nil, sdk.ErrInternal(sdk.AppendMsgToErr("invalid address", err.Error()))
 	}
 
-	fees := keeper.GetFeeAllowances(ctx, granteeAddr)
+	fees := list(ctx, addr)
 	if fees == nil {
 		fees = []FeeAllowanceGrant{}
 	}
@@ -58,3 +73,23 @@ func queryGetFeeAllowances(ctx sdk.Context, args []string, keeper Keeper) ([]byt
 	}
 	return bz, nil
 }
+
+func queryGetCapabilityGrants(ctx sdk.Context, args []string, list func(sdk.Context, sdk.AccAddress) []CapabilityGrant, keeper Keeper) ([]byte, sdk.Error) {
+	if len(args) != 1 {
+		return nil, sdk.ErrUnknownRequest("missing address")
+	}
+	addr, err := sdk.AccAddressFromBech32(args[0])
+	if err != nil {
+		return nil, sdk.ErrInternal(sdk.AppendMsgToErr("invalid address", err.Error()))
+	}
+
+	grants := list(ctx, addr)
+	if grants == nil {
+		grants = []CapabilityGrant{}
+	}
+	bz, jErr := keeper.cdc.MarshalJSON(grants)
+	if jErr != nil {
+		return nil, sdk.ErrInternal(sdk.AppendMsgToErr("could not marshal result to JSON", jErr.Error()))
+	}
+	return bz, nil
+}
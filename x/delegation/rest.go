@@ -21,9 +21,21 @@ func registerQueryRoutes(cliCtx context.CLIContext, r *mux.Router) {
 		"/delegation/capabilities/{granteeAddr}/{granterAddr}/{route}/{type}",
 		getCapabilitiesHandlerFn(cliCtx),
 	).Methods("GET")
+	r.HandleFunc(
+		"/delegation/capabilities/{granteeAddr}",
+		getCapabilityGrantsHandlerFn(cliCtx, QueryGetCapsByGrantee, "granteeAddr"),
+	).Methods("GET")
+	r.HandleFunc(
+		"/delegation/granted/{granterAddr}",
+		getCapabilityGrantsHandlerFn(cliCtx, QueryGetCapsByGranter, "granterAddr"),
+	).Methods("GET")
 	r.HandleFunc(
 		"/delegation/allowfees/{granteeAddr}",
-		getAllowFeesHandlerFn(cliCtx),
+		getAllowFeesHandlerFn(cliCtx, QueryGetFeeAllowances, "granteeAddr"),
+	).Methods("GET")
+	r.HandleFunc(
+		"/delegation/allowfees/granted/{granterAddr}",
+		getAllowFeesHandlerFn(cliCtx, QueryGetFeeAllowancesByGranter, "granterAddr"),
 	).Methods("GET")
 }
 
@@ -41,7 +53,7 @@ func getCapabilitiesHandlerFn(cliCtx context.CLIContext) http.HandlerFunc {
 		granter := vars["granterAddr"]
 		rt := vars["route"]
 		typ := vars["type"]
-		route := fmt.Sprintf("custom/group/%s", QueryGetCaps)
+		route := fmt.Sprintf("custom/delegation/%s", QueryGetCaps)
 
 		granteeAddr, err := sdk.AccAddressFromBech32(grantee)
 		if err != nil {
@@ -72,11 +84,12 @@ func getCapabilitiesHandlerFn(cliCtx context.CLIContext) http.HandlerFunc {
 	}
 }
 
-func getAllowFeesHandlerFn(cliCtx context.CLIContext) http.HandlerFunc {
+// getAllowFeesHandlerFn lists the fee allowances of the address in the given
+// route variable
+func getAllowFeesHandlerFn(cliCtx context.CLIContext, query string, addrVar string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		vars := mux.Vars(r)
-		grantee := vars["granteeAddr"]
-		route := fmt.Sprintf("custom/group/%s/%s", QueryGetFeeAllowances, grantee)
+		addr := mux.Vars(r)[addrVar]
+		route := fmt.Sprintf("custom/delegation/%s/%s", query, addr)
 
 		res, err := cliCtx.QueryWithData(route, []byte{})
 		if err != nil {
@@ -87,3 +100,20 @@ func getAllowFeesHandlerFn(cliCtx context.CLIContext) http.HandlerFunc {
 		rest.PostProcessResponse(w, cliCtx, res)
 	}
 }
+
+// getCapabilityGrantsHandlerFn lists the capabilities of the address in the
+// given route variable
+func getCapabilityGrantsHandlerFn(cliCtx context.CLIContext, query string, addrVar string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		addr := mux.Vars(r)[addrVar]
+		route := fmt.Sprintf("custom/delegation/%s/%s", query, addr)
+
+		res, err := cliCtx.QueryWithData(route, nil)
+		if err != nil {
+			rest.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		rest.PostProcessResponse(w, cliCtx, res)
+	}
+}
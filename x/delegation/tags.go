@@ -0,0 +1,50 @@
+package delegation
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// Delegation tags
+const (
+	ActionCapabilityGranted   = "capability-granted"
+	ActionCapabilityRevoked   = "capability-revoked"
+	ActionCapabilityExpired   = "capability-expired"
+	ActionDelegatedExec       = "delegated-exec"
+	ActionFeeAllowanceGranted = "fee-allowance-granted"
+	ActionFeeAllowanceRevoked = "fee-allowance-revoked"
+	ActionFeeAllowanceExpired = "fee-allowance-expired"
+	ActionFeeAllowanceUsed    = "fee-allowance-used"
+	TxCategory                = ModuleName
+
+	TagGrantee = "grantee"
+	TagGranter = "granter"
+	TagMsgType = "msg-type"
+)
+
+// SDK tag aliases
+var (
+	TagAction   = sdk.TagAction
+	TagCategory = sdk.TagCategory
+)
+
+// capabilityTags tags action on the capability of grantee from granter for
+// msgs of msgType
+func capabilityTags(action string, grantee sdk.AccAddress, granter sdk.AccAddress, msgType sdk.Msg) sdk.Tags {
+	return sdk.NewTags(
+		TagCategory, TxCategory,
+		TagAction, action,
+		TagGrantee, grantee.String(),
+		TagGranter, granter.String(),
+		TagMsgType, msgType.Route()+"/"+msgType.Type(),
+	)
+}
+
+// feeAllowanceTags tags action on the fee allowance of grantee from granter
+func feeAllowanceTags(action string, grantee sdk.AccAddress, granter sdk.AccAddress) sdk.Tags {
+	return sdk.NewTags(
+		TagCategory, TxCategory,
+		TagAction, action,
+		TagGrantee, grantee.String(),
+		TagGranter, granter.String(),
+	)
+}
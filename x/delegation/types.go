@@ -22,7 +22,50 @@ type Capability interface {
 // FeeAllowance defines a permission for one account to use another account's balance
 // to pay fees
 type FeeAllowance interface {
-	// Accept checks whether this allowance allows the provided fees to be spent,
-	// and optionally updates the allowance or deletes it entirely
-	Accept(fee sdk.Coins, block abci.Header) (allow bool, updated FeeAllowance, delete bool)
+	// Accept checks whether this allowance allows the provided fees to be spent
+	// on a transaction with msgs, and optionally updates the allowance or
+	// deletes it entirely
+	Accept(fee sdk.Coins, msgs []sdk.Msg, block abci.Header) (allow bool, updated FeeAllowance, delete bool)
+	// Remaining returns the fees that can still be spent at the time of block
+	Remaining(block abci.Header) sdk.Coins
 }
+
+// Authorizer decides whether signers may act for an account, for example
+// because they are members of a group owning the account
+type Authorizer interface {
+	Authorize(ctx sdk.Context, account sdk.AccAddress, signers []sdk.AccAddress) bool
+}
+
+// MsgResult is the outcome of a msg routed by DispatchActions
+type MsgResult struct {
+	Data []byte `json:"data"`
+}
+
+// DecodeMsgResults decodes the data of a DispatchActions result
+func DecodeMsgResults(data []byte) ([]MsgResult, error) {
+	var results []MsgResult
+	err := moduleCodec.UnmarshalJSON(data, &results)
+	return results, err
+}
+
+// msgType stands in for msgs of a route and type that aren't known to this
+// module, so capabilities for them can be stored and revoked. It is never
+// dispatched.
+type msgType struct {
+	route string
+	typ   string
+}
+
+var _ sdk.Msg = msgType{}
+
+func (msg msgType) Route() string { return msg.route }
+
+func (msg msgType) Type() string { return msg.typ }
+
+func (msg msgType) ValidateBasic() sdk.Error {
+	return sdk.ErrUnknownRequest("msg type can't be dispatched")
+}
+
+func (msg msgType) GetSignBytes() []byte { return nil }
+
+func (msg msgType) GetSigners() []sdk.AccAddress { return nil }
@@ -1,17 +1,37 @@
 package group
 
-import "github.com/cosmos/cosmos-sdk/codec"
+import (
+	"github.com/cosmos/cosmos-sdk/codec"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
 
+// moduleCodec encodes the genesis of an AppModuleBasic created without the
+// app codec. It only knows the msgs of this module, genesis with proposals
+// of other modules needs the app codec, see NewAppModuleBasic.
 var moduleCodec = codec.New()
 
+func init() {
+	sdk.RegisterCodec(moduleCodec)
+	codec.RegisterCrypto(moduleCodec)
+	RegisterCodec(moduleCodec)
+}
+
 // RegisterCodec ...
 func RegisterCodec(cdc *codec.Codec) {
 	cdc.RegisterConcrete(MsgCreateGroup{}, "group/MsgCreateGroup", nil)
 	cdc.RegisterConcrete(Group{}, "group/Group", nil)
-	cdc.RegisterConcrete(GroupAccount{}, "group/GroupAccount", nil)
+	cdc.RegisterConcrete(&GroupAccount{}, "group/GroupAccount", nil)
 	cdc.RegisterConcrete(MsgCreateProposal{}, "group/MsgCreateProposal", nil)
 	cdc.RegisterConcrete(MsgVote{}, "group/MsgVote", nil)
 	cdc.RegisterConcrete(MsgTryExecuteProposal{}, "group/MsgTryExecuteProposal", nil)
 	cdc.RegisterConcrete(MsgWithdrawProposal{}, "group/MsgWithdrawProposal", nil)
+	cdc.RegisterConcrete(MsgUpdateGroup{}, "group/MsgUpdateGroup", nil)
+	cdc.RegisterConcrete(MsgUpdateGroupMembers{}, "group/MsgUpdateGroupMembers", nil)
+	cdc.RegisterConcrete(MsgUpdateGroupThreshold{}, "group/MsgUpdateGroupThreshold", nil)
+	cdc.RegisterConcrete(MsgUpdateGroupMetadata{}, "group/MsgUpdateGroupMetadata", nil)
 	cdc.RegisterConcrete(Proposal{}, "group/Proposal", nil)
+	cdc.RegisterInterface((*DecisionPolicy)(nil), nil)
+	cdc.RegisterConcrete(ThresholdDecisionPolicy{}, "group/ThresholdDecisionPolicy", nil)
+	cdc.RegisterConcrete(PercentageDecisionPolicy{}, "group/PercentageDecisionPolicy", nil)
+	cdc.RegisterConcrete(QuorumDecisionPolicy{}, "group/QuorumDecisionPolicy", nil)
 }
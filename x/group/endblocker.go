@@ -0,0 +1,14 @@
+package group
+
+import (
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// EndBlocker closes the proposals whose voting period ended
+func EndBlocker(ctx sdk.Context, keeper Keeper) sdk.Tags {
+	resTags := sdk.NewTags()
+	for _, id := range keeper.endedProposals(ctx) {
+		resTags = resTags.AppendTags(keeper.closeProposal(ctx, id))
+	}
+	return resTags
+}
@@ -1,35 +1,210 @@
 package group
 
-import sdk "github.com/cosmos/cosmos-sdk/types"
+import (
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/auth"
+)
 
 // GenesisState defines genesis data for the module
 type GenesisState struct {
-	// Delegations []Delegation `json:"delegations"`
+	Params    Params     `json:"params"`
+	Groups    []Group    `json:"groups"`
+	Proposals []Proposal `json:"proposals"`
+	Votes     []Vote     `json:"votes"`
+	Sequences Sequences  `json:"sequences"`
+}
+
+// Sequences are the next group and proposal ids to be handed out
+type Sequences struct {
+	NextGroupID    uint64 `json:"next_group_id"`
+	NextProposalID uint64 `json:"next_proposal_id"`
 }
 
 // NewGenesisState creates a new genesis state.
-func NewGenesisState() GenesisState {
+func NewGenesisState(params Params) GenesisState {
 	return GenesisState{
-		// Delegations: nil,
+		Params:    params,
+		Groups:    nil,
+		Proposals: nil,
+		Votes:     nil,
 	}
 }
 
 // DefaultGenesisState returns a default genesis state
-func DefaultGenesisState() GenesisState { return NewGenesisState() }
+func DefaultGenesisState() GenesisState { return NewGenesisState(DefaultParams()) }
 
 // InitGenesis initializes story state from genesis file
 func InitGenesis(ctx sdk.Context, keeper Keeper, data GenesisState) {
+	keeper.SetParams(ctx, data.Params)
+	for _, group := range data.Groups {
+		keeper.importGroup(ctx, group)
+	}
+	for _, proposal := range data.Proposals {
+		keeper.importProposal(ctx, proposal)
+	}
+	for _, vote := range data.Votes {
+		keeper.setVote(ctx, vote.ProposalID, vote.Voter, vote.Option)
+	}
+	keeper.setSequence(ctx, keyNewGroupID, data.Sequences.NextGroupID)
+	keeper.setSequence(ctx, keyNewProposalID, data.Sequences.NextProposalID)
 }
 
 // ExportGenesis exports the genesis state
 func ExportGenesis(ctx sdk.Context, keeper Keeper) GenesisState {
+	var proposals []Proposal
+	keeper.IterateProposals(ctx, func(proposal Proposal) bool {
+		proposals = append(proposals, proposal)
+		return false
+	})
+	var votes []Vote
+	keeper.iterateVotes(ctx, keyVotePrefix, func(vote Vote) bool {
+		votes = append(votes, vote)
+		return false
+	})
 	return GenesisState{
-		//		Delegations: keeper.Delegations(ctx),
+		Params:    keeper.GetParams(ctx),
+		Groups:    keeper.GetGroups(ctx),
+		Proposals: proposals,
+		Votes:     votes,
+		Sequences: Sequences{
+			NextGroupID:    keeper.peekSequence(ctx, keyNewGroupID),
+			NextProposalID: keeper.peekSequence(ctx, keyNewProposalID),
+		},
+	}
+}
+
+// importGroup stores a group with its member index and creates its account,
+// unless the account was imported already
+func (keeper Keeper) importGroup(ctx sdk.Context, group Group) {
+	keeper.setGroupInfo(ctx, group.ID, group)
+	keeper.setMemberIndex(ctx, group)
+	if keeper.accountKeeper.GetAccount(ctx, group.ID) == nil {
+		keeper.accountKeeper.SetAccount(ctx, &GroupAccount{
+			BaseAccount: &auth.BaseAccount{
+				Address: group.ID,
+			},
+		})
+	}
+}
+
+// importProposal stores a proposal and queues it if voting on it didn't end
+func (keeper Keeper) importProposal(ctx sdk.Context, proposal Proposal) {
+	keeper.storeProposal(ctx, proposal.ID, &proposal)
+	if !proposal.Status.IsClosed() {
+		ctx.KVStore(keeper.storeKey).Set(KeyVotingEndQueue(proposal.VotingEndTime, proposal.ID), []byte{})
 	}
 }
 
 // ValidateGenesis validates the genesis state data
 func ValidateGenesis(data GenesisState) error {
+	if data.Params.MaxNestingDepth == 0 {
+		return fmt.Errorf("group parameter MaxNestingDepth must be positive")
+	}
 
+	groups := make(map[string]Group)
+	for _, group := range data.Groups {
+		seq, err := groupIDToUint64(group.ID)
+		if err != nil {
+			return err
+		}
+		if seq >= data.Sequences.NextGroupID {
+			return fmt.Errorf("group %s isn't below the next group id %d", group.ID, data.Sequences.NextGroupID)
+		}
+		if _, ok := groups[string(group.ID)]; ok {
+			return fmt.Errorf("duplicate group %s", group.ID)
+		}
+		if err := group.ValidateBasic(); err != nil {
+			return fmt.Errorf("group %s: %s", group.ID, err.Error())
+		}
+		groups[string(group.ID)] = group
+	}
+	if err := validateNesting(groups, data.Params.MaxNestingDepth); err != nil {
+		return err
+	}
+
+	proposals := make(map[ProposalID]bool)
+	for _, proposal := range data.Proposals {
+		if uint64(proposal.ID) >= data.Sequences.NextProposalID {
+			return fmt.Errorf("proposal %d isn't below the next proposal id %d", proposal.ID, data.Sequences.NextProposalID)
+		}
+		if proposals[proposal.ID] {
+			return fmt.Errorf("duplicate proposal %d", proposal.ID)
+		}
+		proposals[proposal.ID] = true
+		group, ok := groups[string(proposal.Group)]
+		if !ok {
+			return fmt.Errorf("group %s of proposal %d doesn't exist", proposal.Group, proposal.ID)
+		}
+		if proposal.Status == StatusNil || proposal.Status.String() == "" {
+			return fmt.Errorf("invalid status %d of proposal %d", proposal.Status, proposal.ID)
+		}
+		if proposal.GroupVersion > group.Version || (!proposal.Status.IsClosed() && proposal.GroupVersion != group.Version) {
+			return fmt.Errorf("proposal %d of group version %d doesn't match version %d", proposal.ID, proposal.GroupVersion, group.Version)
+		}
+	}
+
+	votes := make(map[string]bool)
+	for _, vote := range data.Votes {
+		if !proposals[vote.ProposalID] {
+			return fmt.Errorf("proposal %d of vote by %s doesn't exist", vote.ProposalID, vote.Voter)
+		}
+		if vote.Voter.Empty() {
+			return fmt.Errorf("vote on proposal %d has no voter", vote.ProposalID)
+		}
+		if vote.Option.String() == "" {
+			return fmt.Errorf("invalid option %d of vote by %s", vote.Option, vote.Voter)
+		}
+		key := string(KeyVote(vote.ProposalID, vote.Voter))
+		if votes[key] {
+			return fmt.Errorf("duplicate vote by %s on proposal %d", vote.Voter, vote.ProposalID)
+		}
+		votes[key] = true
+	}
+	return nil
+}
+
+// validateNesting returns an error when groups contain themselves or are
+// nested deeper than maxDepth
+func validateNesting(groups map[string]Group, maxDepth uint64) error {
+	heights := make(map[string]uint64)
+	visiting := make(map[string]bool)
+	var height func(info Group) (uint64, error)
+	height = func(info Group) (uint64, error) {
+		if h, ok := heights[string(info.ID)]; ok {
+			return h, nil
+		}
+		if visiting[string(info.ID)] {
+			return 0, fmt.Errorf("group %s is a member of itself", info.ID)
+		}
+		visiting[string(info.ID)] = true
+		var max uint64
+		for _, mem := range info.Members {
+			nested, ok := groups[string(mem.Address)]
+			if !ok {
+				continue
+			}
+			h, err := height(nested)
+			if err != nil {
+				return 0, err
+			}
+			if h > max {
+				max = h
+			}
+		}
+		heights[string(info.ID)] = max + 1
+		return max + 1, nil
+	}
+
+	for _, group := range groups {
+		h, err := height(group)
+		if err != nil {
+			return err
+		}
+		if h > maxDepth {
+			return fmt.Errorf("group %s is nested %d levels deep, the maximum is %d", group.ID, h, maxDepth)
+		}
+	}
 	return nil
 }
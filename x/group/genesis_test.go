@@ -0,0 +1,158 @@
+package group
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/bank"
+)
+
+func TestGenesisExportImport(t *testing.T) {
+	setupTestInput()
+	now := ctx.BlockHeader().Time
+	group := createTestGroup(t, ThresholdDecisionPolicy{Threshold: sdk.NewInt(3)}, VotingPolicy{VotingPeriod: time.Hour})
+	outer, err := keeper.CreateGroup(ctx, Group{
+		Members:        []Member{{Address: group, Weight: sdk.NewInt(1)}},
+		DecisionPolicy: ThresholdDecisionPolicy{Threshold: sdk.NewInt(1)},
+		VotingPolicy:   DefaultVotingPolicy,
+	})
+	require.Nil(t, err)
+	send := bank.NewMsgSend(group, carol, trees(30))
+
+	executed, _ := keeper.Propose(ctx, carol, group, []sdk.Msg{send})
+	require.True(t, keeper.TryExecute(ctx, executed).IsOK())
+	invalidated, _ := keeper.Propose(ctx, alice, group, []sdk.Msg{send})
+	_, err = keeper.UpdateGroupMemo(ctx, group, "trees")
+	require.Nil(t, err)
+	open, _ := keeper.Propose(ctx, alice, group, []sdk.Msg{send})
+	require.True(t, keeper.Vote(ctx, open, bob, OptionNo).IsOK())
+
+	exported := ExportGenesis(ctx, keeper)
+	require.Nil(t, ValidateGenesis(exported))
+	require.Len(t, exported.Groups, 2)
+	require.Len(t, exported.Proposals, 3)
+	require.Equal(t, []Vote{
+		{ProposalID: executed, Voter: carol, Option: OptionYes},
+		{ProposalID: invalidated, Voter: alice, Option: OptionYes},
+		{ProposalID: open, Voter: alice, Option: OptionYes},
+		{ProposalID: open, Voter: bob, Option: OptionNo},
+	}, exported.Votes)
+	require.Equal(t, Sequences{NextGroupID: 2, NextProposalID: 3}, exported.Sequences)
+
+	bz, jsonErr := cdc.MarshalJSON(exported)
+	require.Nil(t, jsonErr)
+	var imported GenesisState
+	require.Nil(t, cdc.UnmarshalJSON(bz, &imported))
+
+	setupTestInput()
+	InitGenesis(ctx, keeper, imported)
+	require.Equal(t, exported, ExportGenesis(ctx, keeper))
+
+	require.NotNil(t, keeper.accountKeeper.GetAccount(ctx, group))
+	require.Len(t, keeper.GetGroupsByMemberAddress(ctx, group), 1)
+	require.True(t, keeper.Authorize(ctx, outer, []sdk.AccAddress{carol}))
+	id, err := keeper.CreateGroup(ctx, Group{
+		Members:        []Member{{Address: alice, Weight: sdk.NewInt(1)}},
+		DecisionPolicy: ThresholdDecisionPolicy{Threshold: sdk.NewInt(1)},
+		VotingPolicy:   DefaultVotingPolicy,
+	})
+	require.Nil(t, err)
+	require.Equal(t, addrFromUint64(2), id)
+	proposalID, _ := keeper.Propose(ctx, alice, group, []sdk.Msg{send})
+	require.Equal(t, ProposalID(3), proposalID)
+
+	// the open proposal is queued again
+	require.Equal(t, []ProposalID{open}, keeper.endedProposals(atTime(now.Add(time.Hour))))
+}
+
+func TestAppModuleGenesis(t *testing.T) {
+	setupTestInput()
+	group := createTestGroup(t, ThresholdDecisionPolicy{Threshold: sdk.NewInt(3)}, DefaultVotingPolicy)
+	keeper.Propose(ctx, alice, group, []sdk.Msg{bank.NewMsgSend(group, carol, trees(30))})
+
+	// the proposal holds a bank msg, which only the app codec knows
+	am := NewAppModule(keeper)
+	exported := am.ExportGenesis(ctx)
+	require.NoError(t, am.ValidateGenesis(exported))
+	require.Error(t, AppModuleBasic{}.ValidateGenesis(exported))
+	require.NoError(t, AppModuleBasic{}.ValidateGenesis(AppModuleBasic{}.DefaultGenesis()))
+
+	setupTestInput()
+	am = NewAppModule(keeper)
+	am.InitGenesis(ctx, exported)
+	require.Equal(t, exported, am.ExportGenesis(ctx))
+}
+
+func TestValidateGenesis(t *testing.T) {
+	threshold := ThresholdDecisionPolicy{Threshold: sdk.NewInt(1)}
+	newGroup := func(seq uint64, version uint64, members ...sdk.AccAddress) Group {
+		info := Group{ID: addrFromUint64(seq), DecisionPolicy: threshold, VotingPolicy: DefaultVotingPolicy, Version: version}
+		for _, mem := range members {
+			info.Members = append(info.Members, Member{Address: mem, Weight: sdk.NewInt(1)})
+		}
+		return info
+	}
+	validGroup := newGroup(0, 1, alice)
+	validProposal := Proposal{ID: 0, Group: validGroup.ID, Proposer: alice, Tally: NewTally(), GroupVersion: 1, Status: StatusOpen}
+	validVote := Vote{ProposalID: 0, Voter: alice, Option: OptionYes}
+
+	genesis := func(groups []Group, proposals []Proposal, votes []Vote) GenesisState {
+		return GenesisState{
+			Params:    DefaultParams(),
+			Groups:    groups,
+			Proposals: proposals,
+			Votes:     votes,
+			Sequences: Sequences{NextGroupID: 3, NextProposalID: 1},
+		}
+	}
+	oldVersion := validProposal
+	oldVersion.GroupVersion = 0
+	invalidated := oldVersion
+	invalidated.Status = StatusInvalidated
+	unknownGroup := validProposal
+	unknownGroup.Group = addrFromUint64(2)
+	noStatus := validProposal
+	noStatus.Status = StatusNil
+
+	cases := map[string]struct {
+		state GenesisState
+		valid bool
+	}{
+		"default":            {DefaultGenesisState(), true},
+		"valid":              {genesis([]Group{validGroup}, []Proposal{validProposal}, []Vote{validVote}), true},
+		"nested":             {genesis([]Group{validGroup, newGroup(1, 0, validGroup.ID)}, nil, nil), true},
+		"no nesting depth":   {GenesisState{Sequences: Sequences{NextGroupID: 1}}, false},
+		"group sequence":     {GenesisState{Params: DefaultParams(), Groups: []Group{validGroup}}, false},
+		"duplicate group":    {genesis([]Group{validGroup, validGroup}, nil, nil), false},
+		"invalid group":      {genesis([]Group{newGroup(0, 0)}, nil, nil), false},
+		"not a group id":     {genesis([]Group{{ID: alice, Members: validGroup.Members, DecisionPolicy: threshold, VotingPolicy: DefaultVotingPolicy}}, nil, nil), false},
+		"cycle":              {genesis([]Group{newGroup(0, 0, addrFromUint64(1)), newGroup(1, 0, addrFromUint64(0))}, nil, nil), false},
+		"three levels":       {genesis([]Group{newGroup(0, 0, alice), newGroup(1, 0, addrFromUint64(0)), newGroup(2, 0, addrFromUint64(1))}, nil, nil), true},
+		"proposal sequence":  {genesis([]Group{validGroup}, []Proposal{validProposal, {ID: 1, Group: validGroup.ID, Status: StatusOpen, GroupVersion: 1}}, nil), false},
+		"duplicate proposal": {genesis([]Group{validGroup}, []Proposal{validProposal, validProposal}, nil), false},
+		"unknown group":      {genesis([]Group{validGroup}, []Proposal{unknownGroup}, nil), false},
+		"no status":          {genesis([]Group{validGroup}, []Proposal{noStatus}, nil), false},
+		"old version":        {genesis([]Group{validGroup}, []Proposal{oldVersion}, nil), false},
+		"invalidated":        {genesis([]Group{validGroup}, []Proposal{invalidated}, nil), true},
+		"unknown proposal":   {genesis([]Group{validGroup}, nil, []Vote{validVote}), false},
+		"duplicate vote":     {genesis([]Group{validGroup}, []Proposal{validProposal}, []Vote{validVote, validVote}), false},
+		"no option":          {genesis([]Group{validGroup}, []Proposal{validProposal}, []Vote{{ProposalID: 0, Voter: alice}}), false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateGenesis(tc.state)
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+
+	deep := genesis([]Group{newGroup(0, 0, alice), newGroup(1, 0, addrFromUint64(0)), newGroup(2, 0, addrFromUint64(1))}, nil, nil)
+	deep.Params.MaxNestingDepth = 2
+	require.Error(t, ValidateGenesis(deep))
+}
@@ -5,10 +5,17 @@ import (
 	//"fmt"
 	//"github.com/DATA-DOG/godog"
 	//"github.com/DATA-DOG/godog/gherkin"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/baseapp"
 	"github.com/cosmos/cosmos-sdk/codec"
 	"github.com/cosmos/cosmos-sdk/store"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/x/auth"
+	"github.com/cosmos/cosmos-sdk/x/bank"
+	banktypes "github.com/cosmos/cosmos-sdk/x/bank/types"
+	"github.com/cosmos/cosmos-sdk/x/delegation"
+	"github.com/cosmos/cosmos-sdk/x/params"
 
 	abci "github.com/tendermint/tendermint/abci/types"
 	//"github.com/tendermint/tendermint/crypto"
@@ -21,31 +28,47 @@ import (
 var cdc *codec.Codec
 var ctx sdk.Context
 var keeper Keeper
+var bankKeeper bank.Keeper
 
 func setupTestInput() {
 	db := dbm.NewMemDB()
+	sdk.GetConfig().SetAddressVerifier(VerifyAddressFormat)
 
 	cdc = codec.New()
 	auth.RegisterCodec(cdc)
+	bank.RegisterCodec(cdc)
+	sdk.RegisterCodec(cdc)
 	codec.RegisterCrypto(cdc)
+	delegation.RegisterCodec(cdc)
 	RegisterCodec(cdc)
 
 	paramsKey := sdk.NewKVStoreKey("params")
-	// tparamsKey := sdk.NewTransientStoreKey("tparams")
+	tparamsKey := sdk.NewTransientStoreKey("tparams")
 	accKey := sdk.NewKVStoreKey("acc")
+	delegationKey := sdk.NewKVStoreKey("delegationKey")
 	groupKey := sdk.NewKVStoreKey("groupKey")
 
 	ms := store.NewCommitMultiStore(db)
 	ms.MountStoreWithDB(accKey, sdk.StoreTypeIAVL, db)
 	ms.MountStoreWithDB(paramsKey, sdk.StoreTypeIAVL, db)
+	ms.MountStoreWithDB(tparamsKey, sdk.StoreTypeTransient, db)
+	ms.MountStoreWithDB(delegationKey, sdk.StoreTypeIAVL, db)
 	ms.MountStoreWithDB(groupKey, sdk.StoreTypeIAVL, db)
 	_ = ms.LoadLatestVersion()
 
-	// paramsKeeper := params.NewKeeper(cdc, paramsKey, tparamsKey, params.DefaultCodespace)
-	// accKeeper := auth.NewAccountKeeper(cdc, accKey, paramsKeeper.Subspace(auth.DefaultParamspace), auth.ProtoBaseAccount)
+	paramsKeeper := params.NewKeeper(cdc, paramsKey, tparamsKey, params.DefaultCodespace)
+	accKeeper := auth.NewAccountKeeper(cdc, accKey, paramsKeeper.Subspace(auth.DefaultParamspace), auth.ProtoBaseAccount)
+	bankKeeper = bank.NewBaseKeeper(accKeeper, paramsKeeper.Subspace(banktypes.DefaultParamspace), banktypes.DefaultCodespace)
+
+	router := baseapp.NewRouter()
+	router.AddRoute(bank.RouterKey, bank.NewHandler(bankKeeper))
 
-	// keeper = NewKeeper(groupKey, cdc, accKeeper, nil)
-	ctx = sdk.NewContext(ms, abci.Header{ChainID: "test-chain-id"}, false, log.NewNopLogger())
+	keeper = NewKeeper(groupKey, cdc, accKeeper, delegation.NewKeeper(delegationKey, cdc, router), paramsKeeper.Subspace(DefaultParamspace))
+	router.AddRoute(RouterKey, NewHandler(keeper))
+	ctx = sdk.NewContext(ms, abci.Header{ChainID: "test-chain-id", Time: time.Now().UTC()}, false, log.NewNopLogger())
+	accKeeper.SetParams(ctx, auth.DefaultParams())
+	bankKeeper.SetSendEnabled(ctx, true)
+	keeper.SetParams(ctx, DefaultParams())
 }
 
 //var privKey secp256k1.PrivKeySecp256k1
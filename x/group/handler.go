@@ -22,6 +22,12 @@ func NewHandler(keeper Keeper) sdk.Handler {
 			return handleMsgWithdrawProposal(ctx, keeper, msg)
 		case MsgUpdateGroup:
 			return handleMsgUpdateGroup(ctx, keeper, msg)
+		case MsgUpdateGroupMembers:
+			return handleMsgUpdateGroupMembers(ctx, keeper, msg)
+		case MsgUpdateGroupThreshold:
+			return handleMsgUpdateGroupThreshold(ctx, keeper, msg)
+		case MsgUpdateGroupMetadata:
+			return handleMsgUpdateGroupMetadata(ctx, keeper, msg)
 		default:
 			errMsg := fmt.Sprintf("Unrecognized data Msg type: %v", msg.Type())
 			return sdk.ErrUnknownRequest(errMsg).Result()
@@ -57,7 +63,7 @@ func handleMsgCreateProposal(ctx sdk.Context, keeper Keeper, msg MsgCreatePropos
 }
 
 func handleMsgVote(ctx sdk.Context, keeper Keeper, msg MsgVote) sdk.Result {
-	return keeper.Vote(ctx, msg.ProposalID, msg.Voter, msg.Vote)
+	return keeper.Vote(ctx, msg.ProposalID, msg.Voter, msg.Option)
 }
 
 func handleMsgTryExecuteProposal(ctx sdk.Context, keeper Keeper, msg MsgTryExecuteProposal) sdk.Result {
@@ -69,6 +75,29 @@ func handleMsgWithdrawProposal(ctx sdk.Context, keeper Keeper, msg MsgWithdrawPr
 }
 
 func handleMsgUpdateGroup(ctx sdk.Context, keeper Keeper, msg MsgUpdateGroup) sdk.Result {
-	keeper.UpdateGroupInfo(ctx, msg.GroupID, msg.Data)
-	return sdk.Result{}
+	return groupUpdatedResult(keeper.UpdateGroupInfo(ctx, msg.GroupID, msg.Data))
+}
+
+func handleMsgUpdateGroupMembers(ctx sdk.Context, keeper Keeper, msg MsgUpdateGroupMembers) sdk.Result {
+	return groupUpdatedResult(keeper.UpdateGroupMembers(ctx, msg.Group, msg.MemberUpdates))
+}
+
+func handleMsgUpdateGroupThreshold(ctx sdk.Context, keeper Keeper, msg MsgUpdateGroupThreshold) sdk.Result {
+	return groupUpdatedResult(keeper.UpdateGroupDecisionPolicy(ctx, msg.Group, msg.DecisionPolicy))
+}
+
+func handleMsgUpdateGroupMetadata(ctx sdk.Context, keeper Keeper, msg MsgUpdateGroupMetadata) sdk.Result {
+	return groupUpdatedResult(keeper.UpdateGroupMemo(ctx, msg.Group, msg.Memo))
+}
+
+func groupUpdatedResult(info Group, err sdk.Error) sdk.Result {
+	if err != nil {
+		return err.Result()
+	}
+	return sdk.Result{
+		Tags: sdk.NewTags(
+			"group.id", []byte(info.ID.String()),
+			"group.version", []byte(fmt.Sprintf("%d", info.Version)),
+		),
+	}
 }
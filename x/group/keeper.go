@@ -3,12 +3,15 @@ package group
 import (
 	"bytes"
 	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"time"
 
 	"github.com/cosmos/cosmos-sdk/codec"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/x/auth"
 	"github.com/cosmos/cosmos-sdk/x/delegation"
+	"github.com/cosmos/cosmos-sdk/x/params/subspace"
 	"github.com/tendermint/tendermint/crypto"
 	"github.com/tendermint/tendermint/libs/bech32"
 )
@@ -18,17 +21,36 @@ type Keeper struct {
 	cdc           *codec.Codec
 	accountKeeper auth.AccountKeeper
 	dispatcher    delegation.Keeper
+	paramSpace    subspace.Subspace
 }
 
-func NewKeeper(groupStoreKey sdk.StoreKey, cdc *codec.Codec, accountKeeper auth.AccountKeeper, dispatcher delegation.Keeper) Keeper {
-	return Keeper{
+// NewKeeper creates a group keeper and adds it as an Authorizer of
+// dispatcher, so group members can act for their groups
+func NewKeeper(groupStoreKey sdk.StoreKey, cdc *codec.Codec, accountKeeper auth.AccountKeeper, dispatcher delegation.Keeper, paramSpace subspace.Subspace) Keeper {
+	keeper := Keeper{
 		groupStoreKey,
 		cdc,
 		accountKeeper,
 		dispatcher,
+		paramSpace.WithKeyTable(ParamKeyTable()),
 	}
+	dispatcher.AddAuthorizer(keeper)
+	return keeper
 }
 
+// SetParams sets the group module's parameters.
+func (keeper Keeper) SetParams(ctx sdk.Context, params Params) {
+	keeper.paramSpace.SetParamSet(ctx, &params)
+}
+
+// GetParams gets the group module's parameters.
+func (keeper Keeper) GetParams(ctx sdk.Context) (params Params) {
+	keeper.paramSpace.GetParamSet(ctx, &params)
+	return
+}
+
+var _ delegation.Authorizer = Keeper{}
+
 type GroupAccount struct {
 	*auth.BaseAccount
 }
@@ -42,20 +64,80 @@ var (
 	keyNewProposalID = []byte("newProposalID")
 )
 
+// Every kind of entry has its own key prefix, so iterating over one kind
+// never yields another. Proposal ids have a fixed width to keep them in order.
+var (
+	keyGroupPrefix          = []byte("g/")
+	keyMemberIndexPrefix    = []byte("m/")
+	keyProposalPrefix       = []byte("p/")
+	keyGroupProposalsPrefix = []byte("r/")
+	keyVotePrefix           = []byte("v/")
+)
+
+// KeyGroupID stores a group
 func KeyGroupID(id sdk.AccAddress) []byte {
-	return []byte(fmt.Sprintf("g/%x", id))
+	return []byte(fmt.Sprintf("%s%x", keyGroupPrefix, id))
 }
 
+func keyGroupsByMemberAddressPrefix(addr sdk.AccAddress) []byte {
+	return []byte(fmt.Sprintf("%s%x/", keyMemberIndexPrefix, addr))
+}
+
+// KeyGroupIDByMemberAddress indexes the groups of a member, the value is the
+// group id
 func KeyGroupIDByMemberAddress(addr sdk.AccAddress, id sdk.AccAddress) []byte {
-	return []byte(fmt.Sprintf("g/%x/%x", addr, id))
+	return []byte(fmt.Sprintf("%s%x", keyGroupsByMemberAddressPrefix(addr), id))
+}
+
+func keyProposalsByGroupIDPrefix(groupID sdk.AccAddress) []byte {
+	return []byte(fmt.Sprintf("%s%x/", keyGroupProposalsPrefix, groupID))
 }
 
+// KeyProposalsByGroupID indexes the proposals of a group, the value is the
+// big endian proposal id
 func KeyProposalsByGroupID(groupID sdk.AccAddress, proposalID ProposalID) []byte {
-	return []byte(fmt.Sprintf("p/%x/%x", groupID, proposalID))
+	return []byte(fmt.Sprintf("%s%016x", keyProposalsByGroupIDPrefix(groupID), uint64(proposalID)))
 }
 
+// KeyProposal stores a proposal
 func KeyProposal(id ProposalID) []byte {
-	return []byte(fmt.Sprintf("p/%x", id))
+	return []byte(fmt.Sprintf("%s%016x", keyProposalPrefix, uint64(id)))
+}
+
+func keyVotesPrefix(id ProposalID) []byte {
+	return []byte(fmt.Sprintf("%s%016x/", keyVotePrefix, uint64(id)))
+}
+
+// KeyVote stores the VoteOption of voter on a proposal
+func KeyVote(id ProposalID, voter sdk.AccAddress) []byte {
+	return []byte(fmt.Sprintf("%s%x", keyVotesPrefix(id), voter))
+}
+
+// voteFromKey returns the proposal id and voter of a KeyVote
+func voteFromKey(key []byte) (ProposalID, sdk.AccAddress) {
+	key = key[len(keyVotePrefix):]
+	id, err := hex.DecodeString(string(key[:16]))
+	if err != nil {
+		panic(err)
+	}
+	voter, err := hex.DecodeString(string(key[17:]))
+	if err != nil {
+		panic(err)
+	}
+	return ProposalID(binary.BigEndian.Uint64(id)), voter
+}
+
+// keyVotingEndQueuePrefix orders open and accepted proposals by the end of
+// their voting period
+var keyVotingEndQueuePrefix = []byte("q/")
+
+func keyVotingEndQueueTime(endTime time.Time) []byte {
+	return append(append([]byte{}, keyVotingEndQueuePrefix...), sdk.FormatTimeBytes(endTime)...)
+}
+
+// KeyVotingEndQueue queues the proposal id until endTime, the value is empty
+func KeyVotingEndQueue(endTime time.Time, id ProposalID) []byte {
+	return append(keyVotingEndQueueTime(endTime), sdk.Uint64ToBigEndian(uint64(id))...)
 }
 
 func (keeper Keeper) GetGroupInfo(ctx sdk.Context, id sdk.AccAddress) (info Group, err sdk.Error) {
@@ -75,60 +157,122 @@ func (keeper Keeper) GetGroupInfo(ctx sdk.Context, id sdk.AccAddress) (info Grou
 	return info, nil
 }
 
-// GetGroups gets all groups
-func (keeper Keeper) GetGroups(ctx sdk.Context) []Group {
-	prefix := fmt.Sprintf("g/")
-	prefixBytes := []byte(prefix)
+// IterateGroups calls cb with every group until it returns true
+func (keeper Keeper) IterateGroups(ctx sdk.Context, cb func(group Group) (stop bool)) {
 	store := ctx.KVStore(keeper.storeKey)
-	var groups []Group
-	iter := sdk.KVStorePrefixIterator(store, prefixBytes)
+	iter := sdk.KVStorePrefixIterator(store, keyGroupPrefix)
 	defer iter.Close()
 	for ; iter.Valid(); iter.Next() {
 		var group Group
-		// keeper.cdc.MustUnmarshalBinaryBare(iter.Value(), &group)
-		keeper.cdc.MustUnmarshalBinaryLengthPrefixed(iter.Value(), &group)
-		groups = append(groups, group)
+		keeper.cdc.MustUnmarshalBinaryBare(iter.Value(), &group)
+		if cb(group) {
+			return
+		}
 	}
+}
 
+// GetGroups gets all groups
+func (keeper Keeper) GetGroups(ctx sdk.Context) []Group {
+	var groups []Group
+	keeper.IterateGroups(ctx, func(group Group) bool {
+		groups = append(groups, group)
+		return false
+	})
 	return groups
 }
 
+// IterateGroupsByMemberAddress calls cb with every group memberAddr is a
+// member of until it returns true
+func (keeper Keeper) IterateGroupsByMemberAddress(ctx sdk.Context, memberAddr sdk.AccAddress, cb func(group Group) (stop bool)) {
+	for _, id := range keeper.groupIDsByMemberAddress(ctx, memberAddr) {
+		group, err := keeper.GetGroupInfo(ctx, id)
+		if err != nil {
+			panic(err)
+		}
+		if cb(group) {
+			return
+		}
+	}
+}
+
 // GetGroupsByMemberAddress get groups that I'm a member of
-// key: g/%x/%x
-// g/[member address]/[group id] -> [group id]
 func (keeper Keeper) GetGroupsByMemberAddress(ctx sdk.Context, memberAddr sdk.AccAddress) []Group {
-	prefix := fmt.Sprintf("g/%x/", memberAddr)
-	prefixBytes := []byte(prefix)
-	store := ctx.KVStore(keeper.storeKey)
 	var groups []Group
-	iter := sdk.KVStorePrefixIterator(store, prefixBytes)
+	keeper.IterateGroupsByMemberAddress(ctx, memberAddr, func(group Group) bool {
+		groups = append(groups, group)
+		return false
+	})
+	return groups
+}
+
+// IterateProposalsByGroupID calls cb with every proposal of a group, ordered
+// by id, until it returns true
+func (keeper Keeper) IterateProposalsByGroupID(ctx sdk.Context, groupID sdk.AccAddress, cb func(proposal Proposal) (stop bool)) {
+	store := ctx.KVStore(keeper.storeKey)
+	iter := sdk.KVStorePrefixIterator(store, keyProposalsByGroupIDPrefix(groupID))
 	defer iter.Close()
 	for ; iter.Valid(); iter.Next() {
-		groupID := iter.Value()
-		group, err := keeper.GetGroupInfo(ctx, groupID)
+		proposal, err := keeper.GetProposal(ctx, ProposalID(binary.BigEndian.Uint64(iter.Value())))
 		if err != nil {
 			panic(err)
 		}
-		groups = append(groups, group)
+		if cb(*proposal) {
+			return
+		}
 	}
-
-	return groups
 }
 
 func (keeper Keeper) GetProposalsByGroupID(ctx sdk.Context, groupID sdk.AccAddress) []Proposal {
-	prefix := fmt.Sprintf("p/%x/", groupID)
-	prefixBytes := []byte(prefix)
-	store := ctx.KVStore(keeper.storeKey)
 	var proposals []Proposal
-	iter := sdk.KVStorePrefixIterator(store, prefixBytes)
+	keeper.IterateProposalsByGroupID(ctx, groupID, func(proposal Proposal) bool {
+		proposals = append(proposals, proposal)
+		return false
+	})
+	return proposals
+}
+
+// IterateProposals calls cb with every proposal, ordered by id, until it
+// returns true
+func (keeper Keeper) IterateProposals(ctx sdk.Context, cb func(proposal Proposal) (stop bool)) {
+	store := ctx.KVStore(keeper.storeKey)
+	iter := sdk.KVStorePrefixIterator(store, keyProposalPrefix)
 	defer iter.Close()
 	for ; iter.Valid(); iter.Next() {
 		var proposal Proposal
 		keeper.cdc.MustUnmarshalBinaryBare(iter.Value(), &proposal)
-		proposals = append(proposals, proposal)
+		if cb(proposal) {
+			return
+		}
 	}
+}
 
-	return proposals
+// IterateVotes calls cb with every vote on a proposal until it returns true
+func (keeper Keeper) IterateVotes(ctx sdk.Context, proposalID ProposalID, cb func(vote Vote) (stop bool)) {
+	keeper.iterateVotes(ctx, keyVotesPrefix(proposalID), cb)
+}
+
+// GetVotes returns the votes on a proposal
+func (keeper Keeper) GetVotes(ctx sdk.Context, proposalID ProposalID) []Vote {
+	var votes []Vote
+	keeper.IterateVotes(ctx, proposalID, func(vote Vote) bool {
+		votes = append(votes, vote)
+		return false
+	})
+	return votes
+}
+
+func (keeper Keeper) iterateVotes(ctx sdk.Context, prefix []byte, cb func(vote Vote) (stop bool)) {
+	store := ctx.KVStore(keeper.storeKey)
+	iter := sdk.KVStorePrefixIterator(store, prefix)
+	defer iter.Close()
+	for ; iter.Valid(); iter.Next() {
+		var vote Vote
+		vote.ProposalID, vote.Voter = voteFromKey(iter.Key())
+		keeper.cdc.MustUnmarshalBinaryBare(iter.Value(), &vote.Option)
+		if cb(vote) {
+			return
+		}
+	}
 }
 
 func addrFromUint64(id uint64) sdk.AccAddress {
@@ -138,21 +282,56 @@ func addrFromUint64(id uint64) sdk.AccAddress {
 	return addr[:n+1]
 }
 
-func (keeper Keeper) getNewGroupId(ctx sdk.Context) sdk.AccAddress {
-	store := ctx.KVStore(keeper.storeKey)
-	bz := store.Get(keyNewGroupID)
-	var groupId uint64 = 0
+// groupIDToUint64 returns the sequence number of a group id
+func groupIDToUint64(id sdk.AccAddress) (uint64, error) {
+	if len(id) < 2 || id[0] != 'G' {
+		return 0, fmt.Errorf("%X is not a group id", []byte(id))
+	}
+	seq, n := binary.Uvarint(id[1:])
+	if n != len(id)-1 {
+		return 0, fmt.Errorf("%X is not a group id", []byte(id))
+	}
+	return seq, nil
+}
+
+// VerifyAddressFormat accepts group ids next to the default addresses, apps
+// with groups should set it with sdk.GetConfig().SetAddressVerifier so group
+// ids can be decoded from bech32 and JSON
+func VerifyAddressFormat(bz []byte) error {
+	if _, err := groupIDToUint64(bz); err == nil {
+		return nil
+	}
+	if len(bz) != sdk.AddrLen {
+		return fmt.Errorf("Incorrect address length")
+	}
+	return nil
+}
+
+func (keeper Keeper) peekSequence(ctx sdk.Context, key []byte) uint64 {
+	bz := ctx.KVStore(keeper.storeKey).Get(key)
+	var seq uint64 = 0
 	if bz != nil {
-		keeper.cdc.MustUnmarshalBinaryBare(bz, &groupId)
+		keeper.cdc.MustUnmarshalBinaryBare(bz, &seq)
 	}
-	bz = keeper.cdc.MustMarshalBinaryBare(groupId + 1)
-	store.Set(keyNewGroupID, bz)
+	return seq
+}
+
+func (keeper Keeper) setSequence(ctx sdk.Context, key []byte, seq uint64) {
+	ctx.KVStore(keeper.storeKey).Set(key, keeper.cdc.MustMarshalBinaryBare(seq))
+}
+
+func (keeper Keeper) getNewGroupId(ctx sdk.Context) sdk.AccAddress {
+	groupId := keeper.peekSequence(ctx, keyNewGroupID)
+	keeper.setSequence(ctx, keyNewGroupID, groupId+1)
 	return addrFromUint64(groupId)
 }
 
 func (keeper Keeper) CreateGroup(ctx sdk.Context, info Group) (sdk.AccAddress, sdk.Error) {
 	id := keeper.getNewGroupId(ctx)
 	info.ID = id
+	if err := keeper.checkNesting(ctx, info); err != nil {
+		return nil, err
+	}
 	keeper.setGroupInfo(ctx, id, info)
 	acct := &GroupAccount{
 		BaseAccount: &auth.BaseAccount{
@@ -165,16 +344,19 @@ func (keeper Keeper) CreateGroup(ctx sdk.Context, info Group) (sdk.AccAddress, s
 	}
 	keeper.accountKeeper.SetAccount(ctx, acct)
 
-	// iterate through members in group and add member <-> group id association
-	for _, member := range info.Members {
-		key := KeyGroupIDByMemberAddress(member.Address, id)
-		store := ctx.KVStore(keeper.storeKey)
-		store.Set(key, id)
-	}
+	keeper.setMemberIndex(ctx, info)
 
 	return id, nil
 }
 
+// setMemberIndex adds the member <-> group id association of every member
+func (keeper Keeper) setMemberIndex(ctx sdk.Context, info Group) {
+	store := ctx.KVStore(keeper.storeKey)
+	for _, member := range info.Members {
+		store.Set(KeyGroupIDByMemberAddress(member.Address, info.ID), info.ID)
+	}
+}
+
 func (keeper Keeper) setGroupInfo(ctx sdk.Context, id sdk.AccAddress, info Group) {
 	store := ctx.KVStore(keeper.storeKey)
 	bz, err := keeper.cdc.MarshalBinaryBare(info)
@@ -184,8 +366,103 @@ func (keeper Keeper) setGroupInfo(ctx sdk.Context, id sdk.AccAddress, info Group
 	store.Set(KeyGroupID(id), bz)
 }
 
-func (keeper Keeper) UpdateGroupInfo(ctx sdk.Context, id sdk.AccAddress, info Group) {
-	keeper.setGroupInfo(ctx, id, info)
+// UpdateGroupInfo replaces the group with info
+func (keeper Keeper) UpdateGroupInfo(ctx sdk.Context, id sdk.AccAddress, info Group) (Group, sdk.Error) {
+	old, err := keeper.GetGroupInfo(ctx, id)
+	if err != nil {
+		return old, err
+	}
+	return keeper.updateGroup(ctx, old, info)
+}
+
+// UpdateGroupMembers sets the weight of the members in updates, adding the
+// ones that aren't members yet and removing the ones with weight zero
+func (keeper Keeper) UpdateGroupMembers(ctx sdk.Context, id sdk.AccAddress, updates []Member) (Group, sdk.Error) {
+	old, err := keeper.GetGroupInfo(ctx, id)
+	if err != nil {
+		return old, err
+	}
+	updated := old
+	updated.Members = append([]Member{}, old.Members...)
+	for _, update := range updates {
+		found := false
+		for i, mem := range updated.Members {
+			if mem.Address.Equals(update.Address) {
+				updated.Members = append(updated.Members[:i], updated.Members[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found && update.Weight.IsZero() {
+			return old, sdk.ErrUnknownRequest(fmt.Sprintf("%s is not a member of the group", update.Address))
+		}
+		if !update.Weight.IsZero() {
+			updated.Members = append(updated.Members, update)
+		}
+	}
+	return keeper.updateGroup(ctx, old, updated)
+}
+
+// UpdateGroupDecisionPolicy replaces the decision policy of the group
+func (keeper Keeper) UpdateGroupDecisionPolicy(ctx sdk.Context, id sdk.AccAddress, policy DecisionPolicy) (Group, sdk.Error) {
+	old, err := keeper.GetGroupInfo(ctx, id)
+	if err != nil {
+		return old, err
+	}
+	updated := old
+	updated.DecisionPolicy = policy
+	return keeper.updateGroup(ctx, old, updated)
+}
+
+// UpdateGroupMemo replaces the memo of the group
+func (keeper Keeper) UpdateGroupMemo(ctx sdk.Context, id sdk.AccAddress, memo string) (Group, sdk.Error) {
+	old, err := keeper.GetGroupInfo(ctx, id)
+	if err != nil {
+		return old, err
+	}
+	updated := old
+	updated.Memo = memo
+	return keeper.updateGroup(ctx, old, updated)
+}
+
+// updateGroup stores updated as the next version of old, keeps the member
+// index in sync and invalidates the proposals of the group that are still
+// pending, as they were voted on by the old members under the old policy
+func (keeper Keeper) updateGroup(ctx sdk.Context, old Group, updated Group) (Group, sdk.Error) {
+	if err := updated.ValidateBasic(); err != nil {
+		return old, err
+	}
+	updated.ID = old.ID
+	updated.Version = old.Version + 1
+	if err := keeper.checkNesting(ctx, updated); err != nil {
+		return old, err
+	}
+
+	store := ctx.KVStore(keeper.storeKey)
+	for _, mem := range old.Members {
+		store.Delete(KeyGroupIDByMemberAddress(mem.Address, old.ID))
+	}
+	keeper.setMemberIndex(ctx, updated)
+	keeper.setGroupInfo(ctx, old.ID, updated)
+
+	for _, proposal := range keeper.GetProposalsByGroupID(ctx, old.ID) {
+		if proposal.Status.IsClosed() {
+			continue
+		}
+		proposal.Status = StatusInvalidated
+		store.Delete(KeyVotingEndQueue(proposal.VotingEndTime, proposal.ID))
+		keeper.storeProposal(ctx, proposal.ID, &proposal)
+	}
+	return updated, nil
+}
+
+// checkGroupVersion returns an error when the group changed since proposal
+// was submitted
+func checkGroupVersion(proposal *Proposal, info Group) sdk.Error {
+	if proposal.GroupVersion != info.Version {
+		return sdk.ErrUnknownRequest(fmt.Sprintf("group changed to version %d since the proposal was submitted", info.Version))
+	}
+	return nil
 }
 
 func (keeper Keeper) Authorize(ctx sdk.Context, group sdk.AccAddress, signers []sdk.AccAddress) bool {
@@ -197,28 +474,134 @@ func (keeper Keeper) Authorize(ctx sdk.Context, group sdk.AccAddress, signers []
 	return keeper.AuthorizeGroupInfo(ctx, &info, signers)
 }
 
+// AuthorizeGroupInfo returns whether the decision policy of the group allows
+// signers to act for it, counting the members that signed, directly or
+// through a group they are a member of, as yes votes. Groups nested deeper
+// than MaxNestingDepth don't authorize anyone.
 func (keeper Keeper) AuthorizeGroupInfo(ctx sdk.Context, info *Group, signers []sdk.AccAddress) bool {
-	voteCount := sdk.NewInt(0)
-	sigThreshold := info.DecisionThreshold
-
-	nMembers := len(info.Members)
-	nSigners := len(signers)
-	for i := 0; i < nMembers; i++ {
-		mem := info.Members[i]
-		// TODO Use a hash map to optimize this
-		for j := 0; j < nSigners; j++ {
-			ctx.GasMeter().ConsumeGas(10, "check addr")
-			if bytes.Compare(mem.Address, signers[j]) == 0 || keeper.Authorize(ctx, mem.Address, signers) {
-				voteCount = voteCount.Add(mem.Weight)
-				diff := voteCount.Sub(sigThreshold)
-				if diff.IsZero() || diff.IsPositive() {
-					return true
+	signed := make(map[string]bool, len(signers))
+	for _, signer := range signers {
+		signed[string(signer)] = true
+	}
+	memo := make(map[authorizationKey]bool)
+	return keeper.authorizeGroup(ctx, info, signed, memo, keeper.GetParams(ctx).MaxNestingDepth)
+}
+
+// authorizationKey identifies whether a member authorizes signers with depth
+// levels of nesting left
+type authorizationKey struct {
+	member string
+	depth  uint64
+}
+
+// authorizeGroup evaluates a group with depth levels of nesting left,
+// including its own. The results for nested groups are kept in memo, so each
+// group is evaluated at most once per level for the same signers.
+func (keeper Keeper) authorizeGroup(ctx sdk.Context, info *Group, signed map[string]bool, memo map[authorizationKey]bool, depth uint64) bool {
+	if info.DecisionPolicy == nil || depth == 0 {
+		return false
+	}
+	tally := NewTally()
+	totalWeight := info.TotalWeight()
+	for _, mem := range info.Members {
+		ctx.GasMeter().ConsumeGas(10, "check addr")
+		if !signed[string(mem.Address)] && !keeper.authorizeMember(ctx, mem.Address, signed, memo, depth-1) {
+			continue
+		}
+		tally.Yes = tally.Yes.Add(mem.Weight)
+		if info.DecisionPolicy.Allow(tally, totalWeight) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeMember returns whether a member that didn't sign is a group that
+// authorizes signers
+func (keeper Keeper) authorizeMember(ctx sdk.Context, member sdk.AccAddress, signed map[string]bool, memo map[authorizationKey]bool, depth uint64) bool {
+	key := authorizationKey{string(member), depth}
+	if allowed, ok := memo[key]; ok {
+		return allowed
+	}
+	allowed := false
+	if info, err := keeper.GetGroupInfo(ctx, member); err == nil {
+		ctx.GasMeter().ConsumeGas(10, "group auth")
+		allowed = keeper.authorizeGroup(ctx, &info, signed, memo, depth)
+	}
+	memo[key] = allowed
+	return allowed
+}
+
+// checkNesting returns an error when info would be a member of itself,
+// directly or through other groups, or when the nesting of any group
+// containing info would get deeper than MaxNestingDepth. The existing groups
+// never contain cycles, so only the ones through info need to be looked for.
+func (keeper Keeper) checkNesting(ctx sdk.Context, info Group) sdk.Error {
+	height, err := keeper.nestingHeight(ctx, info, info.ID, make(map[string]uint64))
+	if err != nil {
+		return err
+	}
+	depth := height + keeper.nestingAncestors(ctx, info.ID, make(map[string]uint64))
+	if max := keeper.GetParams(ctx).MaxNestingDepth; depth > max {
+		return sdk.ErrUnknownRequest(fmt.Sprintf("groups would be nested %d levels deep, the maximum is %d", depth, max))
+	}
+	return nil
+}
+
+// nestingHeight returns the levels of groups within info, including info
+// itself, or an error if root is one of them
+func (keeper Keeper) nestingHeight(ctx sdk.Context, info Group, root sdk.AccAddress, memo map[string]uint64) (uint64, sdk.Error) {
+	var height uint64
+	for _, mem := range info.Members {
+		if mem.Address.Equals(root) {
+			return 0, sdk.ErrUnknownRequest(fmt.Sprintf("group %s can't be a member of itself", root))
+		}
+		h, ok := memo[string(mem.Address)]
+		if !ok {
+			nested, err := keeper.GetGroupInfo(ctx, mem.Address)
+			if err == nil {
+				ctx.GasMeter().ConsumeGas(10, "group nesting")
+				h, err = keeper.nestingHeight(ctx, nested, root, memo)
+				if err != nil {
+					return 0, err
 				}
-				break
 			}
+			memo[string(mem.Address)] = h
+		}
+		if h > height {
+			height = h
 		}
 	}
-	return false
+	return height + 1, nil
+}
+
+// nestingAncestors returns the levels of groups that contain id, directly or
+// through other groups
+func (keeper Keeper) nestingAncestors(ctx sdk.Context, id sdk.AccAddress, memo map[string]uint64) uint64 {
+	if levels, ok := memo[string(id)]; ok {
+		return levels
+	}
+	var levels uint64
+	for _, parent := range keeper.groupIDsByMemberAddress(ctx, id) {
+		ctx.GasMeter().ConsumeGas(10, "group nesting")
+		if l := keeper.nestingAncestors(ctx, parent, memo) + 1; l > levels {
+			levels = l
+		}
+	}
+	memo[string(id)] = levels
+	return levels
+}
+
+// groupIDsByMemberAddress returns the ids of the groups addr is a member of
+func (keeper Keeper) groupIDsByMemberAddress(ctx sdk.Context, addr sdk.AccAddress) []sdk.AccAddress {
+	store := ctx.KVStore(keeper.storeKey)
+	iter := sdk.KVStorePrefixIterator(store, keyGroupsByMemberAddressPrefix(addr))
+	defer iter.Close()
+	var ids []sdk.AccAddress
+	for ; iter.Valid(); iter.Next() {
+		ids = append(ids, sdk.AccAddress(iter.Value()))
+	}
+	return ids
 }
 
 const (
@@ -236,43 +619,63 @@ func mustEncodeProposalIDBech32(id ProposalID) string {
 }
 
 func MustDecodeProposalIDBech32(bech string) ProposalID {
-	hrp, data, err := bech32.DecodeAndConvert(bech)
+	id, err := decodeProposalIDBech32(bech)
 	if err != nil {
 		panic(err)
 	}
+	return id
+}
+
+func decodeProposalIDBech32(bech string) (ProposalID, error) {
+	hrp, data, err := bech32.DecodeAndConvert(bech)
+	if err != nil {
+		return 0, err
+	}
 	if hrp != Bech32Prefix {
-		panic(fmt.Sprintf("Expected bech32 prefix %s", Bech32Prefix))
+		return 0, fmt.Errorf("Expected bech32 prefix %s", Bech32Prefix)
 	}
 	id, err := binary.ReadUvarint(bytes.NewBuffer(data))
 	if err != nil {
-		panic(err)
+		return 0, err
 	}
-	return ProposalID(id)
+	return ProposalID(id), nil
 }
 
 func (keeper Keeper) getNewProposalId(ctx sdk.Context) ProposalID {
-	store := ctx.KVStore(keeper.storeKey)
-	bz := store.Get(keyNewProposalID)
-	var id uint64 = 0
-	if bz != nil {
-		keeper.cdc.MustUnmarshalBinaryBare(bz, &id)
-	}
-	bz = keeper.cdc.MustMarshalBinaryBare(id + 1)
-	store.Set(keyNewProposalID, bz)
+	id := keeper.peekSequence(ctx, keyNewProposalID)
+	keeper.setSequence(ctx, keyNewProposalID, id+1)
 	return ProposalID(id)
 }
 
 func (keeper Keeper) Propose(ctx sdk.Context, proposer sdk.AccAddress, group sdk.AccAddress, msgs []sdk.Msg) (ProposalID, sdk.Result) {
-	id := keeper.getNewProposalId(ctx)
+	info, err := keeper.GetGroupInfo(ctx, group)
+	if err != nil {
+		return 0, err.Result()
+	}
 
+	id := keeper.getNewProposalId(ctx)
+	now := ctx.BlockHeader().Time
 	prop := Proposal{
-		Group:     group,
-		Proposer:  proposer,
-		Msgs:      msgs,
-		Approvers: []sdk.AccAddress{proposer},
-	}
+		ID:            id,
+		Group:         group,
+		Proposer:      proposer,
+		Msgs:          msgs,
+		Tally:         NewTally(),
+		GroupVersion:  info.Version,
+		SubmitTime:    now,
+		VotingEndTime: now.Add(info.VotingPolicy.VotingPeriod),
+	}
+	// members vote yes on their own proposals
+	if weight := info.MemberWeight(proposer); weight.IsPositive() {
+		keeper.setVote(ctx, id, proposer, OptionYes)
+		prop.Tally.Yes = prop.Tally.Yes.Add(weight)
+	}
+	updateStatus(&prop, info)
 
 	keeper.storeProposal(ctx, id, &prop)
+	if !prop.Status.IsClosed() {
+		ctx.KVStore(keeper.storeKey).Set(KeyVotingEndQueue(prop.VotingEndTime, id), []byte{})
+	}
 
 	res := sdk.Result{}
 	res.Tags = res.Tags.
@@ -280,6 +683,25 @@ func (keeper Keeper) Propose(ctx sdk.Context, proposer sdk.AccAddress, group sdk
 	return id, res
 }
 
+// updateStatus accepts a proposal once the decision policy of the group
+// accepts its tally and rejects it as soon as the members that didn't vote
+// can't change that, even if they all vote yes. Proposals accepted by a
+// quorum can be opened again by later no votes.
+func updateStatus(proposal *Proposal, info Group) {
+	totalWeight := info.TotalWeight()
+	if info.DecisionPolicy.Allow(proposal.Tally, totalWeight) {
+		proposal.Status = StatusAccepted
+		return
+	}
+	best := proposal.Tally
+	best.Yes = best.Yes.Add(totalWeight.Sub(proposal.Tally.Total()))
+	if !info.DecisionPolicy.Allow(best, totalWeight) {
+		proposal.Status = StatusRejected
+		return
+	}
+	proposal.Status = StatusOpen
+}
+
 func (keeper Keeper) storeProposal(ctx sdk.Context, id ProposalID, proposal *Proposal) {
 	store := ctx.KVStore(keeper.storeKey)
 	bz, err := keeper.cdc.MarshalBinaryBare(proposal)
@@ -288,13 +710,16 @@ func (keeper Keeper) storeProposal(ctx sdk.Context, id ProposalID, proposal *Pro
 	}
 
 	store.Set(KeyProposal(id), bz)
-	store.Set(KeyProposalsByGroupID(proposal.Group, id), bz)
+	store.Set(KeyProposalsByGroupID(proposal.Group, id), sdk.Uint64ToBigEndian(uint64(id)))
 }
 
 func (keeper Keeper) GetProposal(ctx sdk.Context, id ProposalID) (proposal *Proposal, err sdk.Error) {
 	store := ctx.KVStore(keeper.storeKey)
 	bz := store.Get(KeyProposal(id))
 	proposal = &Proposal{}
+	if bz == nil {
+		return proposal, sdk.ErrUnknownRequest("can't find proposal")
+	}
 	marshalErr := keeper.cdc.UnmarshalBinaryBare(bz, proposal)
 	if marshalErr != nil {
 		return proposal, sdk.ErrUnknownRequest(marshalErr.Error())
@@ -302,7 +727,25 @@ func (keeper Keeper) GetProposal(ctx sdk.Context, id ProposalID) (proposal *Prop
 	return proposal, nil
 }
 
-func (keeper Keeper) Vote(ctx sdk.Context, proposalId ProposalID, voter sdk.AccAddress, yesNo bool) sdk.Result {
+// checkVotingPeriod returns an error when proposal is closed or voting on it
+// ended
+func checkVotingPeriod(ctx sdk.Context, proposal *Proposal) sdk.Error {
+	if proposal.Status.IsClosed() {
+		return sdk.ErrUnknownRequest(fmt.Sprintf("proposal is %s", proposal.Status))
+	}
+	if !ctx.BlockHeader().Time.Before(proposal.VotingEndTime) {
+		return sdk.ErrUnknownRequest("voting period has ended")
+	}
+	return nil
+}
+
+// Vote records the ballot of a member of the group on a proposal, ballots
+// can't be changed once cast
+func (keeper Keeper) Vote(ctx sdk.Context, proposalId ProposalID, voter sdk.AccAddress, option VoteOption) sdk.Result {
+	// msgs routed by DispatchActions skip ValidateBasic
+	if option.String() == "" {
+		return sdk.ErrUnknownRequest(fmt.Sprintf("invalid vote option %d", option)).Result()
+	}
 	proposal, err := keeper.GetProposal(ctx, proposalId)
 
 	if err != nil {
@@ -311,80 +754,109 @@ func (keeper Keeper) Vote(ctx sdk.Context, proposalId ProposalID, voter sdk.AccA
 			Log:  "can't find proposal",
 		}
 	}
+	if err := checkVotingPeriod(ctx, proposal); err != nil {
+		return err.Result()
+	}
 
-	var newVotes []sdk.AccAddress
-	votes := proposal.Approvers
-	nVotes := len(votes)
-
-	if yesNo {
-		newVotes = make([]sdk.AccAddress, nVotes+1)
-		for i := 0; i < nVotes; i++ {
-			oldVoter := votes[i]
-			if bytes.Equal(voter, oldVoter) {
-				// Already voted YES
-				return sdk.Result{
-					Code: sdk.CodeUnknownRequest,
-					Log:  "already voted yes",
-				}
-			}
-			newVotes[i] = oldVoter
-		}
-		newVotes[nVotes] = voter
-	} else {
-		newVotes = make([]sdk.AccAddress, nVotes)
-		didntVote := true
-		j := 0
-		for i := 0; i < nVotes; i++ {
-			oldVoter := votes[i]
-			if bytes.Equal(voter, oldVoter) {
-				didntVote = false
-			} else {
-				newVotes[j] = oldVoter
-				j++
-			}
-		}
-		if didntVote {
-			return sdk.Result{
-				Code: sdk.CodeUnknownRequest,
-				Log:  "didn't vote yes previously",
-			}
-		}
-		if j != nVotes-1 {
-			panic("unexpected vote count")
-		}
-		newVotes = newVotes[:j]
+	info, err := keeper.GetGroupInfo(ctx, proposal.Group)
+	if err != nil {
+		return err.Result()
+	}
+	if err := checkGroupVersion(proposal, info); err != nil {
+		return err.Result()
+	}
+	weight := info.MemberWeight(voter)
+	if !weight.IsPositive() {
+		return sdk.ErrUnauthorized(fmt.Sprintf("%s is not a member of the group", voter)).Result()
+	}
+	if _, found := keeper.GetVote(ctx, proposalId, voter); found {
+		return sdk.ErrUnknownRequest("already voted").Result()
 	}
 
-	newProp := Proposal{
-		Proposer:  proposal.Proposer,
-		Msgs:      proposal.Msgs,
-		Approvers: newVotes,
+	tally, err := proposal.Tally.Add(option, weight)
+	if err != nil {
+		return err.Result()
+	}
+	keeper.setVote(ctx, proposalId, voter, option)
+	proposal.Tally = tally
+	updateStatus(proposal, info)
+	if proposal.Status.IsClosed() {
+		ctx.KVStore(keeper.storeKey).Delete(KeyVotingEndQueue(proposal.VotingEndTime, proposalId))
 	}
 
-	keeper.storeProposal(ctx, proposalId, &newProp)
+	keeper.storeProposal(ctx, proposalId, proposal)
 
 	return sdk.Result{Code: sdk.CodeOK,
 		Tags: sdk.EmptyTags().
-			AppendTag("proposal.id", mustEncodeProposalIDBech32(proposalId)),
+			AppendTag("proposal.id", mustEncodeProposalIDBech32(proposalId)).
+			AppendTag("proposal.status", proposal.Status.String()),
+	}
+}
+
+func (keeper Keeper) setVote(ctx sdk.Context, proposalId ProposalID, voter sdk.AccAddress, option VoteOption) {
+	ctx.KVStore(keeper.storeKey).Set(KeyVote(proposalId, voter), keeper.cdc.MustMarshalBinaryBare(option))
+}
+
+func (keeper Keeper) deleteVotes(ctx sdk.Context, proposalId ProposalID) {
+	store := ctx.KVStore(keeper.storeKey)
+	iter := sdk.KVStorePrefixIterator(store, keyVotesPrefix(proposalId))
+	var keys [][]byte
+	for ; iter.Valid(); iter.Next() {
+		keys = append(keys, iter.Key())
+	}
+	iter.Close()
+	for _, key := range keys {
+		store.Delete(key)
+	}
+}
+
+// GetVote returns the ballot of voter on a proposal
+func (keeper Keeper) GetVote(ctx sdk.Context, proposalId ProposalID, voter sdk.AccAddress) (option VoteOption, found bool) {
+	bz := ctx.KVStore(keeper.storeKey).Get(KeyVote(proposalId, voter))
+	if bz == nil {
+		return OptionEmpty, false
 	}
+	keeper.cdc.MustUnmarshalBinaryBare(bz, &option)
+	return option, true
 }
 
+// TryExecute dispatches the msgs of an accepted proposal once its minimum
+// execution period passed
 func (keeper Keeper) TryExecute(ctx sdk.Context, proposalId ProposalID) sdk.Result {
 	proposal, err := keeper.GetProposal(ctx, proposalId)
 
 	if err != nil {
 		return sdk.ErrUnknownRequest("can't find proposal").Result()
 	}
+	if err := checkVotingPeriod(ctx, proposal); err != nil {
+		return err.Result()
+	}
 
-	if !keeper.Authorize(ctx, proposal.Group, proposal.Approvers) {
+	if proposal.Status != StatusAccepted {
 		return sdk.ErrUnauthorized("proposal failed").Result()
 	}
 
+	info, err := keeper.GetGroupInfo(ctx, proposal.Group)
+	if err != nil {
+		return err.Result()
+	}
+	if err := checkGroupVersion(proposal, info); err != nil {
+		return err.Result()
+	}
+	executable := proposal.SubmitTime.Add(info.VotingPolicy.MinExecutionPeriod)
+	if ctx.BlockHeader().Time.Before(executable) {
+		return sdk.ErrUnauthorized(fmt.Sprintf("proposal can't be executed before %s", executable)).Result()
+	}
+
 	res := keeper.dispatcher.DispatchActions(ctx, proposal.Group, proposal.Msgs)
 
 	if res.Code == sdk.CodeOK {
-		store := ctx.KVStore(keeper.storeKey)
-		store.Delete(KeyProposal(proposalId))
+		proposal.Status = StatusExecuted
+		keeper.storeProposal(ctx, proposalId, proposal)
+		ctx.KVStore(keeper.storeKey).Delete(KeyVotingEndQueue(proposal.VotingEndTime, proposalId))
+		res.Tags = res.Tags.
+			AppendTag("proposal.id", mustEncodeProposalIDBech32(proposalId)).
+			AppendTag("proposal.status", proposal.Status.String())
 	}
 
 	return res
@@ -406,12 +878,53 @@ func (keeper Keeper) Withdraw(ctx sdk.Context, proposalId ProposalID, proposer s
 			Log:  "you didn't propose this",
 		}
 	}
+	if proposal.Status.IsClosed() {
+		return sdk.ErrUnknownRequest(fmt.Sprintf("proposal is %s", proposal.Status)).Result()
+	}
 
 	store := ctx.KVStore(keeper.storeKey)
 	store.Delete(KeyProposal(proposalId))
+	store.Delete(KeyProposalsByGroupID(proposal.Group, proposalId))
+	store.Delete(KeyVotingEndQueue(proposal.VotingEndTime, proposalId))
+	keeper.deleteVotes(ctx, proposalId)
 
 	return sdk.Result{Code: sdk.CodeOK,
 		Tags: sdk.EmptyTags().
 			AppendTag("proposal.id", mustEncodeProposalIDBech32(proposalId)),
 	}
 }
+
+// closeProposal rejects a proposal that is still open when voting ends and
+// expires one that was accepted but not executed
+func (keeper Keeper) closeProposal(ctx sdk.Context, proposalId ProposalID) sdk.Tags {
+	proposal, err := keeper.GetProposal(ctx, proposalId)
+	if err != nil {
+		panic(err)
+	}
+	ctx.KVStore(keeper.storeKey).Delete(KeyVotingEndQueue(proposal.VotingEndTime, proposalId))
+	if proposal.Status == StatusAccepted {
+		proposal.Status = StatusExpired
+	} else {
+		proposal.Status = StatusRejected
+	}
+	keeper.storeProposal(ctx, proposalId, proposal)
+	return sdk.NewTags(
+		"proposal.id", mustEncodeProposalIDBech32(proposalId),
+		"proposal.status", proposal.Status.String(),
+	)
+}
+
+// endedProposals returns the ids of the proposals whose voting period ended
+// by the time of the block
+func (keeper Keeper) endedProposals(ctx sdk.Context) []ProposalID {
+	store := ctx.KVStore(keeper.storeKey)
+	iter := store.Iterator(keyVotingEndQueuePrefix, sdk.PrefixEndBytes(keyVotingEndQueueTime(ctx.BlockHeader().Time)))
+	defer iter.Close()
+
+	var ids []ProposalID
+	for ; iter.Valid(); iter.Next() {
+		key := iter.Key()
+		ids = append(ids, ProposalID(binary.BigEndian.Uint64(key[len(key)-8:])))
+	}
+	return ids
+}
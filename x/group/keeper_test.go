@@ -0,0 +1,510 @@
+package group
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/bank"
+)
+
+var (
+	alice = sdk.AccAddress([]byte("alice_______________"))
+	bob   = sdk.AccAddress([]byte("bob_________________"))
+	carol = sdk.AccAddress([]byte("carol_______________"))
+)
+
+func trees(amount int64) sdk.Coins {
+	return sdk.NewCoins(sdk.NewInt64Coin("tree", amount))
+}
+
+// createTestGroup creates a group of alice, bob and carol with weights 1, 2
+// and 3 and funds it
+func createTestGroup(t *testing.T, decision DecisionPolicy, voting VotingPolicy) sdk.AccAddress {
+	info := Group{
+		Members: []Member{
+			{Address: alice, Weight: sdk.NewInt(1)},
+			{Address: bob, Weight: sdk.NewInt(2)},
+			{Address: carol, Weight: sdk.NewInt(3)},
+		},
+		DecisionPolicy: decision,
+		VotingPolicy:   voting,
+	}
+	require.Nil(t, info.ValidateBasic())
+	id, err := keeper.CreateGroup(ctx, info)
+	require.Nil(t, err)
+	require.Nil(t, bankKeeper.SetCoins(ctx, id, trees(100)))
+	return id
+}
+
+func atTime(t time.Time) sdk.Context {
+	return ctx.WithBlockHeader(abci.Header{Time: t})
+}
+
+func TestVotingPolicyValidateBasic(t *testing.T) {
+	require.Nil(t, DefaultVotingPolicy.ValidateBasic())
+	require.Nil(t, VotingPolicy{VotingPeriod: time.Hour, MinExecutionPeriod: time.Minute}.ValidateBasic())
+	require.NotNil(t, VotingPolicy{}.ValidateBasic())
+	require.NotNil(t, VotingPolicy{VotingPeriod: time.Hour, MinExecutionPeriod: time.Hour}.ValidateBasic())
+	require.NotNil(t, VotingPolicy{VotingPeriod: time.Hour, MinExecutionPeriod: -time.Minute}.ValidateBasic())
+}
+
+func TestProposalExecution(t *testing.T) {
+	setupTestInput()
+	now := ctx.BlockHeader().Time
+	group := createTestGroup(t, ThresholdDecisionPolicy{Threshold: sdk.NewInt(3)}, VotingPolicy{VotingPeriod: time.Hour, MinExecutionPeriod: 10 * time.Minute})
+	send := bank.NewMsgSend(group, carol, trees(30))
+
+	id, res := keeper.Propose(ctx, alice, group, []sdk.Msg{send})
+	require.True(t, res.IsOK(), "%v", res)
+	proposal, err := keeper.GetProposal(ctx, id)
+	require.Nil(t, err)
+	require.Equal(t, StatusOpen, proposal.Status)
+	require.Equal(t, now, proposal.SubmitTime)
+	require.Equal(t, now.Add(time.Hour), proposal.VotingEndTime)
+	require.False(t, keeper.TryExecute(ctx, id).IsOK())
+
+	require.True(t, keeper.Vote(ctx, id, bob, OptionYes).IsOK())
+	proposal, _ = keeper.GetProposal(ctx, id)
+	require.Equal(t, StatusAccepted, proposal.Status)
+
+	// votes can't be changed
+	require.False(t, keeper.Vote(ctx, id, bob, OptionNo).IsOK())
+	option, found := keeper.GetVote(ctx, id, bob)
+	require.True(t, found)
+	require.Equal(t, OptionYes, option)
+
+	// accepted proposals wait for the minimum execution period
+	require.False(t, keeper.TryExecute(atTime(now.Add(5*time.Minute)), id).IsOK())
+	res = keeper.TryExecute(atTime(now.Add(10*time.Minute)), id)
+	require.True(t, res.IsOK(), "%v", res)
+	require.Equal(t, trees(70), bankKeeper.GetCoins(ctx, group))
+
+	proposal, _ = keeper.GetProposal(ctx, id)
+	require.Equal(t, StatusExecuted, proposal.Status)
+	require.False(t, keeper.TryExecute(atTime(now.Add(20*time.Minute)), id).IsOK())
+	require.False(t, keeper.Vote(ctx, id, carol, OptionYes).IsOK())
+	require.False(t, keeper.Withdraw(ctx, id, alice).IsOK())
+	// executed proposals aren't closed again
+	require.Empty(t, EndBlocker(atTime(now.Add(time.Hour)), keeper))
+}
+
+func TestProposalEndBlocker(t *testing.T) {
+	setupTestInput()
+	now := ctx.BlockHeader().Time
+	group := createTestGroup(t, ThresholdDecisionPolicy{Threshold: sdk.NewInt(3)}, VotingPolicy{VotingPeriod: time.Hour})
+	send := bank.NewMsgSend(group, carol, trees(30))
+
+	rejected, _ := keeper.Propose(ctx, alice, group, []sdk.Msg{send})
+	expired, _ := keeper.Propose(ctx, alice, group, []sdk.Msg{send})
+	require.True(t, keeper.Vote(ctx, expired, bob, OptionYes).IsOK())
+	withdrawn, _ := keeper.Propose(ctx, bob, group, []sdk.Msg{send})
+	require.False(t, keeper.Withdraw(ctx, withdrawn, alice).IsOK())
+	require.True(t, keeper.Withdraw(ctx, withdrawn, bob).IsOK())
+	later, _ := keeper.Propose(atTime(now.Add(time.Minute)), alice, group, []sdk.Msg{send})
+
+	require.Empty(t, EndBlocker(atTime(now.Add(59*time.Minute)), keeper))
+	end := atTime(now.Add(time.Hour))
+	tags := EndBlocker(end, keeper)
+	require.Len(t, tags, 4)
+
+	// voting has ended
+	require.False(t, keeper.Vote(end, rejected, bob, OptionYes).IsOK())
+	require.False(t, keeper.TryExecute(end, expired).IsOK())
+	require.Equal(t, trees(100), bankKeeper.GetCoins(ctx, group))
+
+	statuses := make(map[ProposalID]ProposalStatus)
+	for _, status := range []ProposalStatus{StatusOpen, StatusAccepted, StatusRejected, StatusExpired} {
+		for _, p := range filterProposalsByStatus(keeper.GetProposalsByGroupID(end, group), status) {
+			require.Equal(t, status, p.Status)
+			statuses[p.ID] = p.Status
+		}
+	}
+	require.Equal(t, map[ProposalID]ProposalStatus{
+		rejected: StatusRejected,
+		expired:  StatusExpired,
+		later:    StatusOpen,
+	}, statuses)
+
+	querier := NewQuerier(keeper)
+	res, sdkErr := querier(end, []string{QueryProposal, mustEncodeProposalIDBech32(withdrawn)}, abci.RequestQuery{})
+	require.NotNil(t, sdkErr)
+	res, sdkErr = querier(end, []string{QueryProposal, mustEncodeProposalIDBech32(expired)}, abci.RequestQuery{})
+	require.Nil(t, sdkErr)
+	require.Contains(t, string(res), `"status": "expired"`)
+
+	// the later proposal is still open
+	require.True(t, keeper.Vote(end, later, bob, OptionYes).IsOK())
+}
+
+func TestWeightedVoting(t *testing.T) {
+	setupTestInput()
+	// a majority of the weight of all members decides
+	group := createTestGroup(t, PercentageDecisionPolicy{Percentage: sdk.NewDecWithPrec(5, 1)}, DefaultVotingPolicy)
+	send := bank.NewMsgSend(group, carol, trees(30))
+	stranger := sdk.AccAddress([]byte("stranger____________"))
+
+	accepted, _ := keeper.Propose(ctx, alice, group, []sdk.Msg{send})
+	require.False(t, keeper.Vote(ctx, accepted, stranger, OptionYes).IsOK())
+	require.Equal(t, sdk.CodeUnknownRequest, keeper.Vote(ctx, accepted, bob, VoteOption(0x05)).Code)
+	require.Equal(t, sdk.CodeUnknownRequest, keeper.Vote(ctx, accepted, bob, OptionEmpty).Code)
+	require.True(t, keeper.Vote(ctx, accepted, bob, OptionAbstain).IsOK())
+	proposal, _ := keeper.GetProposal(ctx, accepted)
+	require.Equal(t, StatusOpen, proposal.Status)
+	require.True(t, keeper.Vote(ctx, accepted, carol, OptionYes).IsOK())
+	proposal, _ = keeper.GetProposal(ctx, accepted)
+	require.Equal(t, StatusAccepted, proposal.Status)
+	require.Equal(t, Tally{Yes: sdk.NewInt(4), No: sdk.ZeroInt(), Abstain: sdk.NewInt(2), Veto: sdk.ZeroInt()}, proposal.Tally)
+
+	// carol voting no leaves too little weight to accept it
+	rejected, _ := keeper.Propose(ctx, bob, group, []sdk.Msg{send})
+	require.True(t, keeper.Vote(ctx, rejected, carol, OptionNo).IsOK())
+	proposal, _ = keeper.GetProposal(ctx, rejected)
+	require.Equal(t, StatusOpen, proposal.Status)
+	require.True(t, keeper.Vote(ctx, rejected, alice, OptionVeto).IsOK())
+	proposal, _ = keeper.GetProposal(ctx, rejected)
+	require.Equal(t, StatusRejected, proposal.Status)
+	require.False(t, keeper.TryExecute(ctx, rejected).IsOK())
+
+	// rejected proposals are closed before voting ends
+	end := atTime(ctx.BlockHeader().Time.Add(DefaultVotingPolicy.VotingPeriod))
+	require.Equal(t, []ProposalID{accepted}, keeper.endedProposals(end))
+
+	// signers are authorized by the same policy
+	require.False(t, keeper.Authorize(ctx, group, []sdk.AccAddress{alice, stranger}))
+	require.True(t, keeper.Authorize(ctx, group, []sdk.AccAddress{carol}))
+}
+
+func TestUpdateGroupMembers(t *testing.T) {
+	setupTestInput()
+	group := createTestGroup(t, ThresholdDecisionPolicy{Threshold: sdk.NewInt(3)}, DefaultVotingPolicy)
+	dave := sdk.AccAddress([]byte("dave________________"))
+	send := bank.NewMsgSend(group, carol, trees(30))
+
+	executed, _ := keeper.Propose(ctx, carol, group, []sdk.Msg{send})
+	require.True(t, keeper.TryExecute(ctx, executed).IsOK())
+	pending, _ := keeper.Propose(ctx, alice, group, []sdk.Msg{send})
+
+	_, err := keeper.UpdateGroupMembers(ctx, group, []Member{{Address: dave, Weight: sdk.ZeroInt()}})
+	require.NotNil(t, err)
+	info, err := keeper.UpdateGroupMembers(ctx, group, []Member{
+		{Address: alice, Weight: sdk.ZeroInt()},
+		{Address: bob, Weight: sdk.NewInt(5)},
+		{Address: dave, Weight: sdk.NewInt(1)},
+	})
+	require.Nil(t, err)
+	require.Equal(t, uint64(1), info.Version)
+	require.Equal(t, sdk.NewInt(9), info.TotalWeight())
+
+	require.Empty(t, keeper.GetGroupsByMemberAddress(ctx, alice))
+	require.Len(t, keeper.GetGroupsByMemberAddress(ctx, dave), 1)
+	require.Equal(t, sdk.NewInt(5), keeper.GetGroupsByMemberAddress(ctx, bob)[0].MemberWeight(bob))
+
+	// the pending proposal can't be passed by the new members
+	proposal, _ := keeper.GetProposal(ctx, pending)
+	require.Equal(t, StatusInvalidated, proposal.Status)
+	require.False(t, keeper.Vote(ctx, pending, bob, OptionYes).IsOK())
+	proposal, _ = keeper.GetProposal(ctx, executed)
+	require.Equal(t, StatusExecuted, proposal.Status)
+	require.Empty(t, keeper.endedProposals(atTime(ctx.BlockHeader().Time.Add(DefaultVotingPolicy.VotingPeriod))))
+
+	proposal = &Proposal{GroupVersion: 0}
+	require.NotNil(t, checkGroupVersion(proposal, info))
+}
+
+func TestUpdateGroupByProposal(t *testing.T) {
+	setupTestInput()
+	group := createTestGroup(t, ThresholdDecisionPolicy{Threshold: sdk.NewInt(3)}, DefaultVotingPolicy)
+	handler := NewHandler(keeper)
+
+	res := handler(ctx, MsgUpdateGroupMetadata{Group: group, Memo: "trees"})
+	require.True(t, res.IsOK(), "%v", res)
+	info, _ := keeper.GetGroupInfo(ctx, group)
+	require.Equal(t, "trees", info.Memo)
+	require.Equal(t, uint64(1), info.Version)
+
+	update := MsgUpdateGroupThreshold{Group: group, DecisionPolicy: ThresholdDecisionPolicy{Threshold: sdk.NewInt(6)}}
+	require.Nil(t, update.ValidateBasic())
+	other, _ := keeper.Propose(ctx, bob, group, []sdk.Msg{bank.NewMsgSend(group, bob, trees(10))})
+	id, _ := keeper.Propose(ctx, carol, group, []sdk.Msg{update})
+	res = keeper.TryExecute(ctx, id)
+	require.True(t, res.IsOK(), "%v", res)
+
+	info, _ = keeper.GetGroupInfo(ctx, group)
+	require.Equal(t, uint64(2), info.Version)
+	require.Equal(t, ThresholdDecisionPolicy{Threshold: sdk.NewInt(6)}, info.DecisionPolicy)
+	proposal, _ := keeper.GetProposal(ctx, id)
+	require.Equal(t, StatusExecuted, proposal.Status)
+	proposal, _ = keeper.GetProposal(ctx, other)
+	require.Equal(t, StatusInvalidated, proposal.Status)
+
+	// updates are validated like new groups
+	require.False(t, handler(ctx, MsgUpdateGroupMembers{Group: group, MemberUpdates: []Member{
+		{Address: alice, Weight: sdk.ZeroInt()},
+		{Address: bob, Weight: sdk.ZeroInt()},
+		{Address: carol, Weight: sdk.ZeroInt()},
+	}}).IsOK())
+	require.NotNil(t, MsgUpdateGroupMembers{Group: group, MemberUpdates: []Member{
+		{Address: alice, Weight: sdk.NewInt(1)},
+		{Address: alice, Weight: sdk.NewInt(2)},
+	}}.ValidateBasic())
+}
+
+func TestGroupCycles(t *testing.T) {
+	setupTestInput()
+	threshold := ThresholdDecisionPolicy{Threshold: sdk.NewInt(1)}
+	members := func(addrs ...sdk.AccAddress) []Member {
+		var mems []Member
+		for _, addr := range addrs {
+			mems = append(mems, Member{Address: addr, Weight: sdk.NewInt(1)})
+		}
+		return mems
+	}
+
+	// the id of the next group is known in advance
+	_, err := keeper.CreateGroup(ctx, Group{Members: members(alice, addrFromUint64(0)), DecisionPolicy: threshold, VotingPolicy: DefaultVotingPolicy})
+	require.NotNil(t, err)
+
+	inner, err := keeper.CreateGroup(ctx, Group{Members: members(alice), DecisionPolicy: threshold, VotingPolicy: DefaultVotingPolicy})
+	require.Nil(t, err)
+	outer, err := keeper.CreateGroup(ctx, Group{Members: members(bob, inner), DecisionPolicy: threshold, VotingPolicy: DefaultVotingPolicy})
+	require.Nil(t, err)
+	require.True(t, keeper.Authorize(ctx, outer, []sdk.AccAddress{alice}))
+
+	_, err = keeper.UpdateGroupMembers(ctx, inner, members(outer))
+	require.NotNil(t, err)
+	_, err = keeper.UpdateGroupMembers(ctx, outer, members(outer))
+	require.NotNil(t, err)
+
+	// inner is two levels below the top group
+	keeper.SetParams(ctx, NewParams(2))
+	_, err = keeper.CreateGroup(ctx, Group{Members: members(outer), DecisionPolicy: threshold, VotingPolicy: DefaultVotingPolicy})
+	require.NotNil(t, err)
+	nested, err := keeper.CreateGroup(ctx, Group{Members: members(carol), DecisionPolicy: threshold, VotingPolicy: DefaultVotingPolicy})
+	require.Nil(t, err)
+	_, err = keeper.UpdateGroupMembers(ctx, inner, members(nested))
+	require.NotNil(t, err)
+
+	// groups nested deeper than the limit don't authorize
+	keeper.SetParams(ctx, NewParams(1))
+	require.False(t, keeper.Authorize(ctx, outer, []sdk.AccAddress{alice}))
+	require.True(t, keeper.Authorize(ctx, outer, []sdk.AccAddress{bob}))
+}
+
+// groupModel mirrors the groups created in the store, with members of
+// weight one and a threshold policy
+type groupModel struct {
+	members   map[string][]sdk.AccAddress
+	threshold map[string]int
+}
+
+// height returns the levels of groups in members, including the group itself,
+// or false if root is one of them
+func (m groupModel) height(members []sdk.AccAddress, root sdk.AccAddress) (uint64, bool) {
+	var height uint64
+	for _, mem := range members {
+		if mem.Equals(root) {
+			return 0, false
+		}
+		if nested, ok := m.members[string(mem)]; ok {
+			h, ok := m.height(nested, root)
+			if !ok {
+				return 0, false
+			}
+			if h > height {
+				height = h
+			}
+		}
+	}
+	return height + 1, true
+}
+
+// ancestors returns the levels of groups that contain id
+func (m groupModel) ancestors(id sdk.AccAddress) uint64 {
+	var levels uint64
+	for parent, members := range m.members {
+		for _, mem := range members {
+			if mem.Equals(id) {
+				if l := m.ancestors(sdk.AccAddress(parent)) + 1; l > levels {
+					levels = l
+				}
+			}
+		}
+	}
+	return levels
+}
+
+func (m groupModel) valid(id sdk.AccAddress, members []sdk.AccAddress, maxDepth uint64) bool {
+	height, ok := m.height(members, id)
+	return ok && height+m.ancestors(id) <= maxDepth
+}
+
+// authorize evaluates a group without memoization
+func (m groupModel) authorize(id string, signed map[string]bool, depth uint64) bool {
+	if depth == 0 {
+		return false
+	}
+	yes := 0
+	for _, mem := range m.members[id] {
+		if signed[string(mem)] {
+			yes++
+		} else if _, ok := m.members[string(mem)]; ok && m.authorize(string(mem), signed, depth-1) {
+			yes++
+		}
+	}
+	return yes >= m.threshold[id]
+}
+
+func TestRandomGroupGraphs(t *testing.T) {
+	const maxDepth = 3
+	accounts := make([]sdk.AccAddress, 5)
+	for i := range accounts {
+		accounts[i] = sdk.AccAddress([]byte(fmt.Sprintf("account%013d", i)))
+	}
+
+	for seed := int64(0); seed < 20; seed++ {
+		setupTestInput()
+		keeper.SetParams(ctx, NewParams(maxDepth))
+		r := rand.New(rand.NewSource(seed))
+		model := groupModel{members: make(map[string][]sdk.AccAddress), threshold: make(map[string]int)}
+		var groups []sdk.AccAddress
+		// failed creations still use up an id here, as ctx isn't cached
+		var nextID uint64
+
+		randomMembers := func(self sdk.AccAddress) []sdk.AccAddress {
+			var members []sdk.AccAddress
+			for _, addr := range append(append(append([]sdk.AccAddress{}, accounts...), groups...), self) {
+				if r.Intn(3) == 0 {
+					members = append(members, addr)
+				}
+			}
+			if len(members) == 0 {
+				members = append(members, accounts[r.Intn(len(accounts))])
+			}
+			return members
+		}
+		toGroup := func(members []sdk.AccAddress, threshold int) Group {
+			info := Group{DecisionPolicy: ThresholdDecisionPolicy{Threshold: sdk.NewInt(int64(threshold))}, VotingPolicy: DefaultVotingPolicy}
+			for _, mem := range members {
+				info.Members = append(info.Members, Member{Address: mem, Weight: sdk.NewInt(1)})
+			}
+			return info
+		}
+		checkAuthorize := func() {
+			signers := make(map[string]bool)
+			var signerList []sdk.AccAddress
+			for _, addr := range accounts {
+				if r.Intn(2) == 0 {
+					signers[string(addr)] = true
+					signerList = append(signerList, addr)
+				}
+			}
+			for _, id := range groups {
+				require.Equal(t, model.authorize(string(id), signers, maxDepth), keeper.Authorize(ctx, id, signerList),
+					"seed %d group %x signers %v", seed, id, signerList)
+			}
+		}
+
+		for i := 0; i < 30; i++ {
+			if len(groups) == 0 || r.Intn(2) == 0 {
+				next := addrFromUint64(nextID)
+				nextID++
+				members := randomMembers(next)
+				threshold := 1 + r.Intn(len(members))
+				expected := model.valid(next, members, maxDepth)
+				id, err := keeper.CreateGroup(ctx, toGroup(members, threshold))
+				require.Equal(t, expected, err == nil, "seed %d create %d: %v", seed, i, err)
+				if err == nil {
+					require.Equal(t, next, id)
+					groups = append(groups, id)
+					model.members[string(id)] = members
+					model.threshold[string(id)] = threshold
+				}
+			} else {
+				id := groups[r.Intn(len(groups))]
+				members := randomMembers(id)
+				threshold := 1 + r.Intn(len(members))
+				expected := model.valid(id, members, maxDepth)
+				_, err := keeper.UpdateGroupInfo(ctx, id, toGroup(members, threshold))
+				require.Equal(t, expected, err == nil, "seed %d update %x: %v", seed, id, err)
+				if err == nil {
+					model.members[string(id)] = members
+					model.threshold[string(id)] = threshold
+				}
+			}
+			checkAuthorize()
+		}
+	}
+}
+
+func TestPaginatedQueries(t *testing.T) {
+	setupTestInput()
+	querier := NewQuerier(keeper)
+	query := func(path string, params interface{}, result interface{}) {
+		res, err := querier(ctx, []string{path}, abci.RequestQuery{Data: cdc.MustMarshalJSON(params)})
+		require.Nil(t, err)
+		cdc.MustUnmarshalJSON(res, result)
+	}
+
+	var groups []sdk.AccAddress
+	for i := 0; i < 3; i++ {
+		groups = append(groups, createTestGroup(t, ThresholdDecisionPolicy{Threshold: sdk.NewInt(6)}, DefaultVotingPolicy))
+	}
+	var all []Group
+	query(QueryGroups, QueryGroupsParams{}, &all)
+	require.Len(t, all, 3)
+	var page []Group
+	query(QueryGroups, QueryGroupsParams{Page: 2, Limit: 2}, &page)
+	require.Equal(t, all[2:], page)
+	query(QueryGroupsByMember, QueryGroupsByMemberParams{Address: bob, Page: 1, Limit: 2}, &page)
+	require.Equal(t, all[:2], page)
+	query(QueryGroupsByMember, QueryGroupsByMemberParams{Address: bob, Page: 3, Limit: 2}, &page)
+	require.Empty(t, page)
+
+	// limits are capped and pages beyond the counter are rejected
+	p, err := newPager(2, 10*MaxQueryLimit)
+	require.Nil(t, err)
+	require.Equal(t, pager{start: MaxQueryLimit, end: 2 * MaxQueryLimit}, *p)
+	maxInt := int(^uint(0) >> 1)
+	_, err = querier(ctx, []string{QueryGroups}, abci.RequestQuery{Data: cdc.MustMarshalJSON(QueryGroupsParams{Page: maxInt, Limit: 2})})
+	require.NotNil(t, err)
+	require.Equal(t, sdk.CodeUnknownRequest, err.Code())
+
+	send := bank.NewMsgSend(groups[0], carol, trees(30))
+	var ids []ProposalID
+	for i := 0; i < 4; i++ {
+		id, _ := keeper.Propose(ctx, alice, groups[0], []sdk.Msg{send})
+		ids = append(ids, id)
+	}
+	require.True(t, keeper.Vote(ctx, ids[1], carol, OptionNo).IsOK())
+	var proposals []Proposal
+	query(QueryProposalsByGroupID, QueryProposalsByGroupIDrParams{Address: groups[0], Page: 2, Limit: 3}, &proposals)
+	require.Len(t, proposals, 1)
+	require.Equal(t, ids[3], proposals[0].ID)
+	query(QueryProposalsByGroupID, QueryProposalsByGroupIDrParams{Address: groups[0], Status: StatusOpen, Page: 1, Limit: 2}, &proposals)
+	require.Len(t, proposals, 2)
+	require.Equal(t, []ProposalID{ids[0], ids[2]}, []ProposalID{proposals[0].ID, proposals[1].ID})
+
+	require.True(t, keeper.Vote(ctx, ids[0], bob, OptionAbstain).IsOK())
+	var votes []Vote
+	query(QueryVotesByProposal, QueryVotesByProposalParams{ProposalID: ids[0]}, &votes)
+	require.Len(t, votes, 2)
+	query(QueryVotesByProposal, QueryVotesByProposalParams{ProposalID: ids[0], Page: 2, Limit: 1}, &votes)
+	require.Equal(t, keeper.GetVotes(ctx, ids[0])[1:], votes)
+}
+
+// filterProposalsByStatus returns the proposals with the given status
+func filterProposalsByStatus(proposals []Proposal, status ProposalStatus) []Proposal {
+	filtered := []Proposal{}
+	for _, p := range proposals {
+		if p.Status == status {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
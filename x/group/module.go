@@ -25,10 +25,27 @@ const StoreKey = ModuleName
 
 // AppModuleBasic defines the internal data for the module
 // ----------------------------------------------------------------------------
-type AppModuleBasic struct{}
+type AppModuleBasic struct {
+	cdc *codec.Codec
+}
 
 var _ module.AppModuleBasic = AppModuleBasic{}
 
+// NewAppModuleBasic creates an AppModuleBasic encoding genesis with cdc, the
+// app codec knowing the msgs of all modules proposals can contain. The zero
+// AppModuleBasic only knows the msgs of this module.
+func NewAppModuleBasic(cdc *codec.Codec) AppModuleBasic {
+	return AppModuleBasic{cdc: cdc}
+}
+
+// codec returns the codec genesis is encoded with
+func (a AppModuleBasic) codec() *codec.Codec {
+	if a.cdc == nil {
+		return moduleCodec
+	}
+	return a.cdc
+}
+
 // Name define the name of the module
 func (AppModuleBasic) Name() string {
 	return ModuleName
@@ -40,14 +57,14 @@ func (AppModuleBasic) RegisterCodec(cdc *codec.Codec) {
 }
 
 // DefaultGenesis creates the default genesis state for testing
-func (AppModuleBasic) DefaultGenesis() json.RawMessage {
-	return moduleCodec.MustMarshalJSON(DefaultGenesisState())
+func (a AppModuleBasic) DefaultGenesis() json.RawMessage {
+	return a.codec().MustMarshalJSON(DefaultGenesisState())
 }
 
 // ValidateGenesis validates the genesis state
-func (AppModuleBasic) ValidateGenesis(bz json.RawMessage) error {
+func (a AppModuleBasic) ValidateGenesis(bz json.RawMessage) error {
 	var data GenesisState
-	err := moduleCodec.UnmarshalJSON(bz, &data)
+	err := a.codec().UnmarshalJSON(bz, &data)
 	if err != nil {
 		return err
 	}
@@ -79,7 +96,7 @@ type AppModule struct {
 // NewAppModule creates a new app module
 func NewAppModule(keeper Keeper) AppModule {
 	return AppModule{
-		AppModuleBasic: AppModuleBasic{},
+		AppModuleBasic: NewAppModuleBasic(keeper.cdc),
 		keeper:         keeper,
 	}
 }
@@ -110,7 +127,7 @@ func (am AppModule) NewQuerierHandler() sdk.Querier {
 // InitGenesis enforces the creation of the genesis state for the delegation module
 func (am AppModule) InitGenesis(ctx sdk.Context, data json.RawMessage) []abci.ValidatorUpdate {
 	var genesisState GenesisState
-	moduleCodec.MustUnmarshalJSON(data, &genesisState)
+	am.codec().MustUnmarshalJSON(data, &genesisState)
 	InitGenesis(ctx, am.keeper, genesisState)
 	return []abci.ValidatorUpdate{}
 }
@@ -118,7 +135,7 @@ func (am AppModule) InitGenesis(ctx sdk.Context, data json.RawMessage) []abci.Va
 // ExportGenesis enforces exporting this module's data to a genesis file
 func (am AppModule) ExportGenesis(ctx sdk.Context) json.RawMessage {
 	gs := ExportGenesis(ctx, am.keeper)
-	return moduleCodec.MustMarshalJSON(gs)
+	return am.codec().MustMarshalJSON(gs)
 }
 
 // BeginBlock runs before a block is processed
@@ -127,6 +144,7 @@ func (AppModule) BeginBlock(_ sdk.Context, _ abci.RequestBeginBlock) sdk.Tags {
 }
 
 // EndBlock runs at the end of each block
-func (AppModule) EndBlock(_ sdk.Context, _ abci.RequestEndBlock) ([]abci.ValidatorUpdate, sdk.Tags) {
-	return []abci.ValidatorUpdate{}, sdk.EmptyTags()
+func (am AppModule) EndBlock(ctx sdk.Context, _ abci.RequestEndBlock) ([]abci.ValidatorUpdate, sdk.Tags) {
+	tags := EndBlocker(ctx, am.keeper)
+	return []abci.ValidatorUpdate{}, tags
 }
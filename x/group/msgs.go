@@ -22,6 +22,26 @@ type MsgUpdateGroup struct {
 	Data    Group          `json:"data"`
 }
 
+// Sets the weight of members of a group, members with weight zero are
+// removed and new members are added. Should be signed by the group, usually
+// through a proposal.
+type MsgUpdateGroupMembers struct {
+	Group         sdk.AccAddress `json:"group"`
+	MemberUpdates []Member       `json:"member_updates"`
+}
+
+// Replaces the decision policy of a group
+type MsgUpdateGroupThreshold struct {
+	Group          sdk.AccAddress `json:"group"`
+	DecisionPolicy DecisionPolicy `json:"decision_policy"`
+}
+
+// Replaces the memo of a group
+type MsgUpdateGroupMetadata struct {
+	Group sdk.AccAddress `json:"group"`
+	Memo  string         `json:"memo"`
+}
+
 type CapabilityUpdateGroup struct {
 	GroupIDs []sdk.AccAddress `json:"group_ids"`
 }
@@ -39,7 +59,7 @@ type MsgCreateProposal struct {
 type MsgVote struct {
 	ProposalID ProposalID     `json:"proposal_id"`
 	Voter      sdk.AccAddress `json:"voter"`
-	Vote       bool           `json:"vote"`
+	Option     VoteOption     `json:"option"`
 }
 
 type MsgTryExecuteProposal struct {
@@ -67,10 +87,18 @@ func (info Group) ValidateBasic() sdk.Error {
 	if len(info.Members) <= 0 {
 		return sdk.ErrUnknownRequest("Group must reference a non-empty set of members")
 	}
-	if !info.DecisionThreshold.IsPositive() {
-		return sdk.ErrUnknownRequest(fmt.Sprintf("DecisionThreshold must be a positive integer, got %s", info.DecisionThreshold.String()))
+	for _, mem := range info.Members {
+		if !mem.Weight.IsPositive() {
+			return sdk.ErrUnknownRequest(fmt.Sprintf("Weight of member %s must be a positive integer", mem.Address))
+		}
 	}
-	return nil
+	if info.DecisionPolicy == nil {
+		return sdk.ErrUnknownRequest("Group must have a DecisionPolicy")
+	}
+	if err := info.DecisionPolicy.ValidateBasic(); err != nil {
+		return err
+	}
+	return info.VotingPolicy.ValidateBasic()
 }
 
 func (msg MsgCreateGroup) ValidateBasic() sdk.Error {
@@ -119,7 +147,15 @@ func (msg MsgVote) Route() string { return "group" }
 
 func (msg MsgVote) Type() string { return "proposal.vote" }
 
-func (msg MsgVote) ValidateBasic() sdk.Error { return nil }
+func (msg MsgVote) ValidateBasic() sdk.Error {
+	if len(msg.Voter) == 0 {
+		return sdk.ErrInvalidAddress("missing voter")
+	}
+	if msg.Option.String() == "" {
+		return sdk.ErrUnknownRequest(fmt.Sprintf("invalid vote option %d", msg.Option))
+	}
+	return nil
+}
 
 func (msg MsgVote) GetSignBytes() []byte {
 	b, err := json.Marshal(msg)
@@ -197,6 +233,94 @@ func (msg MsgUpdateGroup) GetSigners() []sdk.AccAddress {
 	return []sdk.AccAddress{msg.GroupID}
 }
 
+func (msg MsgUpdateGroupMembers) Route() string { return "group" }
+
+func (msg MsgUpdateGroupMembers) Type() string { return "group.update-members" }
+
+func (msg MsgUpdateGroupMembers) ValidateBasic() sdk.Error {
+	if len(msg.Group) == 0 {
+		return sdk.ErrInvalidAddress("missing group")
+	}
+	if len(msg.MemberUpdates) == 0 {
+		return sdk.ErrUnknownRequest("MemberUpdates must not be empty")
+	}
+	seen := make(map[string]bool)
+	for _, mem := range msg.MemberUpdates {
+		if len(mem.Address) == 0 {
+			return sdk.ErrInvalidAddress("missing member address")
+		}
+		if seen[string(mem.Address)] {
+			return sdk.ErrUnknownRequest(fmt.Sprintf("duplicate member %s", mem.Address))
+		}
+		seen[string(mem.Address)] = true
+		if mem.Weight.IsNegative() {
+			return sdk.ErrUnknownRequest(fmt.Sprintf("Weight of member %s must not be negative", mem.Address))
+		}
+	}
+	return nil
+}
+
+func (msg MsgUpdateGroupMembers) GetSignBytes() []byte {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	return sdk.MustSortJSON(b)
+}
+
+func (msg MsgUpdateGroupMembers) GetSigners() []sdk.AccAddress {
+	return []sdk.AccAddress{msg.Group}
+}
+
+func (msg MsgUpdateGroupThreshold) Route() string { return "group" }
+
+func (msg MsgUpdateGroupThreshold) Type() string { return "group.update-threshold" }
+
+func (msg MsgUpdateGroupThreshold) ValidateBasic() sdk.Error {
+	if len(msg.Group) == 0 {
+		return sdk.ErrInvalidAddress("missing group")
+	}
+	if msg.DecisionPolicy == nil {
+		return sdk.ErrUnknownRequest("missing DecisionPolicy")
+	}
+	return msg.DecisionPolicy.ValidateBasic()
+}
+
+func (msg MsgUpdateGroupThreshold) GetSignBytes() []byte {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	return sdk.MustSortJSON(b)
+}
+
+func (msg MsgUpdateGroupThreshold) GetSigners() []sdk.AccAddress {
+	return []sdk.AccAddress{msg.Group}
+}
+
+func (msg MsgUpdateGroupMetadata) Route() string { return "group" }
+
+func (msg MsgUpdateGroupMetadata) Type() string { return "group.update-metadata" }
+
+func (msg MsgUpdateGroupMetadata) ValidateBasic() sdk.Error {
+	if len(msg.Group) == 0 {
+		return sdk.ErrInvalidAddress("missing group")
+	}
+	return nil
+}
+
+func (msg MsgUpdateGroupMetadata) GetSignBytes() []byte {
+	b, err := json.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	return sdk.MustSortJSON(b)
+}
+
+func (msg MsgUpdateGroupMetadata) GetSigners() []sdk.AccAddress {
+	return []sdk.AccAddress{msg.Group}
+}
+
 func (cap CapabilityUpdateGroup) MsgType() sdk.Msg {
 	return MsgUpdateGroup{}
 }
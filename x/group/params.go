@@ -0,0 +1,66 @@
+package group
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cosmos/cosmos-sdk/x/params/subspace"
+)
+
+// DefaultParamspace defines the default group module parameter subspace
+const DefaultParamspace = ModuleName
+
+// Default parameter values
+const (
+	DefaultMaxNestingDepth uint64 = 5
+)
+
+// Parameter keys
+var (
+	KeyMaxNestingDepth = []byte("MaxNestingDepth")
+)
+
+var _ subspace.ParamSet = &Params{}
+
+// Params defines the parameters for the group module.
+type Params struct {
+	// MaxNestingDepth is the maximum depth of groups that are members of
+	// other groups, a group of accounts only has a depth of one
+	MaxNestingDepth uint64 `json:"max_nesting_depth"`
+}
+
+// NewParams creates a new Params object
+func NewParams(maxNestingDepth uint64) Params {
+	return Params{
+		MaxNestingDepth: maxNestingDepth,
+	}
+}
+
+// ParamKeyTable for group module
+func ParamKeyTable() subspace.KeyTable {
+	return subspace.NewKeyTable().RegisterParamSet(&Params{})
+}
+
+// ParamSetPairs implements the ParamSet interface and returns all the key/value pairs
+// pairs of group module's parameters.
+// nolint
+func (p *Params) ParamSetPairs() subspace.ParamSetPairs {
+	return subspace.ParamSetPairs{
+		{Key: KeyMaxNestingDepth, Value: &p.MaxNestingDepth},
+	}
+}
+
+// DefaultParams returns a default set of parameters.
+func DefaultParams() Params {
+	return Params{
+		MaxNestingDepth: DefaultMaxNestingDepth,
+	}
+}
+
+// String implements the stringer interface.
+func (p Params) String() string {
+	var sb strings.Builder
+	sb.WriteString("Params: \n")
+	sb.WriteString(fmt.Sprintf("MaxNestingDepth: %d\n", p.MaxNestingDepth))
+	return sb.String()
+}
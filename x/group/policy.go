@@ -0,0 +1,205 @@
+package group
+
+import (
+	"encoding/json"
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+// VoteOption is the choice of a member on a proposal
+type VoteOption byte
+
+const (
+	OptionEmpty   VoteOption = 0x00
+	OptionYes     VoteOption = 0x01
+	OptionNo      VoteOption = 0x02
+	OptionAbstain VoteOption = 0x03
+	OptionVeto    VoteOption = 0x04
+)
+
+// VoteOptionFromString turns a string into a VoteOption
+func VoteOptionFromString(str string) (VoteOption, error) {
+	switch str {
+	case "yes":
+		return OptionYes, nil
+	case "no":
+		return OptionNo, nil
+	case "abstain":
+		return OptionAbstain, nil
+	case "veto":
+		return OptionVeto, nil
+	default:
+		return VoteOption(0xff), fmt.Errorf("'%s' is not a valid vote option", str)
+	}
+}
+
+// String implements the Stringer interface
+func (option VoteOption) String() string {
+	switch option {
+	case OptionYes:
+		return "yes"
+	case OptionNo:
+		return "no"
+	case OptionAbstain:
+		return "abstain"
+	case OptionVeto:
+		return "veto"
+	default:
+		return ""
+	}
+}
+
+// MarshalJSON marshals the option as a string
+func (option VoteOption) MarshalJSON() ([]byte, error) {
+	return json.Marshal(option.String())
+}
+
+// UnmarshalJSON unmarshals the option from a string
+func (option *VoteOption) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := VoteOptionFromString(s)
+	if err != nil {
+		return err
+	}
+	*option = parsed
+	return nil
+}
+
+// Tally is the weight of the members that voted for each option
+type Tally struct {
+	Yes     sdk.Int `json:"yes"`
+	No      sdk.Int `json:"no"`
+	Abstain sdk.Int `json:"abstain"`
+	Veto    sdk.Int `json:"veto"`
+}
+
+// NewTally returns a tally without votes
+func NewTally() Tally {
+	return Tally{
+		Yes:     sdk.ZeroInt(),
+		No:      sdk.ZeroInt(),
+		Abstain: sdk.ZeroInt(),
+		Veto:    sdk.ZeroInt(),
+	}
+}
+
+// Add returns the tally with weight added to option
+func (t Tally) Add(option VoteOption, weight sdk.Int) (Tally, sdk.Error) {
+	switch option {
+	case OptionYes:
+		t.Yes = t.Yes.Add(weight)
+	case OptionNo:
+		t.No = t.No.Add(weight)
+	case OptionAbstain:
+		t.Abstain = t.Abstain.Add(weight)
+	case OptionVeto:
+		t.Veto = t.Veto.Add(weight)
+	default:
+		return t, sdk.ErrUnknownRequest(fmt.Sprintf("invalid vote option %d", option))
+	}
+	return t, nil
+}
+
+// Total returns the weight of all votes
+func (t Tally) Total() sdk.Int {
+	return t.Yes.Add(t.No).Add(t.Abstain).Add(t.Veto)
+}
+
+// DecisionPolicy decides whether the members of a group accept a proposal
+// or, for authorization, whether signers can act for the group. Policies
+// must be monotonic in the yes votes, so adding yes votes never turns an
+// accepted tally into a rejected one.
+type DecisionPolicy interface {
+	// Allow returns whether tally accepts the proposal, where totalWeight is
+	// the weight of all members of the group
+	Allow(tally Tally, totalWeight sdk.Int) bool
+	ValidateBasic() sdk.Error
+}
+
+// ThresholdDecisionPolicy accepts proposals once the weight of the yes votes
+// reaches Threshold
+type ThresholdDecisionPolicy struct {
+	// A big integer is used here to avoid any potential vulnerabilities from
+	// overflow errors where large weight and threshold values are used.
+	Threshold sdk.Int `json:"threshold"`
+}
+
+var _ DecisionPolicy = ThresholdDecisionPolicy{}
+
+func (p ThresholdDecisionPolicy) Allow(tally Tally, totalWeight sdk.Int) bool {
+	return tally.Yes.GTE(p.Threshold)
+}
+
+func (p ThresholdDecisionPolicy) ValidateBasic() sdk.Error {
+	if !p.Threshold.IsPositive() {
+		return sdk.ErrUnknownRequest(fmt.Sprintf("Threshold must be a positive integer, got %s", p.Threshold))
+	}
+	return nil
+}
+
+// PercentageDecisionPolicy accepts proposals once the yes votes reach
+// Percentage of the weight of all members
+type PercentageDecisionPolicy struct {
+	Percentage sdk.Dec `json:"percentage"`
+}
+
+var _ DecisionPolicy = PercentageDecisionPolicy{}
+
+func (p PercentageDecisionPolicy) Allow(tally Tally, totalWeight sdk.Int) bool {
+	if !totalWeight.IsPositive() {
+		return false
+	}
+	return sdk.NewDecFromInt(tally.Yes).GTE(p.Percentage.MulInt(totalWeight))
+}
+
+func (p PercentageDecisionPolicy) ValidateBasic() sdk.Error {
+	if p.Percentage.IsNil() || !p.Percentage.IsPositive() || p.Percentage.GT(sdk.OneDec()) {
+		return sdk.ErrUnknownRequest(fmt.Sprintf("Percentage must be above 0 and at most 1, got %s", p.Percentage))
+	}
+	return nil
+}
+
+// QuorumDecisionPolicy accepts proposals once the members that voted reach
+// Quorum of the weight of all members and the yes votes reach Threshold of
+// the yes, no and veto votes. Abstaining counts towards the quorum only.
+// Proposals where the veto votes exceed VetoThreshold of the votes aren't
+// accepted, unless VetoThreshold is zero.
+type QuorumDecisionPolicy struct {
+	Quorum        sdk.Dec `json:"quorum"`
+	Threshold     sdk.Dec `json:"threshold"`
+	VetoThreshold sdk.Dec `json:"veto_threshold"`
+}
+
+var _ DecisionPolicy = QuorumDecisionPolicy{}
+
+func (p QuorumDecisionPolicy) Allow(tally Tally, totalWeight sdk.Int) bool {
+	total := tally.Total()
+	if !total.IsPositive() || sdk.NewDecFromInt(total).LT(p.Quorum.MulInt(totalWeight)) {
+		return false
+	}
+	if !p.VetoThreshold.IsZero() && sdk.NewDecFromInt(tally.Veto).GT(p.VetoThreshold.MulInt(total)) {
+		return false
+	}
+	decisive := tally.Yes.Add(tally.No).Add(tally.Veto)
+	if !decisive.IsPositive() {
+		return false
+	}
+	return sdk.NewDecFromInt(tally.Yes).GTE(p.Threshold.MulInt(decisive))
+}
+
+func (p QuorumDecisionPolicy) ValidateBasic() sdk.Error {
+	if p.Quorum.IsNil() || p.Quorum.IsNegative() || p.Quorum.GT(sdk.OneDec()) {
+		return sdk.ErrUnknownRequest(fmt.Sprintf("Quorum must be between 0 and 1, got %s", p.Quorum))
+	}
+	if p.Threshold.IsNil() || !p.Threshold.IsPositive() || p.Threshold.GT(sdk.OneDec()) {
+		return sdk.ErrUnknownRequest(fmt.Sprintf("Threshold must be above 0 and at most 1, got %s", p.Threshold))
+	}
+	if p.VetoThreshold.IsNil() || p.VetoThreshold.IsNegative() || p.VetoThreshold.GT(sdk.OneDec()) {
+		return sdk.ErrUnknownRequest(fmt.Sprintf("VetoThreshold must be between 0 and 1, got %s", p.VetoThreshold))
+	}
+	return nil
+}
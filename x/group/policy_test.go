@@ -0,0 +1,64 @@
+package group
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestDecisionPolicies(t *testing.T) {
+	tally := func(yes, no, abstain, veto int64) Tally {
+		return Tally{Yes: sdk.NewInt(yes), No: sdk.NewInt(no), Abstain: sdk.NewInt(abstain), Veto: sdk.NewInt(veto)}
+	}
+	total := sdk.NewInt(10)
+	threshold := ThresholdDecisionPolicy{Threshold: sdk.NewInt(4)}
+	percentage := PercentageDecisionPolicy{Percentage: sdk.NewDecWithPrec(5, 1)}
+	quorum := QuorumDecisionPolicy{Quorum: sdk.NewDecWithPrec(4, 1), Threshold: sdk.NewDecWithPrec(5, 1), VetoThreshold: sdk.NewDecWithPrec(3, 1)}
+
+	cases := map[string]struct {
+		policy DecisionPolicy
+		tally  Tally
+		allow  bool
+	}{
+		"threshold reached":     {threshold, tally(4, 6, 0, 0), true},
+		"threshold not reached": {threshold, tally(3, 0, 0, 0), false},
+		"percentage reached":    {percentage, tally(5, 0, 0, 0), true},
+		"percentage not met":    {percentage, tally(4, 0, 1, 0), false},
+		"quorum met":            {quorum, tally(2, 1, 1, 0), true},
+		"no quorum":             {quorum, tally(2, 1, 0, 0), false},
+		"abstain not decisive":  {quorum, tally(1, 1, 8, 0), true},
+		"threshold not met":     {quorum, tally(2, 2, 0, 1), false},
+		"vetoed":                {quorum, tally(6, 0, 0, 4), false},
+		"only abstained":        {quorum, tally(0, 0, 5, 0), false},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.allow, tc.policy.Allow(tc.tally, total))
+		})
+	}
+}
+
+func TestDecisionPolicyValidateBasic(t *testing.T) {
+	require.Nil(t, ThresholdDecisionPolicy{Threshold: sdk.NewInt(1)}.ValidateBasic())
+	require.NotNil(t, ThresholdDecisionPolicy{Threshold: sdk.ZeroInt()}.ValidateBasic())
+	require.Nil(t, PercentageDecisionPolicy{Percentage: sdk.OneDec()}.ValidateBasic())
+	require.NotNil(t, PercentageDecisionPolicy{Percentage: sdk.NewDecWithPrec(11, 1)}.ValidateBasic())
+	require.NotNil(t, PercentageDecisionPolicy{}.ValidateBasic())
+	require.Nil(t, QuorumDecisionPolicy{Quorum: sdk.ZeroDec(), Threshold: sdk.OneDec(), VetoThreshold: sdk.ZeroDec()}.ValidateBasic())
+	require.NotNil(t, QuorumDecisionPolicy{Quorum: sdk.ZeroDec(), Threshold: sdk.ZeroDec(), VetoThreshold: sdk.ZeroDec()}.ValidateBasic())
+	require.NotNil(t, QuorumDecisionPolicy{Quorum: sdk.OneDec(), Threshold: sdk.OneDec()}.ValidateBasic())
+}
+
+func TestTallyAdd(t *testing.T) {
+	tally, err := NewTally().Add(OptionNo, sdk.NewInt(3))
+	require.Nil(t, err)
+	require.Equal(t, sdk.NewInt(3), tally.No)
+	require.Equal(t, sdk.NewInt(3), tally.Total())
+
+	_, err = tally.Add(VoteOption(0x05), sdk.NewInt(1))
+	require.NotNil(t, err)
+	_, err = tally.Add(OptionEmpty, sdk.NewInt(1))
+	require.NotNil(t, err)
+}
@@ -12,16 +12,73 @@ import (
 const (
 	QueryGet                = "get"
 	QueryGroups             = "groups"
-	QueryGroupsByMember     = "groups_by_member"
-	QueryProposalsByGroupID = "proposals_by_group_id"
+	QueryGroupsByMember     = "groups-by-member"
+	QueryProposalsByGroupID = "proposals-by-group"
+	QueryProposal           = "proposal"
+	QueryVotesByProposal    = "votes-by-proposal"
 )
 
+const (
+	// DefaultQueryLimit is the number of results on a page of paginated
+	// queries that don't set a limit
+	DefaultQueryLimit = 100
+	// MaxQueryLimit is the maximum number of results on a page, higher
+	// limits are lowered to it
+	MaxQueryLimit = 1000
+)
+
+// Paginated queries return the results on Page, starting at one, with up to
+// Limit results per page
+type QueryGroupsParams struct {
+	Page, Limit int
+}
+
 type QueryGroupsByMemberParams struct {
-	Address sdk.AccAddress
+	Address     sdk.AccAddress
+	Page, Limit int
 }
 
 type QueryProposalsByGroupIDrParams struct {
 	Address sdk.AccAddress
+	// Only proposals with this status are returned, unless it is StatusNil
+	Status      ProposalStatus
+	Page, Limit int
+}
+
+type QueryVotesByProposalParams struct {
+	ProposalID  ProposalID
+	Page, Limit int
+}
+
+// pager counts the results of an iteration to find the ones on a page
+type pager struct {
+	start, end, n int
+}
+
+// newPager returns a pager for page, rejecting pages whose results can't be
+// counted without overflowing
+func newPager(page, limit int) (*pager, sdk.Error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = DefaultQueryLimit
+	}
+	if limit > MaxQueryLimit {
+		limit = MaxQueryLimit
+	}
+	if maxInt := int(^uint(0) >> 1); page > maxInt/limit {
+		return nil, sdk.ErrUnknownRequest(fmt.Sprintf("page %d is out of range", page))
+	}
+	start := (page - 1) * limit
+	return &pager{start: start, end: start + limit}, nil
+}
+
+// next returns whether the next result is on the page and whether the
+// iteration can stop after it
+func (p *pager) next() (onPage bool, stop bool) {
+	p.n++
+	return p.n > p.start, p.n >= p.end
 }
 
 func NewQuerier(keeper Keeper) sdk.Querier {
@@ -35,6 +92,10 @@ func NewQuerier(keeper Keeper) sdk.Querier {
 			return queryGroupsByMemberAddress(ctx, path[1:], req, keeper)
 		case QueryProposalsByGroupID:
 			return queryProposalsByGroupID(ctx, path[1:], req, keeper)
+		case QueryProposal:
+			return queryProposal(ctx, path[1:], req, keeper)
+		case QueryVotesByProposal:
+			return queryVotesByProposal(ctx, path[1:], req, keeper)
 		default:
 			return nil, sdk.ErrUnknownRequest("unknown data query endpoint")
 		}
@@ -64,7 +125,27 @@ func queryGroup(ctx sdk.Context, path []string, req abci.RequestQuery, keeper Ke
 }
 
 func queryGroups(ctx sdk.Context, path []string, req abci.RequestQuery, keeper Keeper) (res []byte, err sdk.Error) {
-	groups := keeper.GetGroups(ctx)
+	var params QueryGroupsParams
+	if len(req.Data) > 0 {
+		parseErr := keeper.cdc.UnmarshalJSON(req.Data, &params)
+		if parseErr != nil {
+			err = sdk.ErrUnknownRequest(fmt.Sprintf("Incorrectly formatted request data - %s", parseErr.Error()))
+			return
+		}
+	}
+
+	groups := []Group{}
+	p, err := newPager(params.Page, params.Limit)
+	if err != nil {
+		return nil, err
+	}
+	keeper.IterateGroups(ctx, func(group Group) bool {
+		onPage, stop := p.next()
+		if onPage {
+			groups = append(groups, group)
+		}
+		return stop
+	})
 
 	res, jsonErr := codec.MarshalJSONIndent(keeper.cdc, groups)
 	if jsonErr != nil {
@@ -76,13 +157,24 @@ func queryGroups(ctx sdk.Context, path []string, req abci.RequestQuery, keeper K
 func queryGroupsByMemberAddress(ctx sdk.Context, path []string, req abci.RequestQuery, keeper Keeper) (res []byte, err sdk.Error) {
 
 	var params QueryGroupsByMemberParams
-	parseErr := moduleCodec.UnmarshalJSON(req.Data, &params)
+	parseErr := keeper.cdc.UnmarshalJSON(req.Data, &params)
 	if parseErr != nil {
 		err = sdk.ErrUnknownRequest(fmt.Sprintf("Incorrectly formatted request data - %s", parseErr.Error()))
 		return
 	}
 
-	groups := keeper.GetGroupsByMemberAddress(ctx, params.Address)
+	groups := []Group{}
+	p, err := newPager(params.Page, params.Limit)
+	if err != nil {
+		return nil, err
+	}
+	keeper.IterateGroupsByMemberAddress(ctx, params.Address, func(group Group) bool {
+		onPage, stop := p.next()
+		if onPage {
+			groups = append(groups, group)
+		}
+		return stop
+	})
 
 	res, jsonErr := codec.MarshalJSONIndent(keeper.cdc, groups)
 	if jsonErr != nil {
@@ -94,13 +186,27 @@ func queryGroupsByMemberAddress(ctx sdk.Context, path []string, req abci.Request
 func queryProposalsByGroupID(ctx sdk.Context, path []string, req abci.RequestQuery, keeper Keeper) (res []byte, err sdk.Error) {
 
 	var params QueryProposalsByGroupIDrParams
-	parseErr := moduleCodec.UnmarshalJSON(req.Data, &params)
+	parseErr := keeper.cdc.UnmarshalJSON(req.Data, &params)
 	if parseErr != nil {
 		err = sdk.ErrUnknownRequest(fmt.Sprintf("Incorrectly formatted request data - %s", parseErr.Error()))
 		return
 	}
 
-	proposals := keeper.GetProposalsByGroupID(ctx, params.Address)
+	proposals := []Proposal{}
+	p, err := newPager(params.Page, params.Limit)
+	if err != nil {
+		return nil, err
+	}
+	keeper.IterateProposalsByGroupID(ctx, params.Address, func(proposal Proposal) bool {
+		if params.Status != StatusNil && proposal.Status != params.Status {
+			return false
+		}
+		onPage, stop := p.next()
+		if onPage {
+			proposals = append(proposals, proposal)
+		}
+		return stop
+	})
 
 	res, jsonErr := codec.MarshalJSONIndent(keeper.cdc, proposals)
 	if jsonErr != nil {
@@ -108,3 +214,53 @@ func queryProposalsByGroupID(ctx sdk.Context, path []string, req abci.RequestQue
 	}
 	return res, nil
 }
+
+func queryProposal(ctx sdk.Context, path []string, req abci.RequestQuery, keeper Keeper) (res []byte, err sdk.Error) {
+	if len(path) < 1 {
+		return nil, sdk.ErrUnknownRequest("missing proposal ID")
+	}
+	id, e := decodeProposalIDBech32(path[0])
+	if e != nil {
+		return nil, sdk.ErrUnknownRequest("could not decode proposal ID")
+	}
+
+	proposal, err := keeper.GetProposal(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	res, jsonErr := codec.MarshalJSONIndent(keeper.cdc, proposal)
+	if jsonErr != nil {
+		return nil, sdk.ErrInternal(sdk.AppendMsgToErr("could not marshal result to JSON", jsonErr.Error()))
+	}
+	return res, nil
+}
+
+func queryVotesByProposal(ctx sdk.Context, path []string, req abci.RequestQuery, keeper Keeper) (res []byte, err sdk.Error) {
+
+	var params QueryVotesByProposalParams
+	parseErr := keeper.cdc.UnmarshalJSON(req.Data, &params)
+	if parseErr != nil {
+		err = sdk.ErrUnknownRequest(fmt.Sprintf("Incorrectly formatted request data - %s", parseErr.Error()))
+		return
+	}
+
+	votes := []Vote{}
+	p, err := newPager(params.Page, params.Limit)
+	if err != nil {
+		return nil, err
+	}
+	keeper.IterateVotes(ctx, params.ProposalID, func(vote Vote) bool {
+		onPage, stop := p.next()
+		if onPage {
+			votes = append(votes, vote)
+		}
+		return stop
+	})
+
+	res, jsonErr := codec.MarshalJSONIndent(keeper.cdc, votes)
+	if jsonErr != nil {
+		return nil, sdk.ErrInternal(sdk.AppendMsgToErr("could not marshal result to JSON", jsonErr.Error()))
+	}
+	return res, nil
+}
@@ -3,6 +3,8 @@ package group
 import (
 	"fmt"
 
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
 	"github.com/cosmos/cosmos-sdk/client"
 	"github.com/cosmos/cosmos-sdk/client/context"
 	"github.com/cosmos/cosmos-sdk/codec"
@@ -19,7 +21,10 @@ func GetQueryCmd(queryRoute string, cdc *codec.Codec) *cobra.Command {
 	agentQueryCmd.AddCommand(client.GetCommands(
 		GetCmdGetGroup(queryRoute, cdc),
 		GetCmdGetGroups(queryRoute, cdc),
+		GetCmdGetGroupsByMember(queryRoute, cdc),
 		GetCmdGetProposal(queryRoute, cdc),
+		GetCmdGetProposals(queryRoute, cdc),
+		GetCmdGetVotes(queryRoute, cdc),
 	)...)
 
 	return agentQueryCmd
@@ -51,14 +56,20 @@ func GetCmdGetGroup(queryRoute string, cdc *codec.Codec) *cobra.Command {
 
 // GetCmdGetGroups queries information about an group
 func GetCmdGetGroups(queryRoute string, cdc *codec.Codec) *cobra.Command {
-	return &cobra.Command{
+	var page, limit int
+
+	cmd := &cobra.Command{
 		Use:   "groups",
 		Short: "get groups",
 		// Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cliCtx := context.NewCLIContext().WithCodec(cdc)
 
-			res, err := cliCtx.QueryWithData(fmt.Sprintf("custom/%s/groups", queryRoute), nil)
+			bz, err := cdc.MarshalJSON(QueryGroupsParams{Page: page, Limit: limit})
+			if err != nil {
+				return err
+			}
+			res, err := cliCtx.QueryWithData(fmt.Sprintf("custom/%s/groups", queryRoute), bz)
 			if err != nil {
 				fmt.Println(err)
 				// fmt.Printf("could not resolve group - %s \n", id)
@@ -70,6 +81,42 @@ func GetCmdGetGroups(queryRoute string, cdc *codec.Codec) *cobra.Command {
 			return nil
 		},
 	}
+	addPaginationFlags(cmd, &page, &limit)
+	return cmd
+}
+
+// GetCmdGetGroupsByMember queries the groups of a member
+func GetCmdGetGroupsByMember(queryRoute string, cdc *codec.Codec) *cobra.Command {
+	var page, limit int
+
+	cmd := &cobra.Command{
+		Use:   "groups-by-member [address]",
+		Short: "get the groups an address is a member of",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cliCtx := context.NewCLIContext().WithCodec(cdc)
+
+			member, err := sdk.AccAddressFromBech32(args[0])
+			if err != nil {
+				return err
+			}
+
+			bz, err := cdc.MarshalJSON(QueryGroupsByMemberParams{Address: member, Page: page, Limit: limit})
+			if err != nil {
+				return err
+			}
+			res, err := cliCtx.QueryWithData(fmt.Sprintf("custom/%s/%s", queryRoute, QueryGroupsByMember), bz)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(res))
+
+			return nil
+		},
+	}
+	addPaginationFlags(cmd, &page, &limit)
+	return cmd
 }
 
 // GetCmdProposal queries information about an proposal
@@ -82,7 +129,7 @@ func GetCmdGetProposal(queryRoute string, cdc *codec.Codec) *cobra.Command {
 			cliCtx := context.NewCLIContext().WithCodec(cdc)
 			id := args[0]
 
-			res, err := cliCtx.QueryWithData(fmt.Sprintf("custom/%s/get/%s", queryRoute, id), nil)
+			res, err := cliCtx.QueryWithData(fmt.Sprintf("custom/%s/%s/%s", queryRoute, QueryProposal, id), nil)
 			if err != nil {
 				fmt.Println(err)
 				fmt.Printf("could not resolve proposal - %s \n", id)
@@ -95,3 +142,83 @@ func GetCmdGetProposal(queryRoute string, cdc *codec.Codec) *cobra.Command {
 		},
 	}
 }
+
+// GetCmdGetProposals queries the proposals of a group
+func GetCmdGetProposals(queryRoute string, cdc *codec.Codec) *cobra.Command {
+	var status string
+	var page, limit int
+
+	cmd := &cobra.Command{
+		Use:     "proposals-by-group [group-id]",
+		Aliases: []string{"proposals"},
+		Short:   "get the proposals of a group, optionally only those with a status",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cliCtx := context.NewCLIContext().WithCodec(cdc)
+
+			group, err := sdk.AccAddressFromBech32(args[0])
+			if err != nil {
+				return err
+			}
+			proposalStatus, err := ProposalStatusFromString(status)
+			if err != nil {
+				return err
+			}
+
+			bz, err := cdc.MarshalJSON(QueryProposalsByGroupIDrParams{Address: group, Status: proposalStatus, Page: page, Limit: limit})
+			if err != nil {
+				return err
+			}
+			res, err := cliCtx.QueryWithData(fmt.Sprintf("custom/%s/%s", queryRoute, QueryProposalsByGroupID), bz)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(res))
+
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&status, "status", "", "only get proposals with this status (open, accepted, rejected, executed, expired or invalidated)")
+	addPaginationFlags(cmd, &page, &limit)
+	return cmd
+}
+
+// GetCmdGetVotes queries the votes on a proposal
+func GetCmdGetVotes(queryRoute string, cdc *codec.Codec) *cobra.Command {
+	var page, limit int
+
+	cmd := &cobra.Command{
+		Use:   "votes-by-proposal [proposal-id]",
+		Short: "get the votes on a proposal",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cliCtx := context.NewCLIContext().WithCodec(cdc)
+
+			id, err := decodeProposalIDBech32(args[0])
+			if err != nil {
+				return err
+			}
+
+			bz, err := cdc.MarshalJSON(QueryVotesByProposalParams{ProposalID: id, Page: page, Limit: limit})
+			if err != nil {
+				return err
+			}
+			res, err := cliCtx.QueryWithData(fmt.Sprintf("custom/%s/%s", queryRoute, QueryVotesByProposal), bz)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(res))
+
+			return nil
+		},
+	}
+	addPaginationFlags(cmd, &page, &limit)
+	return cmd
+}
+
+func addPaginationFlags(cmd *cobra.Command, page, limit *int) {
+	cmd.Flags().IntVar(page, "page", 1, "the page of results to get")
+	cmd.Flags().IntVar(limit, "limit", DefaultQueryLimit, "the number of results per page")
+}
@@ -56,8 +56,9 @@ func createdGroupHandlerFn(cliCtx context.CLIContext) http.HandlerFunc {
 
 		signer := cliCtx.GetFromAddress()
 		info := Group{
-			Members:           members,
-			DecisionThreshold: sdk.NewInt(10),
+			Members:        members,
+			DecisionPolicy: ThresholdDecisionPolicy{Threshold: sdk.NewInt(10)},
+			VotingPolicy:   DefaultVotingPolicy,
 		}
 		msg := NewMsgCreateGroup(info, signer)
 
@@ -67,12 +68,12 @@ func createdGroupHandlerFn(cliCtx context.CLIContext) http.HandlerFunc {
 
 func registerQueryRoutes(cliCtx context.CLIContext, r *mux.Router) {
 	r.HandleFunc(
-		"/group/groups_by_member/{memberAddr}",
+		"/group/groups-by-member/{memberAddr}",
 		memberGroupsHandlerFn(cliCtx),
 	).Methods("GET")
 
 	r.HandleFunc(
-		"/group/proposals_by_group_id/{groupId}",
+		"/group/proposals-by-group/{groupId}",
 		groupProposalsHandlerFn(cliCtx),
 	).Methods("GET")
 
@@ -80,12 +81,23 @@ func registerQueryRoutes(cliCtx context.CLIContext, r *mux.Router) {
 		"/group/groups",
 		groupsHandlerFn(cliCtx),
 	).Methods("GET")
+
+	r.HandleFunc(
+		"/group/votes-by-proposal/{proposalId}",
+		proposalVotesHandlerFn(cliCtx),
+	).Methods("GET")
 }
 func groupsHandlerFn(cliCtx context.CLIContext) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		route := fmt.Sprintf("custom/%s/%s", "group", "groups")
 
-		res, err := cliCtx.QueryWithData(route, nil)
+		_, page, limit, err := rest.ParseHTTPArgsWithLimit(r, 0)
+		if err != nil {
+			rest.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		bz, _ := cliCtx.Codec.MarshalJSON(QueryGroupsParams{Page: page, Limit: limit})
+		res, err := cliCtx.QueryWithData(route, bz)
 		if err != nil {
 			rest.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
 			return
@@ -99,11 +111,18 @@ func memberGroupsHandlerFn(cliCtx context.CLIContext) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		memberAddr := vars["memberAddr"]
-		route := fmt.Sprintf("custom/%s/%s", "group", "groups_by_member")
+		route := fmt.Sprintf("custom/%s/%s", "group", QueryGroupsByMember)
 
 		decodedAddr, _ := sdk.AccAddressFromBech32(memberAddr)
+		_, page, limit, err := rest.ParseHTTPArgsWithLimit(r, 0)
+		if err != nil {
+			rest.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		params := QueryGroupsByMemberParams{
 			Address: decodedAddr,
+			Page:    page,
+			Limit:   limit,
 		}
 
 		bz, _ := cliCtx.Codec.MarshalJSON(params)
@@ -121,11 +140,56 @@ func groupProposalsHandlerFn(cliCtx context.CLIContext) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r)
 		memberAddr := vars["groupId"]
-		route := fmt.Sprintf("custom/%s/%s", "group", "proposals_by_group_id")
+		route := fmt.Sprintf("custom/%s/%s", "group", QueryProposalsByGroupID)
 
 		decodedAddr, _ := sdk.AccAddressFromBech32(memberAddr)
+		status, err := ProposalStatusFromString(r.URL.Query().Get("status"))
+		if err != nil {
+			rest.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		_, page, limit, err := rest.ParseHTTPArgsWithLimit(r, 0)
+		if err != nil {
+			rest.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
 		params := QueryProposalsByGroupIDrParams{
 			Address: decodedAddr,
+			Status:  status,
+			Page:    page,
+			Limit:   limit,
+		}
+
+		bz, _ := cliCtx.Codec.MarshalJSON(params)
+		res, err := cliCtx.QueryWithData(route, bz)
+		if err != nil {
+			rest.WriteErrorResponse(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		rest.PostProcessResponse(w, cliCtx, res)
+	}
+}
+
+func proposalVotesHandlerFn(cliCtx context.CLIContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		route := fmt.Sprintf("custom/%s/%s", "group", QueryVotesByProposal)
+
+		id, err := decodeProposalIDBech32(vars["proposalId"])
+		if err != nil {
+			rest.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		_, page, limit, err := rest.ParseHTTPArgsWithLimit(r, 0)
+		if err != nil {
+			rest.WriteErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		params := QueryVotesByProposalParams{
+			ProposalID: id,
+			Page:       page,
+			Limit:      limit,
 		}
 
 		bz, _ := cliCtx.Codec.MarshalJSON(params)
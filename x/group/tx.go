@@ -3,14 +3,15 @@ package group
 import (
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/cosmos/cosmos-sdk/client"
 	"github.com/cosmos/cosmos-sdk/client/context"
 	"github.com/cosmos/cosmos-sdk/client/utils"
 	"github.com/cosmos/cosmos-sdk/codec"
 	sdk "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/x/auth"
 	"github.com/spf13/cobra"
-	"github.com/cosmos/cosmos-sdk/client"
 )
 
 // GetTxCmd returns the transaction commands for this module
@@ -33,7 +34,7 @@ func GetTxCmd(cdc *codec.Codec) *cobra.Command {
 		GetCmdCreateGroup(cdc),
 		GetCmdPropose(cdc),
 		GetCmdTryExec(cdc),
-		GetCmdUnapprove(cdc),
+		GetCmdVote(cdc),
 		GetCmdWithdraw(cdc),
 	)...)
 
@@ -69,9 +70,39 @@ func membersFromArray(arr []string) []Member {
 	return res
 }
 
+// decisionPolicyFromFlags returns a quorum policy when a quorum is given, a
+// percentage policy when only a percentage is given and a threshold policy
+// otherwise
+func decisionPolicyFromFlags(threshold int64, percentage, quorum, vetoThreshold string) (DecisionPolicy, error) {
+	if quorum == "" && percentage == "" {
+		return ThresholdDecisionPolicy{Threshold: sdk.NewInt(threshold)}, nil
+	}
+	if percentage == "" {
+		percentage = "0.5"
+	}
+	percentageDec, err := sdk.NewDecFromStr(percentage)
+	if err != nil {
+		return nil, err
+	}
+	if quorum == "" {
+		return PercentageDecisionPolicy{Percentage: percentageDec}, nil
+	}
+	quorumDec, err := sdk.NewDecFromStr(quorum)
+	if err != nil {
+		return nil, err
+	}
+	vetoDec, err := sdk.NewDecFromStr(vetoThreshold)
+	if err != nil {
+		return nil, err
+	}
+	return QuorumDecisionPolicy{Quorum: quorumDec, Threshold: percentageDec, VetoThreshold: vetoDec}, nil
+}
+
 func GetCmdCreateGroup(cdc *codec.Codec) *cobra.Command {
 	var threshold int64
+	var percentage, quorum, vetoThreshold string
 	var members []string
+	var votingPeriod, minExecutionPeriod time.Duration
 
 	cmd := &cobra.Command{
 		Use:   "create",
@@ -87,13 +118,22 @@ func GetCmdCreateGroup(cdc *codec.Codec) *cobra.Command {
 
 			account := cliCtx.GetFromAddress()
 
+			policy, err := decisionPolicyFromFlags(threshold, percentage, quorum, vetoThreshold)
+			if err != nil {
+				return err
+			}
+
 			info := Group{
-				Members:           membersFromArray(members),
-				DecisionThreshold: sdk.NewInt(threshold),
+				Members:        membersFromArray(members),
+				DecisionPolicy: policy,
+				VotingPolicy: VotingPolicy{
+					VotingPeriod:       votingPeriod,
+					MinExecutionPeriod: minExecutionPeriod,
+				},
 			}
 
 			msg := NewMsgCreateGroup(info, account)
-			err := msg.ValidateBasic()
+			err = msg.ValidateBasic()
 			if err != nil {
 				return err
 			}
@@ -104,8 +144,13 @@ func GetCmdCreateGroup(cdc *codec.Codec) *cobra.Command {
 		},
 	}
 
-	cmd.Flags().Int64Var(&threshold, "decision-threshold", 1, "Decision threshold")
+	cmd.Flags().Int64Var(&threshold, "decision-threshold", 1, "Weight of the yes votes needed for a decision")
+	cmd.Flags().StringVar(&percentage, "decision-percentage", "", "Share of the weight of all members, or with a quorum of the yes, no and veto votes, that must vote yes for a decision, instead of a decision threshold")
+	cmd.Flags().StringVar(&quorum, "quorum", "", "Share of the weight of all members that must vote for a decision")
+	cmd.Flags().StringVar(&vetoThreshold, "veto-threshold", "0", "Share of the votes that must veto to prevent a decision when a quorum is used, 0 disables vetoes")
 	cmd.Flags().StringArrayVar(&members, "members", []string{}, "Members")
+	cmd.Flags().DurationVar(&votingPeriod, "voting-period", DefaultVotingPolicy.VotingPeriod, "How long proposals are open for votes")
+	cmd.Flags().DurationVar(&minExecutionPeriod, "min-execution-period", DefaultVotingPolicy.MinExecutionPeriod, "How long accepted proposals wait before they can be executed")
 
 	return cmd
 }
@@ -154,8 +199,17 @@ func GetCmdPropose(cdc *codec.Codec) *cobra.Command {
 	return cmd
 }
 
-func getRunVote(cdc *codec.Codec, approve bool) func(cmd *cobra.Command, args []string) error {
+func getRunVote(cdc *codec.Codec, option VoteOption) func(cmd *cobra.Command, args []string) error {
 	return func(cmd *cobra.Command, args []string) error {
+		opt := option
+		if len(args) > 1 {
+			var err error
+			opt, err = VoteOptionFromString(args[1])
+			if err != nil {
+				return err
+			}
+		}
+
 		cliCtx := context.NewCLIContext().WithCodec(cdc).WithAccountDecoder(cdc)
 
 		txBldr := auth.NewTxBuilderFromCLI().WithTxEncoder(utils.GetTxEncoder(cdc))
@@ -171,7 +225,7 @@ func getRunVote(cdc *codec.Codec, approve bool) func(cmd *cobra.Command, args []
 		msg := MsgVote{
 			ProposalID: id,
 			Voter:      account,
-			Vote:       approve,
+			Option:     opt,
 		}
 		err := msg.ValidateBasic()
 		if err != nil {
@@ -189,16 +243,16 @@ func GetCmdApprove(cdc *codec.Codec) *cobra.Command {
 		Use:   "approve [ID]",
 		Short: "vote to approve a proposal",
 		Args:  cobra.ExactArgs(1),
-		RunE:  getRunVote(cdc, true),
+		RunE:  getRunVote(cdc, OptionYes),
 	}
 }
 
-func GetCmdUnapprove(cdc *codec.Codec) *cobra.Command {
+func GetCmdVote(cdc *codec.Codec) *cobra.Command {
 	return &cobra.Command{
-		Use:   "unapprove [ID]",
-		Short: "vote to un-approve a proposal that you have previously approved",
-		Args:  cobra.ExactArgs(1),
-		RunE:  getRunVote(cdc, false),
+		Use:   "vote [ID] [option]",
+		Short: "vote yes, no, abstain or veto on a proposal, votes can't be changed",
+		Args:  cobra.ExactArgs(2),
+		RunE:  getRunVote(cdc, OptionEmpty),
 	}
 }
 
@@ -3,6 +3,10 @@
 package group
 
 import (
+	"encoding/json"
+	"fmt"
+	"time"
+
 	sdk "github.com/cosmos/cosmos-sdk/types"
 )
 
@@ -18,14 +22,61 @@ const (
 type Group struct {
 	// The members of the group and their associated weight
 	Members []Member `json:"members,omitempty"`
-	// Specifies the number of votes that must be accumulated in order for a decision to be made by the group.
+	// Specifies the votes that must be accumulated in order for a decision to be made by the group.
 	// A member gets as many votes as is indicated by their Weight field.
-	// A big integer is used here to avoid any potential vulnerabilities from overflow errors
-	// where large weight and threshold values are used.
-	DecisionThreshold sdk.Int `json:"decision_threshold"`
+	DecisionPolicy DecisionPolicy `json:"decision_policy"`
 	// TODO maybe make this something more specific to a domain name or a claim on identity? or Info leave it generic
-	Memo string `json:"memo,omitempty"`
-	ID   sdk.AccAddress
+	Memo string         `json:"memo,omitempty"`
+	ID   sdk.AccAddress `json:"id"`
+	// The voting period and execution delay of proposals of the group
+	VotingPolicy VotingPolicy `json:"voting_policy"`
+	// Version is incremented on every change of the group, proposals
+	// submitted under an older version are invalidated
+	Version uint64 `json:"version"`
+}
+
+// A voting policy specifies how long proposals of a group are open for votes
+// and how long accepted proposals wait before they can be executed
+type VotingPolicy struct {
+	// The time after submission when voting on a proposal ends. Proposals that
+	// weren't executed by then are closed.
+	VotingPeriod time.Duration `json:"voting_period"`
+	// The time after submission before which an accepted proposal can't be
+	// executed, giving the members that didn't vote yet time to cast votes,
+	// which can open a proposal accepted by a quorum again
+	MinExecutionPeriod time.Duration `json:"min_execution_period"`
+}
+
+// DefaultVotingPolicy is used for groups created without a voting policy
+var DefaultVotingPolicy = VotingPolicy{VotingPeriod: 48 * time.Hour}
+
+func (policy VotingPolicy) ValidateBasic() sdk.Error {
+	if policy.VotingPeriod <= 0 {
+		return sdk.ErrUnknownRequest(fmt.Sprintf("VotingPeriod must be positive, got %s", policy.VotingPeriod))
+	}
+	if policy.MinExecutionPeriod < 0 || policy.MinExecutionPeriod >= policy.VotingPeriod {
+		return sdk.ErrUnknownRequest(fmt.Sprintf("MinExecutionPeriod must be at least zero and shorter than the VotingPeriod, got %s", policy.MinExecutionPeriod))
+	}
+	return nil
+}
+
+// TotalWeight returns the weight of all members
+func (info Group) TotalWeight() sdk.Int {
+	total := sdk.ZeroInt()
+	for _, mem := range info.Members {
+		total = total.Add(mem.Weight)
+	}
+	return total
+}
+
+// MemberWeight returns the weight of addr, which is zero for non members
+func (info Group) MemberWeight(addr sdk.AccAddress) sdk.Int {
+	for _, mem := range info.Members {
+		if mem.Address.Equals(addr) {
+			return mem.Weight
+		}
+	}
+	return sdk.ZeroInt()
 }
 
 // A member specifies a address and a weight for a group member
@@ -37,10 +88,111 @@ type Member struct {
 }
 
 type Proposal struct {
-	Group     sdk.AccAddress   `json:"group"`
-	Proposer  sdk.AccAddress   `json:"proposer"`
-	Msgs      []sdk.Msg        `json:"msgs"`
-	Approvers []sdk.AccAddress `json:"approvers,omitempty"`
+	ID       ProposalID     `json:"id"`
+	Group    sdk.AccAddress `json:"group"`
+	Proposer sdk.AccAddress `json:"proposer"`
+	Msgs     []sdk.Msg      `json:"msgs"`
+	// The weight of the ballots cast on the proposal, the ballots are stored
+	// per voter
+	Tally Tally `json:"tally"`
+	// The version of the group the proposal was submitted under
+	GroupVersion uint64 `json:"group_version"`
+	// The time the proposal was submitted and the time voting on it ends, as
+	// set by the voting policy of the group
+	SubmitTime    time.Time      `json:"submit_time"`
+	VotingEndTime time.Time      `json:"voting_end_time"`
+	Status        ProposalStatus `json:"status"`
 }
 
 type ProposalID uint64
+
+// Vote is the ballot of a member of the group on a proposal
+type Vote struct {
+	ProposalID ProposalID     `json:"proposal_id"`
+	Voter      sdk.AccAddress `json:"voter"`
+	Option     VoteOption     `json:"option"`
+}
+
+// ProposalStatus is the state of a proposal. Proposals are open until the
+// decision policy of the group accepts their tally, accepted proposals can be
+// executed once the minimum execution period passed. Proposals are rejected
+// as soon as the remaining members can't accept them anymore or when they are
+// still open when voting ends, accepted ones that weren't executed expire.
+// Pending proposals are invalidated when the group changes.
+type ProposalStatus byte
+
+const (
+	StatusNil         ProposalStatus = 0x00
+	StatusOpen        ProposalStatus = 0x01
+	StatusAccepted    ProposalStatus = 0x02
+	StatusRejected    ProposalStatus = 0x03
+	StatusExecuted    ProposalStatus = 0x04
+	StatusExpired     ProposalStatus = 0x05
+	StatusInvalidated ProposalStatus = 0x06
+)
+
+// ProposalStatusFromString turns a string into a ProposalStatus
+func ProposalStatusFromString(str string) (ProposalStatus, error) {
+	switch str {
+	case "open":
+		return StatusOpen, nil
+	case "accepted":
+		return StatusAccepted, nil
+	case "rejected":
+		return StatusRejected, nil
+	case "executed":
+		return StatusExecuted, nil
+	case "expired":
+		return StatusExpired, nil
+	case "invalidated":
+		return StatusInvalidated, nil
+	case "":
+		return StatusNil, nil
+	default:
+		return ProposalStatus(0xff), fmt.Errorf("'%s' is not a valid proposal status", str)
+	}
+}
+
+// IsClosed returns whether the proposal can't be voted on or executed anymore
+func (status ProposalStatus) IsClosed() bool {
+	return status != StatusOpen && status != StatusAccepted
+}
+
+// String implements the Stringer interface
+func (status ProposalStatus) String() string {
+	switch status {
+	case StatusOpen:
+		return "open"
+	case StatusAccepted:
+		return "accepted"
+	case StatusRejected:
+		return "rejected"
+	case StatusExecuted:
+		return "executed"
+	case StatusExpired:
+		return "expired"
+	case StatusInvalidated:
+		return "invalidated"
+	default:
+		return ""
+	}
+}
+
+// MarshalJSON marshals the status as a string
+func (status ProposalStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(status.String())
+}
+
+// UnmarshalJSON unmarshals the status from a string
+func (status *ProposalStatus) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ProposalStatusFromString(s)
+	if err != nil {
+		return err
+	}
+	*status = parsed
+	return nil
+}